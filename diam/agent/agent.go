@@ -0,0 +1,298 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package agent
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/redirecthostusage"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm/smpeer"
+)
+
+// DefaultTimeout is how long the agent waits for the answer of a
+// forwarded request when Agent.Timeout is not set.
+const DefaultTimeout = 10 * time.Second
+
+// DefaultMaxRequests is how many requests the agent routes at once when
+// Agent.MaxRequests is not set.
+const DefaultMaxRequests = 1024
+
+// RequestHook is called by proxy routes before forwarding the request
+// m to peer, and may modify it. If it returns an error the request is
+// not forwarded, and is answered with DIAMETER_UNABLE_TO_COMPLY.
+type RequestHook func(m *diam.Message, peer *sm.PeerEntry) error
+
+// AnswerHook is called by proxy routes with the answer of a forwarded
+// request before it is sent back, and may modify the answer. If it
+// returns an error the answer is replaced with DIAMETER_UNABLE_TO_COMPLY.
+type AnswerHook func(req, answer *diam.Message, peer *sm.PeerEntry) error
+
+// Agent is a diameter relay and proxy agent. See RFC 6733 section 6.1.
+//
+// Requests are routed according to their Destination-Host and
+// Destination-Realm, as in sm.RoutingTable.SelectPeer, and forwarded
+// with a Route-Record AVP identifying the peer they were received from.
+// The Hop-by-Hop Identifier is replaced when forwarding, and restored
+// in the answer. Requests are answered with an error when:
+//
+//   - the local node is listed in a Route-Record: DIAMETER_LOOP_DETECTED
+//   - there is no route for the realm: DIAMETER_REALM_NOT_SERVED
+//   - no peer of the route is available, or the forwarded request
+//     times out or fails on all of them: DIAMETER_UNABLE_TO_DELIVER
+//   - the agent is already routing MaxRequests requests:
+//     DIAMETER_TOO_BUSY
+//
+// Routes with LocalActionRedirect make the agent a redirect agent for
+// their realm: requests are answered with DIAMETER_REDIRECT_INDICATION
+// and the redirect AVPs of the route. See RFC 6733 section 6.1.7.
+//
+// Routes with LocalActionRelay forward requests unmodified, while
+// routes with LocalActionProxy run the RequestHook and AnswerHook.
+type Agent struct {
+	// Local handles requests for the local node: requests to its
+	// Origin-Host, requests without destination, requests to its
+	// Origin-Realm without route, and requests matching a route with
+	// LocalActionLocal. When nil they are answered with
+	// DIAMETER_COMMAND_UNSUPPORTED.
+	Local diam.Handler
+
+	RequestHook RequestHook // Proxy request rewrite, if any
+	AnswerHook  AnswerHook  // Proxy answer rewrite, if any
+
+	// Timeout is how long to wait for the answer of forwarded
+	// requests. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// MaxRequests is how many requests are routed at once, each in
+	// its own goroutine. Defaults to DefaultMaxRequests.
+	MaxRequests int
+
+	sm       *sm.StateMachine
+	routes   *sm.RoutingTable
+	inFlight int32 // requests being routed
+}
+
+// New creates an Agent that forwards requests using the peers of the
+// state machine sm and the routing table routes, and registers it as
+// the catch-all ("ALL") handler of sm.
+func New(machine *sm.StateMachine, routes *sm.RoutingTable) *Agent {
+	a := &Agent{
+		sm:     machine,
+		routes: routes,
+	}
+	machine.Handle("ALL", a)
+	return a
+}
+
+// ServeDIAM implements the diam.Handler interface. Answers that do not
+// match a forwarded request are ignored.
+func (a *Agent) ServeDIAM(c diam.Conn, m *diam.Message) {
+	if m.Header.CommandFlags&diam.RequestFlag == 0 {
+		return
+	}
+	max := a.MaxRequests
+	if max <= 0 {
+		max = DefaultMaxRequests
+	}
+	if n := atomic.AddInt32(&a.inFlight, 1); int(n) > max {
+		atomic.AddInt32(&a.inFlight, -1)
+		a.answerError(c, m, diam.TooBusy)
+		return
+	}
+	go func() {
+		defer atomic.AddInt32(&a.inFlight, -1)
+		a.route(c, m)
+	}()
+}
+
+// route routes the request m received on c.
+func (a *Agent) route(c diam.Conn, m *diam.Message) {
+	cfg := a.sm.Settings()
+	if a.isLocal(m) {
+		a.serveLocal(c, m)
+		return
+	}
+	for _, rr := range identityAVPs(m, avp.RouteRecord) {
+		if rr == cfg.OriginHost {
+			a.answerError(c, m, diam.LoopDetected)
+			return
+		}
+	}
+	peer, route, err := a.routes.SelectPeer(a.sm.PeerTable(), m)
+	switch {
+	case err == sm.ErrRealmNotServed:
+		if realm, _ := identityAVP(m, avp.DestinationRealm); realm == cfg.OriginRealm {
+			a.serveLocal(c, m)
+		} else {
+			a.answerError(c, m, diam.RealmNotServed)
+		}
+		return
+	case err != nil:
+		a.answerError(c, m, diam.UnableToDeliver)
+		return
+	case peer == nil && route.Action == sm.LocalActionLocal:
+		a.serveLocal(c, m)
+		return
+	case peer == nil && route.Action == sm.LocalActionRedirect:
+		a.answerRedirect(c, m, route)
+		return
+	case peer == nil:
+		a.answerError(c, m, diam.UnableToDeliver)
+		return
+	}
+	a.forward(c, m, peer, route)
+}
+
+// forward forwards a copy of the request m received on c to peer, and
+// writes the answer back to c. If peer fails over before answering, the
+// request is retransmitted to the other servers of route.
+func (a *Agent) forward(c diam.Conn, m *diam.Message, peer *sm.PeerEntry, route *sm.Route) {
+	proxy := route != nil && route.Action == sm.LocalActionProxy
+	hosts := []datatype.DiameterIdentity{peer.Host}
+	if route != nil {
+		hosts = append(hosts, route.Servers...)
+	}
+	// The Route-Record, the hooks and SendRequest, which replaces the
+	// Hop-by-Hop Identifier and may set the T flag, modify the copy.
+	fwd := copyMessage(m)
+	if meta, ok := smpeer.FromContext(c.Context()); ok {
+		fwd.NewAVP(avp.RouteRecord, avp.Mbit, 0, meta.OriginHost)
+	}
+	if proxy && a.RequestHook != nil {
+		if err := a.RequestHook(fwd, peer); err != nil {
+			a.report(c, fwd, err)
+			a.answerError(c, m, diam.UnableToComply)
+			return
+		}
+	}
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ans, err := a.sm.SendRequestFailover(ctx, fwd, hosts)
+	if err != nil {
+		a.report(c, fwd, err)
+		a.answerError(c, m, diam.UnableToDeliver)
+		return
+	}
+	if proxy && a.AnswerHook != nil {
+		if err := a.AnswerHook(fwd, ans, peer); err != nil {
+			a.report(c, ans, err)
+			a.answerError(c, m, diam.UnableToComply)
+			return
+		}
+	}
+	ans.Header.HopByHopID = m.Header.HopByHopID
+	ans.Header.EndToEndID = m.Header.EndToEndID
+	a.write(c, ans, m.MessageStream())
+}
+
+// copyMessage returns a copy of m with its own header and list of AVPs.
+// The AVPs are shared.
+func copyMessage(m *diam.Message) *diam.Message {
+	cp := *m
+	hdr := *m.Header
+	cp.Header = &hdr
+	cp.AVP = append([]*diam.AVP(nil), m.AVP...)
+	return &cp
+}
+
+// isLocal reports whether the request m is for the local node by its
+// destination: either its Destination-Host is the local Origin-Host, or
+// it has neither Destination-Host nor Destination-Realm.
+func (a *Agent) isLocal(m *diam.Message) bool {
+	host, hasHost := identityAVP(m, avp.DestinationHost)
+	if hasHost {
+		return host == a.sm.Settings().OriginHost
+	}
+	_, hasRealm := identityAVP(m, avp.DestinationRealm)
+	return !hasRealm
+}
+
+func (a *Agent) serveLocal(c diam.Conn, m *diam.Message) {
+	if a.Local == nil {
+		a.answerError(c, m, diam.CommandUnsupported)
+		return
+	}
+	a.Local.ServeDIAM(c, m)
+}
+
+// answerError answers the request m with an error generated by the
+// local node. Protocol errors have the E bit set. See RFC 6733 section 7.
+func (a *Agent) answerError(c diam.Conn, m *diam.Message, code uint32) {
+	a.write(c, a.errorAnswer(m, code), m.MessageStream())
+}
+
+// answerRedirect answers the request m with a redirect indication
+// built from route.
+func (a *Agent) answerRedirect(c diam.Conn, m *diam.Message, route *sm.Route) {
+	ans := a.errorAnswer(m, diam.RedirectIndication)
+	hosts := route.RedirectHosts
+	if len(hosts) == 0 {
+		for _, s := range route.Servers {
+			hosts = append(hosts, datatype.DiameterURI("aaa://"+s))
+		}
+	}
+	for _, h := range hosts {
+		ans.NewAVP(avp.RedirectHost, avp.Mbit, 0, h)
+	}
+	if route.RedirectUsage != redirecthostusage.DONT_CACHE {
+		ans.NewAVP(avp.RedirectHostUsage, avp.Mbit, 0, route.RedirectUsage)
+		ans.NewAVP(avp.RedirectMaxCacheTime, avp.Mbit, 0,
+			datatype.Unsigned32(route.RedirectMaxCacheTime/time.Second))
+	}
+	a.write(c, ans, m.MessageStream())
+}
+
+// errorAnswer returns the answer to the request m with the given
+// Result-Code, generated by the local node.
+func (a *Agent) errorAnswer(m *diam.Message, code uint32) *diam.Message {
+	return a.sm.ErrorAnswer(m, code)
+}
+
+func (a *Agent) write(c diam.Conn, m *diam.Message, stream uint) {
+	if _, err := m.WriteToStream(c, stream); err != nil {
+		a.report(c, m, err)
+	}
+}
+
+func (a *Agent) report(c diam.Conn, m *diam.Message, err error) {
+	a.sm.Error(&diam.ErrorReport{
+		Conn:    c,
+		Message: m,
+		Error:   err,
+	})
+}
+
+// identityAVP returns the value of the first DiameterIdentity AVP code of m.
+func identityAVP(m *diam.Message, code uint32) (datatype.DiameterIdentity, bool) {
+	ids := identityAVPs(m, code)
+	if len(ids) == 0 {
+		return "", false
+	}
+	return ids[0], true
+}
+
+// identityAVPs returns the values of all DiameterIdentity AVPs code of m.
+func identityAVPs(m *diam.Message, code uint32) []datatype.DiameterIdentity {
+	var ids []datatype.DiameterIdentity
+	for _, a := range m.AVP {
+		if a.Code != code || a.VendorID != 0 {
+			continue
+		}
+		if id, ok := a.Data.(datatype.DiameterIdentity); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
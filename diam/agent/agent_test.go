@@ -0,0 +1,302 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/agent"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/redirecthostusage"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm"
+)
+
+var (
+	clientSettings = &sm.Settings{
+		OriginHost:       "cli",
+		OriginRealm:      "visited",
+		VendorID:         13,
+		ProductName:      "go-diameter",
+		FirmwareRevision: 1,
+	}
+
+	agentSettings = &sm.Settings{
+		OriginHost:       "agent",
+		OriginRealm:      "visited",
+		VendorID:         13,
+		ProductName:      "go-diameter",
+		FirmwareRevision: 1,
+	}
+
+	serverSettings = &sm.Settings{
+		OriginHost:       "srv",
+		OriginRealm:      "home",
+		VendorID:         13,
+		ProductName:      "go-diameter",
+		FirmwareRevision: 1,
+	}
+)
+
+var ccAppID = []*diam.AVP{
+	diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(diam.CHARGING_CONTROL_APP_ID)),
+}
+
+// testNetwork is a client connected to an agent connected to a server.
+type testNetwork struct {
+	agent *agent.Agent
+	cli   diam.Conn
+	reqc  chan *diam.Message // Requests received by the server
+	close func()
+}
+
+func newTestNetwork(t *testing.T, action sm.LocalAction) *testNetwork {
+	t.Helper()
+	reqc := make(chan *diam.Message, 1)
+	srvSM := sm.New(serverSettings)
+	srvSM.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		reqc <- m
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, serverSettings.OriginHost)
+		a.NewAVP(avp.OriginRealm, avp.Mbit, 0, serverSettings.OriginRealm)
+		a.WriteTo(c)
+	})
+	srv := diamtest.NewServer(srvSM, dict.Default)
+
+	agentSM := sm.New(agentSettings)
+	routes := sm.NewRoutingTable()
+	routes.Add(&sm.Route{
+		Realm:   serverSettings.OriginRealm,
+		AppID:   diam.CHARGING_CONTROL_APP_ID,
+		Action:  action,
+		Servers: []datatype.DiameterIdentity{serverSettings.OriginHost},
+	})
+	routes.Add(&sm.Route{
+		Realm:   "down",
+		AppID:   sm.RelayApplicationID,
+		Action:  sm.LocalActionRelay,
+		Servers: []datatype.DiameterIdentity{"ghost"},
+	})
+	routes.Add(&sm.Route{
+		Realm:                "moved",
+		AppID:                sm.RelayApplicationID,
+		Action:               sm.LocalActionRedirect,
+		Servers:              []datatype.DiameterIdentity{serverSettings.OriginHost},
+		RedirectUsage:        redirecthostusage.ALL_REALM,
+		RedirectMaxCacheTime: time.Minute,
+	})
+	ag := agent.New(agentSM, routes)
+	ag.Timeout = time.Second
+	agentSrv := diamtest.NewServer(agentSM, dict.Default)
+	upstream, err := (&sm.Client{Handler: agentSM, AuthApplicationID: ccAppID}).Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := (&sm.Client{Handler: sm.New(clientSettings), AuthApplicationID: ccAppID}).Dial(agentSrv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testNetwork{
+		agent: ag,
+		cli:   cli,
+		reqc:  reqc,
+		close: func() {
+			cli.Close()
+			upstream.Close()
+			agentSrv.Close()
+			srv.Close()
+		},
+	}
+}
+
+func newCCR(realm datatype.DiameterIdentity) *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, diam.CHARGING_CONTROL_APP_ID, dict.Default)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1;1"))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+	m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, realm)
+	m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(diam.CHARGING_CONTROL_APP_ID))
+	m.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(1))
+	m.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+	return m
+}
+
+func sendRequest(t *testing.T, c diam.Conn, m *diam.Message) *diam.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	a, err := diam.SendRequest(ctx, c, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func resultCode(t *testing.T, m *diam.Message) uint32 {
+	t.Helper()
+	rc, err := m.FindAVP(avp.ResultCode, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uint32(rc.Data.(datatype.Unsigned32))
+}
+
+func routeRecords(m *diam.Message) []datatype.DiameterIdentity {
+	var ids []datatype.DiameterIdentity
+	for _, a := range m.AVP {
+		if a.Code == avp.RouteRecord {
+			ids = append(ids, a.Data.(datatype.DiameterIdentity))
+		}
+	}
+	return ids
+}
+
+func TestAgent_Relay(t *testing.T) {
+	n := newTestNetwork(t, sm.LocalActionRelay)
+	defer n.close()
+	req := newCCR(serverSettings.OriginRealm)
+	a := sendRequest(t, n.cli, req)
+	if code := resultCode(t, a); code != diam.Success {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.Success, code)
+	}
+	if a.Header.HopByHopID != req.Header.HopByHopID {
+		t.Fatalf("Unexpected Hop-by-Hop Identifier. Want %#x, have %#x",
+			req.Header.HopByHopID, a.Header.HopByHopID)
+	}
+	fwd := <-n.reqc
+	rr := routeRecords(fwd)
+	if len(rr) != 1 || rr[0] != clientSettings.OriginHost {
+		t.Fatalf("Unexpected Route-Record. Want [%s], have %v", clientSettings.OriginHost, rr)
+	}
+	if fwd.Header.EndToEndID != req.Header.EndToEndID {
+		t.Fatalf("Unexpected End-to-End Identifier. Want %#x, have %#x",
+			req.Header.EndToEndID, fwd.Header.EndToEndID)
+	}
+}
+
+func TestAgent_Errors(t *testing.T) {
+	n := newTestNetwork(t, sm.LocalActionRelay)
+	defer n.close()
+	loop := newCCR(serverSettings.OriginRealm)
+	loop.NewAVP(avp.RouteRecord, avp.Mbit, 0, agentSettings.OriginHost)
+	local := newCCR(agentSettings.OriginRealm)
+	for _, tc := range []struct {
+		name string
+		m    *diam.Message
+		want uint32
+	}{
+		{"loop", loop, diam.LoopDetected},
+		{"realm not served", newCCR("nowhere"), diam.RealmNotServed},
+		{"unable to deliver", newCCR("down"), diam.UnableToDeliver},
+		{"local", local, diam.CommandUnsupported},
+	} {
+		a := sendRequest(t, n.cli, tc.m)
+		if code := resultCode(t, a); code != tc.want {
+			t.Errorf("%s: unexpected Result-Code. Want %d, have %d", tc.name, tc.want, code)
+		}
+		if a.Header.CommandFlags&diam.ErrorFlag == 0 {
+			t.Errorf("%s: E bit not set", tc.name)
+		}
+		host, err := a.FindAVP(avp.OriginHost, 0)
+		if err != nil || host.Data != agentSettings.OriginHost {
+			t.Errorf("%s: unexpected Origin-Host %v", tc.name, host)
+		}
+	}
+	select {
+	case m := <-n.reqc:
+		t.Fatalf("Unexpected request forwarded: %s", m)
+	default:
+	}
+}
+
+func TestAgent_Redirect(t *testing.T) {
+	n := newTestNetwork(t, sm.LocalActionRelay)
+	defer n.close()
+	a := sendRequest(t, n.cli, newCCR("moved"))
+	if code := resultCode(t, a); code != diam.RedirectIndication {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.RedirectIndication, code)
+	}
+	r, err := sm.ParseRedirect(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Hosts) != 1 || r.Hosts[0].URI != "aaa://srv" {
+		t.Fatalf("Unexpected Redirect-Host %v", r.Hosts)
+	}
+	if r.Usage != redirecthostusage.ALL_REALM || r.MaxCacheTime != time.Minute {
+		t.Fatalf("Unexpected redirect %+v", r)
+	}
+}
+
+func TestAgent_Proxy(t *testing.T) {
+	n := newTestNetwork(t, sm.LocalActionProxy)
+	defer n.close()
+	n.agent.RequestHook = func(m *diam.Message, peer *sm.PeerEntry) error {
+		m.NewAVP(avp.DestinationHost, avp.Mbit, 0, peer.Host)
+		return nil
+	}
+	n.agent.AnswerHook = func(req, answer *diam.Message, peer *sm.PeerEntry) error {
+		answer.NewAVP(avp.RouteRecord, avp.Mbit, 0, peer.Host)
+		return nil
+	}
+	a := sendRequest(t, n.cli, newCCR(serverSettings.OriginRealm))
+	if code := resultCode(t, a); code != diam.Success {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.Success, code)
+	}
+	if _, err := a.FindAVP(avp.RouteRecord, 0); err != nil {
+		t.Fatal("AnswerHook not applied")
+	}
+	fwd := <-n.reqc
+	host, err := fwd.FindAVP(avp.DestinationHost, 0)
+	if err != nil || host.Data != serverSettings.OriginHost {
+		t.Fatalf("RequestHook not applied: %v", host)
+	}
+
+	n.agent.RequestHook = func(m *diam.Message, peer *sm.PeerEntry) error {
+		return errors.New("rejected")
+	}
+	a = sendRequest(t, n.cli, newCCR(serverSettings.OriginRealm))
+	if code := resultCode(t, a); code != diam.UnableToComply {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.UnableToComply, code)
+	}
+}
+
+func TestAgent_MaxRequests(t *testing.T) {
+	n := newTestNetwork(t, sm.LocalActionProxy)
+	defer n.close()
+	n.agent.MaxRequests = 1
+	entered, release := make(chan struct{}), make(chan struct{})
+	n.agent.RequestHook = func(m *diam.Message, peer *sm.PeerEntry) error {
+		close(entered)
+		<-release
+		return nil
+	}
+	done := make(chan *diam.Message, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		a, err := diam.SendRequest(ctx, n.cli, newCCR(serverSettings.OriginRealm))
+		if err != nil {
+			t.Error(err)
+			a = diam.NewRequest(diam.CreditControl, 0, nil).Answer(diam.UnableToDeliver)
+		}
+		done <- a
+	}()
+	<-entered
+	a := sendRequest(t, n.cli, newCCR(serverSettings.OriginRealm))
+	if code := resultCode(t, a); code != diam.TooBusy {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.TooBusy, code)
+	}
+	close(release)
+	if code := resultCode(t, <-done); code != diam.Success {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.Success, code)
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package agent provides a diameter relay and proxy agent.
+//
+// The agent is registered as the catch-all handler of a state machine,
+// and forwards the requests it receives according to a realm routing
+// table, as described in RFC 6733 section 6.1:
+//
+//	machine := sm.New(settings)
+//	routes := sm.NewRoutingTable()
+//	routes.Add(&sm.Route{
+//		Realm:   "hss.example.com",
+//		AppID:   diam.TGPP_S6A_APP_ID,
+//		Action:  sm.LocalActionRelay,
+//		Servers: []datatype.DiameterIdentity{"hss1.example.com"},
+//	})
+//	agent.New(machine, routes)
+//	diam.ListenAndServe(":3868", machine, nil)
+//
+// Upstream peers are connected with sm.Client using the same state
+// machine, so their answers reach the agent and they become part of
+// the peer table of the state machine.
+package agent
@@ -0,0 +1,90 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Hooks on the answers written to diameter connections.
+
+package diam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+// The AnswerHooker interface is implemented by Conns which allow
+// modifying the answer to a request before it is written, such as
+// those of Server and Dial. Handlers wrapping others use it to add
+// AVPs to their answers, without replacing their Conn.
+type AnswerHooker interface {
+	// HookAnswer makes f be called with the first answer to the
+	// request req written to the connection, before it is written.
+	// f may modify the answer. The returned function removes the
+	// hook, if f was not called yet.
+	HookAnswer(req *Message, f func(answer *Message)) (remove func())
+}
+
+// answerHook is a hook of the answer to a request.
+type answerHook struct {
+	f func(answer *Message)
+}
+
+// answerHooks tracks the hooks of the answers of a connection, indexed
+// by Hop-by-Hop Identifier.
+type answerHooks struct {
+	mu    sync.Mutex // guards hooks
+	hooks map[uint32]*answerHook
+}
+
+func newAnswerHooks() *answerHooks {
+	return &answerHooks{
+		hooks: make(map[uint32]*answerHook),
+	}
+}
+
+// HookAnswer implements the AnswerHooker interface.
+func (w *response) HookAnswer(req *Message, f func(answer *Message)) func() {
+	hs := w.conn.hooks
+	hopByHop := req.Header.HopByHopID
+	h := &answerHook{f}
+	hs.mu.Lock()
+	hs.hooks[hopByHop] = h
+	hs.mu.Unlock()
+	return func() {
+		hs.mu.Lock()
+		if hs.hooks[hopByHop] == h {
+			delete(hs.hooks, hopByHop)
+		}
+		hs.mu.Unlock()
+	}
+}
+
+// apply returns the message b modified by the hook of its answer, if b
+// is a hooked answer. b is returned as is if it cannot be decoded with
+// the dictionary d.
+func (hs *answerHooks) apply(b []byte, d *dict.Parser) []byte {
+	if len(b) < HeaderLength || b[4]&RequestFlag != 0 {
+		return b
+	}
+	hopByHop := binary.BigEndian.Uint32(b[12:16])
+	hs.mu.Lock()
+	h, ok := hs.hooks[hopByHop]
+	if ok {
+		delete(hs.hooks, hopByHop)
+	}
+	hs.mu.Unlock()
+	if !ok {
+		return b
+	}
+	m, err := ReadMessage(bytes.NewReader(b), d)
+	if err != nil {
+		return b
+	}
+	h.f(m)
+	if hooked, err := m.Serialize(); err == nil {
+		return hooked
+	}
+	return b
+}
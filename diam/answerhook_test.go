@@ -0,0 +1,53 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+)
+
+func TestHookAnswer(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {
+		remove := c.(diam.AnswerHooker).HookAnswer(m, func(a *diam.Message) {
+			a.NewAVP(avp.ErrorMessage, 0, 0, datatype.UTF8String("hooked"))
+		})
+		if _, err := m.FindAVP(avp.OriginStateID, 0); err == nil {
+			remove()
+		}
+		m.Answer(diam.Success).WriteTo(c)
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	cli, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	for _, removed := range []bool{false, true} {
+		m := diam.NewRequest(diam.DeviceWatchdog, 0, nil)
+		if removed {
+			m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(1))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		a, err := diam.SendRequest(ctx, cli, m)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = a.FindAVP(avp.ErrorMessage, 0)
+		if hooked := err == nil; hooked == removed {
+			t.Errorf("Unexpected answer with removed hook %v: %s", removed, a)
+		}
+	}
+}
@@ -132,25 +132,27 @@ import (
 // Base Protocol and Credit Control dictionaries.
 var Default *Parser
 
+// defaultDictionaries are the dictionaries of Default, in load order.
+var defaultDictionaries = []struct{ name, xml string }{
+	{"Base", baseXML},
+	{"Credit Control", creditcontrolXML},
+	{"Gx Charging Control", gxcreditcontrolXML},
+	{"Network Access Server", networkaccessserverXML},
+	{"TGPP", tgpprorfXML},
+	{"TGPP_S6a", tgpps6aXML},
+	{"TGPP_Swx", tgppswxXML},
+	{"TGPP_Sh", tgppshXML},
+	{"TGPP_s6c", tgpps6cXML},
+	{"TGPP_sgd_gdd", tgppsgdgddXML},
+}
+
 func init() {
-	var dictionaries = []struct{ name, xml string }{
-		{"Base", baseXML},
-		{"Credit Control", creditcontrolXML},
-		{"Gx Charging Control", gxcreditcontrolXML},
-		{"Network Access Server", networkaccessserverXML},
-		{"TGPP", tgpprorfXML},
-		{"TGPP_S6a", tgpps6aXML},
-		{"TGPP_Swx", tgppswxXML},
-		{"TGPP_Sh", tgppshXML},
-		{"TGPP_s6c", tgpps6cXML},
-		{"TGPP_sgd_gdd", tgppsgdgddXML},
-	}
 	var err error
 	Default, err = NewParser()
 	if err != nil {
 		panic(err)
 	}
-	for _, dict := range dictionaries {
+	for _, dict := range defaultDictionaries {
 		err = Default.Load(bytes.NewReader([]byte(dict.xml)))
 		if err != nil {
 			panic(fmt.Sprintf("Cannot load %s dictionary: %s", dict.name, err))
@@ -39,6 +39,23 @@ func NewAVP(code uint32, flags uint8, vendor uint32, data datatype.Type) *AVP {
 	return a
 }
 
+// NewDictAVP creates and initializes a new AVP with the code, vendor
+// and default flags of the AVP in the dictionary of application appid.
+// Code can be either the AVP code (int, uint32) or name (string).
+//
+// The default flags are the flags the AVP must have, as per its flag
+// rules in the dictionary.
+func NewDictAVP(dictionary *dict.Parser, appid uint32, code interface{}, data datatype.Type) (*AVP, error) {
+	dictAVP, err := dictionary.FindAVP(appid, code)
+	if err != nil {
+		return nil, err
+	}
+	if dictAVP.Data.Type == datatype.UnknownType {
+		return nil, fmt.Errorf("could not find preloaded AVP with code %v", code)
+	}
+	return NewAVP(dictAVP.Code, dictAVP.Flags(), dictAVP.VendorID, data), nil
+}
+
 // DecodeAVP decodes the bytes of a Diameter AVP.
 // It uses the given application id and dictionary for decoding the bytes.
 func DecodeAVP(data []byte, application uint32, dictionary *dict.Parser) (*AVP, error) {
@@ -51,25 +68,38 @@ func DecodeAVP(data []byte, application uint32, dictionary *dict.Parser) (*AVP,
 
 // DecodeFromBytes decodes the bytes of a Diameter AVP.
 // It uses the given application id and dictionary for decoding the bytes.
+//
+// Errors caused by the bytes of the AVP, such as an invalid length or
+// a payload that is not valid for its type, are reported by
+// ReadMessage with the Result-Code to answer them with.
 func (a *AVP) DecodeFromBytes(data []byte, application uint32, dictionary *dict.Parser) error {
 	if len(data) < 8 {
-		return fmt.Errorf("not enough data to decode AVP header: %d bytes", len(data))
+		return &avpDecodeError{InvalidAVPLenght, data,
+			fmt.Errorf("not enough data to decode AVP header: %d bytes", len(data))}
 	}
 	a.Code = binary.BigEndian.Uint32(data[0:4])
 	a.Flags = data[4]
 	a.Length = int(uint24to32(data[5:8]))
 	if len(data) < a.Length {
-		return fmt.Errorf("not enough data to decode AVP: %d != %d", len(data), a.Length)
+		return &avpDecodeError{InvalidAVPLenght, data,
+			fmt.Errorf("not enough data to decode AVP: %d != %d", len(data), a.Length)}
 	}
-	data = data[:a.Length] // this cuts padded bytes off
-	if len(data) < 8 {
-		return fmt.Errorf("not enough data to decode AVP header: %d bytes", len(data))
+	if a.Length >= 8 {
+		data = data[:a.Length] // this cuts padded bytes off
+	}
+	if len(data) < 8 || a.Length < 8 {
+		return &avpDecodeError{InvalidAVPLenght, data,
+			fmt.Errorf("not enough data to decode AVP header: %d bytes", a.Length)}
 	}
 
 	var hdrLength int
 	var payload []byte
 	// Read VendorId when required.
 	if a.Flags&avp.Vbit == avp.Vbit {
+		if len(data) < 12 {
+			return &avpDecodeError{InvalidAVPLenght, data,
+				fmt.Errorf("not enough data to decode AVP header: %d bytes", len(data))}
+		}
 		a.VendorID = binary.BigEndian.Uint32(data[8:12])
 		payload = data[12:]
 		hdrLength = 12
@@ -84,11 +114,12 @@ func (a *AVP) DecodeFromBytes(data []byte, application uint32, dictionary *dict.
 	}
 	bodyLen := a.Length - hdrLength
 	if n := len(payload); n < bodyLen {
-		return fmt.Errorf("not enough data to decode AVP: %d != %d", hdrLength, n)
+		return &avpDecodeError{InvalidAVPLenght, data,
+			fmt.Errorf("not enough data to decode AVP: %d != %d", hdrLength, n)}
 	}
 	a.Data, err = datatype.Decode(dictAVP.Data.Type, payload)
 	if err != nil {
-		return err
+		return &avpDecodeError{InvalidAVPValue, data, err}
 	}
 	// Handle grouped AVPs.
 	if a.Data.Type() == datatype.GroupedType {
@@ -100,6 +131,42 @@ func (a *AVP) DecodeFromBytes(data []byte, application uint32, dictionary *dict.
 	return nil
 }
 
+// avpDecodeError is the error of decoding the bytes of an AVP, with
+// the Result-Code to answer the message with. See RFC 6733 section
+// 7.1.5 for details.
+type avpDecodeError struct {
+	code uint32 // InvalidAVPLenght or InvalidAVPValue
+	data []byte // The offending AVP as received
+	err  error
+}
+
+func (e *avpDecodeError) Error() string {
+	return e.err.Error()
+}
+
+// failedAVP returns a copy of the offending AVP as received, with its
+// payload as datatype.Unknown, to be sent in the Failed-AVP of the
+// answer.
+func (e *avpDecodeError) failedAVP() *AVP {
+	a := &AVP{}
+	b := append([]byte(nil), e.data...)
+	if len(b) < 8 {
+		a.Data = datatype.Unknown(b)
+		a.Length = a.headerLen() + len(b)
+		return a
+	}
+	a.Code = binary.BigEndian.Uint32(b[0:4])
+	a.Flags = b[4]
+	b = b[8:]
+	if a.Flags&avp.Vbit == avp.Vbit && len(b) >= 4 {
+		a.VendorID = binary.BigEndian.Uint32(b[0:4])
+		b = b[4:]
+	}
+	a.Data = datatype.Unknown(b)
+	a.Length = a.headerLen() + len(b)
+	return a
+}
+
 // Serialize returns the byte sequence that represents this AVP.
 // It requires at least the Code, Flags and Data fields set.
 func (a *AVP) Serialize() ([]byte, error) {
@@ -148,6 +215,54 @@ func (a *AVP) headerLen() int {
 	return 8
 }
 
+// StringWithDict returns the AVP as String does, with the name of the
+// item of its Enumerated value in the dictionary d, looked up in the
+// application appID. String itself has no dictionary to find it in.
+func (a *AVP) StringWithDict(d *dict.Parser, appID uint32) string {
+	dictAVP, err := d.FindAVPWithVendor(appID, a.Code, a.VendorID)
+	if err != nil {
+		return a.String()
+	}
+	return a.stringWithDictAVP(dictAVP)
+}
+
+// stringWithDictAVP returns the AVP as String does, with the name of
+// the item of Enumerated values in dictAVP.
+func (a *AVP) stringWithDictAVP(dictAVP *dict.AVP) string {
+	n, ok := a.Data.(datatype.Enumerated)
+	if !ok {
+		return a.String()
+	}
+	item := enumItem(dictAVP, n)
+	if item == nil {
+		return a.String()
+	}
+	c := *a
+	c.Data = namedEnumerated{n, item.Name}
+	return c.String()
+}
+
+// namedEnumerated is an Enumerated printed with the name of its item.
+type namedEnumerated struct {
+	datatype.Enumerated
+	name string
+}
+
+func (n namedEnumerated) String() string {
+	return n.StringWithName(n.name)
+}
+
+// enumItem returns the item of the Enumerated value n in dictAVP, or
+// nil if it is not defined.
+func enumItem(dictAVP *dict.AVP, n datatype.Enumerated) *dict.Enum {
+	for _, item := range dictAVP.Data.Enum {
+		if item.Code == int32(n) {
+			return item
+		}
+	}
+	return nil
+}
+
 func (a *AVP) String() string {
 	return fmt.Sprintf("{Code:%d,Flags:0x%x,Length:%d,VendorId:%d,Value:%s}",
 		a.Code,
@@ -65,6 +65,8 @@ const (
 	AddressDomain                              = 898
 	AddressType                                = 899
 	AddresseeType                              = 1208
+	AgeOfLocationInformation                   = 1611
+	AlertReason                                = 1434
 	AllAPNConfigurationsIncludedIndicator      = 1428
 	AllocationRetentionPriority                = 1034
 	AlternateChargedPartyAddress               = 1280
@@ -83,6 +85,7 @@ const (
 	ApplicationServerID                        = 2101
 	ApplicationServerInformation               = 850
 	ApplicationServiceProviderIdentity         = 532
+	ApplicationServiceType                     = 2102
 	ApplicationSessionID                       = 2103
 	AssociatedPartyAddress                     = 2035
 	AssociatedURI                              = 856
@@ -140,6 +143,7 @@ const (
 	CancellationType                           = 1420
 	CarrierSelectRoutingInformation            = 2023
 	CauseCode                                  = 861
+	CellGlobalIdentity                         = 1604
 	ChangeCondition                            = 2037
 	ChangeTime                                 = 2038
 	ChargeReasonCode                           = 2118
@@ -156,6 +160,7 @@ const (
 	ClientAddress                              = 2018
 	ClientIdentity                             = 1480
 	CompleteDataListIncludedIndicator          = 1468
+	ConditionalAPNAggregateMaxBitrate          = 2818
 	ConfidentialityKey                         = 625
 	ConfigurationToken                         = 78
 	ConnectInfo                                = 77
@@ -173,7 +178,9 @@ const (
 	CreditControlFailureHandling               = 427
 	CurrencyCode                               = 425
 	CurrentLocation                            = 707
+	CurrentLocationRetrieved                   = 1610
 	CurrentTariff                              = 2056
+	DCDInformation                             = 2115
 	DRMContent                                 = 1221
 	DRMP                                       = 301
 	DSAITag                                    = 711
@@ -194,9 +201,12 @@ const (
 	DomainName                                 = 1200
 	DynamicAddressFlag                         = 2051
 	DynamicAddressFlagExtension                = 2068
+	EPSLocationInformation                     = 1496
 	EPSSubscribedQoSProfile                    = 1431
+	EUTRANCellGlobalIdentity                   = 1602
 	EUTRANVector                               = 1414
 	EarlyMediaDescription                      = 1272
+	EmergencyServices                          = 1538
 	Envelope                                   = 1266
 	EnvelopeEndTime                            = 1267
 	EnvelopeReporting                          = 1268
@@ -223,6 +233,7 @@ const (
 	ExtendedGBRUL                              = 2851
 	ExtendedMaxRequestedBWDL                   = 554
 	ExtendedMaxRequestedBWUL                   = 555
+	ExtendedeNodeBID                           = 4013
 	ExternalClient                             = 1479
 	ExternalIdentifier                         = 3111
 	FailedAVP                                  = 279
@@ -238,6 +249,7 @@ const (
 	FlowDirection                              = 1080
 	FlowInformation                            = 1058
 	FlowLabel                                  = 1057
+	FlowNumber                                 = 509
 	Flows                                      = 510
 	ForwardingPending                          = 3415
 	FramedAppletalkLink                        = 37
@@ -265,6 +277,8 @@ const (
 	GPRSSubscriptionData                       = 1467
 	GSUPoolIdentifier                          = 453
 	GSUPoolReference                           = 457
+	GeodeticInformation                        = 1609
+	GeographicalInformation                    = 1608
 	GrantedServiceUnit                         = 431
 	GuaranteedBitrateDL                        = 1025
 	GuaranteedBitrateUL                        = 1026
@@ -274,6 +288,7 @@ const (
 	HostIPAddress                              = 257
 	ICSIndicator                               = 1491
 	IMEI                                       = 1402
+	IMInformation                              = 2110
 	IMSApplicationReferenceIdentifier          = 2601
 	IMSChargingIdentifier                      = 841
 	IMSCommunicationServiceIdentifier          = 1281
@@ -310,6 +325,7 @@ const (
 	KASME                                      = 1450
 	Kc                                         = 1453
 	LCSAPN                                     = 1231
+	LCSCapabilitiesSets                        = 2404
 	LCSClientDialedByMS                        = 1233
 	LCSClientExternalID                        = 1234
 	LCSClientID                                = 1232
@@ -331,9 +347,11 @@ const (
 	LocalGWInsertedIndication                  = 2604
 	LocalSequenceNumber                        = 2063
 	LocalTimeZoneIndication                    = 718
+	LocationAreaIdentity                       = 1606
 	LocationEstimate                           = 1242
 	LocationEstimateType                       = 1243
 	LocationType                               = 1244
+	LogicalAccessID                            = 302
 	LoginIPHost                                = 14
 	LoginIPv6Host                              = 98
 	LoginLATGroup                              = 36
@@ -362,6 +380,7 @@ const (
 	MMBoxStorageRequested                      = 1248
 	MMContentType                              = 1203
 	MMEAbsentUserDiagnosticSM                  = 3313
+	MMELocationInformation                     = 1600
 	MMEName                                    = 2402
 	MMENumberforMTSMS                          = 1645
 	MMERealm                                   = 2408
@@ -383,6 +402,7 @@ const (
 	MaxRequestedBandwidthUL                    = 516
 	MaximumRetransmissionTime                  = 3330
 	MaximumUEAvailabilityTime                  = 3329
+	MediaComponentNumber                       = 518
 	MediaInitiatorFlag                         = 882
 	MediaInitiatorParty                        = 1288
 	MessageBody                                = 889
@@ -390,11 +410,15 @@ const (
 	MessageID                                  = 1210
 	MessageSize                                = 1212
 	MessageType                                = 1211
+	MonitoringEventConfigStatus                = 3142
 	MonitoringKey                              = 1066
 	MultiRoundTimeOut                          = 272
 	MultipleServicesCreditControl              = 456
 	MultipleServicesIndicator                  = 455
 	NASFilterRule                              = 400
+	NASIPAddress                               = 4
+	NASIPv6Address                             = 95
+	NASIdentifier                              = 32
 	NASPort                                    = 5
 	NASPortID                                  = 87
 	NASPortType                                = 61
@@ -408,6 +432,7 @@ const (
 	NextTariff                                 = 2057
 	NodeFunctionality                          = 862
 	NodeID                                     = 2064
+	NodeType                                   = 3153
 	Non3GPPIPAccess                            = 1501
 	Non3GPPIPAccessAPN                         = 1502
 	Non3GPPUserData                            = 1500
@@ -437,6 +462,7 @@ const (
 	OnlineChargingFlag                         = 2303
 	OperatorDeterminedBarring                  = 1425
 	OptionalCapability                         = 605
+	OriginAAAProtocol                          = 408
 	OriginHost                                 = 264
 	OriginRealm                                = 296
 	OriginStateID                              = 278
@@ -472,6 +498,7 @@ const (
 	ParticipantGroup                           = 1260
 	ParticipantsInvolved                       = 887
 	PasswordRetry                              = 75
+	PhysicalAccessID                           = 313
 	PoCChangeCondition                         = 1261
 	PoCChangeTime                              = 1262
 	PoCControllingAddress                      = 858
@@ -492,6 +519,7 @@ const (
 	PreemptionVulnerability                    = 1048
 	PreferredAoCCurrency                       = 2315
 	PrepagingSupported                         = 717
+	PresenceReportingAreaElementsList          = 2820
 	PresenceReportingAreaIdentifier            = 2821
 	PresenceReportingAreaInformation           = 2822
 	PresenceReportingAreaStatus                = 2823
@@ -568,9 +596,12 @@ const (
 	RouteHeaderReceived                        = 3403
 	RouteHeaderTransmitted                     = 3404
 	RouteRecord                                = 282
+	RoutingAreaIdentity                        = 1605
 	RuleActivationTime                         = 1043
 	RuleDeactivationTime                       = 1044
 	SCAddress                                  = 3300
+	SCEFID                                     = 3125
+	SCEFReferenceID                            = 3124
 	SDPAnswerTimestamp                         = 1275
 	SDPMediaComponent                          = 843
 	SDPMediaDescription                        = 845
@@ -581,6 +612,7 @@ const (
 	SDPType                                    = 2036
 	SGSNAbsentUserDiagnosticSM                 = 3315
 	SGSNAddress                                = 1228
+	SGSNLocationInformation                    = 1601
 	SGSNName                                   = 2409
 	SGSNNumber                                 = 1489
 	SGSNRealm                                  = 2410
@@ -653,8 +685,10 @@ const (
 	ServerAssignmentType                       = 614
 	ServerCapabilities                         = 603
 	ServerName                                 = 602
+	ServiceAreaIdentity                        = 1607
 	ServiceContextID                           = 461
 	ServiceDataContainer                       = 2040
+	ServiceGenericInformation                  = 1256
 	ServiceID                                  = 855
 	ServiceIdentifier                          = 439
 	ServiceIndication                          = 704
@@ -663,6 +697,9 @@ const (
 	ServiceParameterInfo                       = 440
 	ServiceParameterType                       = 441
 	ServiceParameterValue                      = 442
+	ServiceReport                              = 3152
+	ServiceResult                              = 3146
+	ServiceResultCode                          = 3147
 	ServiceSelection                           = 493
 	ServiceSpecificData                        = 863
 	ServiceSpecificInfo                        = 1249
@@ -684,6 +721,7 @@ const (
 	SponsorIdentity                            = 531
 	StartTime                                  = 2041
 	StartofCharging                            = 3419
+	State                                      = 24
 	StatusASCode                               = 2702
 	StopTime                                   = 2042
 	SubmissionTime                             = 1202
@@ -705,6 +743,8 @@ const (
 	TFRFlags                                   = 3302
 	TFTFilter                                  = 1012
 	TFTPacketFilterInformation                 = 1013
+	TGPP2BSID                                  = 9010
+	TGPP2MEID                                  = 1471
 	TGPPAAAServerName                          = 318
 	TGPPChargingCharacteristics                = 13
 	TGPPChargingID                             = 2
@@ -756,6 +796,7 @@ const (
 	TraceInterfaceList                         = 1464
 	TraceNETypeList                            = 1463
 	TraceReference                             = 1459
+	TrackingAreaIdentity                       = 1603
 	TrafficDataVolumes                         = 2046
 	TranscoderInsertedIndication               = 2605
 	TransitIOIList                             = 2701
@@ -74,6 +74,31 @@ func TestNewAVP(t *testing.T) {
 	t.Log(a)
 }
 
+func TestNewDictAVP(t *testing.T) {
+	for _, tc := range []struct {
+		code   interface{}
+		flags  uint8
+		vendor uint32
+	}{
+		{"Origin-Host", avp.Mbit, 0},
+		{avp.ErrorMessage, 0, 0},
+		{"Charging-Rule-Name", avp.Mbit | avp.Vbit, 10415},
+		{"Usage-Monitoring-Information", avp.Vbit, 10415},
+	} {
+		a, err := diam.NewDictAVP(dict.Default, 16777238, tc.code, datatype.UTF8String("x"))
+		if err != nil {
+			t.Errorf("%v: %v", tc.code, err)
+			continue
+		}
+		if a.Flags != tc.flags || a.VendorID != tc.vendor {
+			t.Errorf("%v: unexpected AVP %s", tc.code, a)
+		}
+	}
+	if _, err := diam.NewDictAVP(dict.Default, 0, uint32(99999), datatype.Unknown("x")); err == nil {
+		t.Error("Unexpected AVP not in the dictionary")
+	}
+}
+
 func TestDecodeAVP(t *testing.T) {
 	a, err := diam.DecodeAVP(testAVP[0], 1, dict.Default)
 	if err != nil {
@@ -80,6 +80,20 @@ func (olr *OCOLR) String() string {
 		olr.OcSequenceNumber, olr.OcReportType, redPct, valDur)
 }
 
+func (l *Load) String() string {
+	loadType, loadValue, sourceID := "nil", "nil", "nil"
+	if l.LoadType != nil {
+		loadType = l.LoadType.String()
+	}
+	if l.LoadValue != nil {
+		loadValue = l.LoadValue.String()
+	}
+	if l.SourceID != nil {
+		sourceID = l.SourceID.String()
+	}
+	return fmt.Sprintf("LoadType: %v, LoadValue: %v, SourceID: %v", loadType, loadValue, sourceID)
+}
+
 func (info *ProxyInfo) String() string {
 	return fmt.Sprintf("ProxyHost: %s, ProxyState: %s", info.ProxyHost.String(), info.ProxyState.String())
 }
@@ -155,6 +169,23 @@ func (olr *OCOLR) Serialize() *diam.GroupedAVP {
 	return &a
 }
 
+// encode Load struct to grouped AVP
+func (l *Load) Serialize() *diam.GroupedAVP {
+	a := diam.GroupedAVP{
+		AVP: []*diam.AVP{},
+	}
+	if l.LoadType != nil {
+		a.AVP = append(a.AVP, diam.NewAVP(avp.LoadType, 0, 0, *l.LoadType))
+	}
+	if l.LoadValue != nil {
+		a.AVP = append(a.AVP, diam.NewAVP(avp.LoadValue, 0, 0, *l.LoadValue))
+	}
+	if l.SourceID != nil {
+		a.AVP = append(a.AVP, diam.NewAVP(avp.SourceID, 0, 0, *l.SourceID))
+	}
+	return &a
+}
+
 // Encode Experimental-Result struct to grouped AVP
 func (er *ExperimentalResult) Serialize() *diam.GroupedAVP {
 	return &diam.GroupedAVP{
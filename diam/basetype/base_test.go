@@ -93,6 +93,26 @@ func TestOCOLRTWithoutOptionalFieldoDiam(t *testing.T) {
 	assert.Equal(t, m2.Serialize(), m1.Serialize())
 }
 
+func TestLoadToDiam(t *testing.T) {
+	loadType := datatype.Enumerated(1)
+	value := datatype.Unsigned64(123)
+	source := datatype.DiameterIdentity("abc")
+	load := basetype.Load{
+		LoadType:  &loadType,
+		LoadValue: &value,
+		SourceID:  &source,
+	}
+	m1 := load.Serialize()
+	m2 := diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.LoadType, 0, 0, datatype.Enumerated(1)),
+			diam.NewAVP(avp.LoadValue, 0, 0, datatype.Unsigned64(123)),
+			diam.NewAVP(avp.SourceID, 0, 0, datatype.DiameterIdentity("abc")),
+		},
+	}
+	assert.Equal(t, m2.Serialize(), m1.Serialize())
+}
+
 func TestProxyInfoToDiam(t *testing.T) {
 	info := basetype.ProxyInfo{
 		ProxyHost:  datatype.DiameterIdentity("abc"),
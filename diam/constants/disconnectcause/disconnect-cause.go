@@ -0,0 +1,26 @@
+package disconnectcause
+
+import "github.com/ctrlzy/go-diameter/v4/diam/datatype"
+
+// IETF RFC 6733 section 5.4.3
+// The Disconnect-Cause AVP (AVP Code 273) is of type Enumerated.  A
+// Diameter node MUST include this AVP in the Disconnect-Peer-Request
+// message to inform the peer of the reason for its intention to
+// shut down the transport connection.  The following values are
+// supported:
+
+const (
+	// A scheduled reboot is imminent.  A receiver of a DPR with this
+	// result code MAY attempt reconnection.
+	REBOOTING = datatype.Enumerated(0)
+	// The peer's internal resources are constrained, and it has
+	// determined that the transport connection needs to be closed.  A
+	// receiver of a DPR with this result code SHOULD NOT attempt
+	// reconnection.
+	BUSY = datatype.Enumerated(1)
+	// The peer has determined that it does not see a need for the
+	// transport connection to exist, since it does not expect any
+	// messages to be exchanged in the foreseeable future.  A receiver
+	// of a DPR with this result code SHOULD NOT attempt reconnection.
+	DO_NOT_WANT_TO_TALK_TO_YOU = datatype.Enumerated(2)
+)
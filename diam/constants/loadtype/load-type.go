@@ -0,0 +1,15 @@
+package loadtype
+
+import "github.com/ctrlzy/go-diameter/v4/diam/datatype"
+
+// IETF RFC 8583 section 7.2
+// The Load-Type AVP (AVP Code 651) is of type Enumerated. It is used to
+// convey the type of Diameter node that sent the load information. The
+// following values are supported:
+
+const (
+	// The load report is for a host.
+	HOST = datatype.Enumerated(0)
+	// The load report is for a peer.
+	PEER = datatype.Enumerated(1)
+)
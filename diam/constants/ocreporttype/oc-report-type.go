@@ -0,0 +1,20 @@
+package ocreporttype
+
+import "github.com/ctrlzy/go-diameter/v4/diam/datatype"
+
+// IETF RFC 7683 section 7.6
+// The OC-Report-Type AVP (AVP Code 626) is of type Enumerated. The
+// value of the AVP describes what the overload report concerns. The
+// following values are supported:
+
+const (
+	// An overload report of type HOST_REPORT is a host report. A
+	// reacting node applies it to requests sent to the host that
+	// identified itself in the Origin-Host of the answer.
+	HOST_REPORT = datatype.Enumerated(0)
+	// An overload report of type REALM_REPORT is a realm report. A
+	// reacting node applies it to requests sent to the realm that
+	// identified itself in the Origin-Realm of the answer, without
+	// Destination-Host.
+	REALM_REPORT = datatype.Enumerated(1)
+)
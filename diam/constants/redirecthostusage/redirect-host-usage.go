@@ -0,0 +1,38 @@
+package redirecthostusage
+
+import "github.com/ctrlzy/go-diameter/v4/diam/datatype"
+
+// IETF RFC 6733 section 6.13
+// The Redirect-Host-Usage AVP (AVP Code 261) is of type Enumerated.
+// This AVP MAY be present in answer messages whose 'E' bit is set and
+// the Result-Code AVP is set to DIAMETER_REDIRECT_INDICATION. It tells
+// the receiver which requests may be sent to the Redirect-Host without
+// going through the redirect agent again, for as long as the
+// Redirect-Max-Cache-Time. The following values are supported:
+
+const (
+	// The redirect information MUST NOT be cached. This is the
+	// default when the AVP is absent.
+	DONT_CACHE = datatype.Enumerated(0)
+	// All messages within the same session, as defined by the same
+	// value of the Session-ID AVP, MAY be sent to the host specified
+	// in the Redirect-Host AVP.
+	ALL_SESSION = datatype.Enumerated(1)
+	// All messages destined for the realm requested MAY be sent to
+	// the host specified in the Redirect-Host AVP.
+	ALL_REALM = datatype.Enumerated(2)
+	// All messages for the application requested to the realm
+	// specified MAY be sent to the host specified in the
+	// Redirect-Host AVP.
+	REALM_AND_APPLICATION = datatype.Enumerated(3)
+	// All messages for the application requested MAY be sent to the
+	// host specified in the Redirect-Host AVP.
+	ALL_APPLICATION = datatype.Enumerated(4)
+	// All messages that would be sent to the host that generated the
+	// Redirect-Host MAY be sent to the host specified in the
+	// Redirect-Host AVP.
+	ALL_HOST = datatype.Enumerated(5)
+	// All messages for the user requested MAY be sent to the host
+	// specified in the Redirect-Host AVP.
+	ALL_USER = datatype.Enumerated(6)
+)
@@ -38,7 +38,15 @@ func (n Enumerated) Type() TypeID {
 	return EnumeratedType
 }
 
-// String implements the Type interface.
+// String implements the Type interface. It only has the value: the
+// dictionary name of the item is printed by StringWithName, as used by
+// diam.Message.String, diam.Message.PrettyDump and diam.AVP.StringWithDict.
 func (n Enumerated) String() string {
 	return fmt.Sprintf("Enumerated{%d}", n)
 }
+
+// StringWithName returns the value with a symbolic name for it, such
+// as the name of its item in the dictionary.
+func (n Enumerated) StringWithName(name string) string {
+	return fmt.Sprintf("Enumerated{%s(%d)}", name, n)
+}
@@ -123,9 +123,10 @@ func (s *Server) StartTLS() {
 	go s.Config.Serve(s.Listener)
 }
 
-// Close shuts down the server.
+// Close shuts down the server, closing its listener and connections.
 func (s *Server) Close() {
 	s.Listener.Close()
+	s.Config.Close()
 }
 
 // localhostCert is a PEM-encoded TLS cert with SAN IPs
@@ -0,0 +1,365 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Command Code Format parser.  Part of go-diameter.
+
+package dict
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// LoadCCF parses the definitions in r with ParseCCF into the application
+// app, and loads it. May be used multiple times.
+func (p *Parser) LoadCCF(r io.Reader, app *App) error {
+	if err := ParseCCF(r, app); err != nil {
+		return err
+	}
+	return p.loadFile(&File{App: []*App{app}})
+}
+
+// ParseCCF parses commands and Grouped AVPs defined in the Command Code
+// Format (CCF) of RFC 6733 section 3.2, and AVPs of AVP flag rules tables,
+// as found in RFCs and 3GPP specifications, and adds them to app:
+//
+//	<ULR> ::= < Diameter Header: 316, REQ, PXY, 16777251 >
+//	          < Session-Id >
+//	          { Auth-Session-State }
+//	         *[ Supported-Features ]
+//
+//	Subscription-Data ::= <AVP header: 1400 10415>
+//	          [ Subscriber-Status ]
+//
+//	Subscription-Data | 1400 | 7.3.2 | Grouped | M, V | | | | No
+//
+// Requests and answers are named after their command, e.g.
+// Update-Location-Request or ULR, and added to the same Command.
+//
+// Columns of AVP tables are separated by '|' or tabs, and hold the AVP
+// name, code, an optional section, the data type and the flag rules:
+// must, may, should not (optional), must not and may encrypt. Vendor
+// specific AVPs get the vendor of the application, unless their
+// Grouped definition has one. Lines that are neither definitions nor
+// AVPs, such as table headers and text, are ignored.
+func ParseCCF(r io.Reader, app *App) error {
+	c := &ccfParser{app: app, avps: make(map[string]*AVP)}
+	for _, a := range app.AVP {
+		c.avps[a.Name] = a
+	}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		if err := c.parseLine(s.Text()); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return c.flush()
+}
+
+// ccfParser holds the state of ParseCCF.
+type ccfParser struct {
+	app  *App
+	avps map[string]*AVP // AVPs of app by name
+	def  string          // Name of the current definition
+	body string          // Current definition after "::="
+}
+
+var (
+	// ccfDef matches the start of a definition: <name> ::= or name ::=
+	ccfDef = regexp.MustCompile(`^\s*<?\s*([A-Za-z][\w-]*)\s*>?\s*::=(.*)$`)
+
+	// ccfElement matches elements of definitions: header, fixed,
+	// required and optional AVPs, with their qualifiers.
+	ccfElement = regexp.MustCompile(`(?:(\d*)\s*\*\s*(\d*))?\s*([<{\[])\s*([^>}\]]*?)\s*[>}\]]`)
+
+	// ccfContinue matches lines that continue a definition.
+	ccfContinue = regexp.MustCompile(`^\s*(\d*\s*\*\s*\d*)?\s*[<{\[]`)
+
+	avpName = regexp.MustCompile(`^[A-Za-z][\w-]*$`)
+)
+
+func (c *ccfParser) parseLine(line string) error {
+	if m := ccfDef.FindStringSubmatch(line); m != nil {
+		if err := c.flush(); err != nil {
+			return err
+		}
+		c.def, c.body = m[1], m[2]
+		return nil
+	}
+	if c.def != "" && ccfContinue.MatchString(line) {
+		c.body += " " + line
+		return nil
+	}
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	// Other lines end definitions.
+	if err := c.flush(); err != nil {
+		return err
+	}
+	return c.parseRow(line)
+}
+
+// flush adds the current definition to the application.
+func (c *ccfParser) flush() error {
+	if c.def == "" {
+		return nil
+	}
+	name, body := c.def, c.body
+	c.def, c.body = "", ""
+	elems := ccfElement.FindAllStringSubmatch(body, -1)
+	if len(elems) == 0 || elems[0][3] != "<" || !strings.Contains(elems[0][4], ":") {
+		return fmt.Errorf("%s: missing header", name)
+	}
+	var rules []*Rule
+	for _, e := range elems[1:] {
+		rule, err := ccfRule(e)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		rules = append(rules, rule)
+	}
+	header := strings.SplitN(elems[0][4], ":", 2)
+	switch strings.ToLower(strings.Join(strings.Fields(header[0]), " ")) {
+	case "diameter header":
+		return c.addCommand(name, header[1], rules)
+	case "avp header":
+		return c.addGrouped(name, header[1], rules)
+	}
+	return fmt.Errorf("%s: unsupported header %q", name, elems[0][4])
+}
+
+// ccfRule returns the rule of the element e, matched by ccfElement.
+func ccfRule(e []string) (*Rule, error) {
+	name := e[4]
+	if !avpName.MatchString(name) {
+		return nil, fmt.Errorf("invalid AVP name %q", name)
+	}
+	rule := &Rule{AVP: name, Required: e[3] != "[", Max: 1}
+	if !strings.Contains(e[0], "*") {
+		return rule, nil
+	}
+	// Qualifiers: min defaults to 0 for fixed and optional AVPs, and 1
+	// for required AVPs, max defaults to unbounded.
+	rule.Max = 0
+	if e[1] != "" {
+		rule.Min, _ = strconv.Atoi(e[1])
+	}
+	if e[2] != "" {
+		rule.Max, _ = strconv.Atoi(e[2])
+	}
+	switch e[3] {
+	case "<":
+		rule.Required = rule.Min > 0
+	case "[":
+		rule.Required = false
+	}
+	return rule, nil
+}
+
+func (c *ccfParser) addCommand(name, header string, rules []*Rule) error {
+	fields := strings.Split(header, ",")
+	code, err := strconv.ParseUint(strings.TrimSpace(fields[0]), 10, 32)
+	if err != nil {
+		return fmt.Errorf("%s: invalid command code %q", name, fields[0])
+	}
+	request := false
+	for _, f := range fields[1:] {
+		f = strings.TrimSpace(f)
+		switch strings.ToUpper(f) {
+		case "REQ":
+			request = true
+		case "PXY", "ERR", "":
+		default:
+			id, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return fmt.Errorf("%s: invalid header field %q", name, f)
+			}
+			if uint32(id) != c.app.ID {
+				return fmt.Errorf("%s: application %d is not %d", name, id, c.app.ID)
+			}
+		}
+	}
+	var cmd *Command
+	for _, cc := range c.app.Command {
+		if cc.Code == uint32(code) {
+			cmd = cc
+		}
+	}
+	if cmd == nil {
+		cmd = &Command{Code: uint32(code)}
+		cmd.Name, cmd.Short = commandNames(name)
+		c.app.Command = append(c.app.Command, cmd)
+	}
+	if request {
+		cmd.Request.Rule = rules
+	} else {
+		cmd.Answer.Rule = rules
+	}
+	return nil
+}
+
+// commandNames returns the name and short name of the command of the
+// request or answer named name: Update-Location-Request or ULR are
+// Update-Location and UL. Short names are used as names.
+func commandNames(name string) (string, string) {
+	for _, suffix := range []string{"-Request", "-Answer"} {
+		if strings.HasSuffix(name, suffix) {
+			name = strings.TrimSuffix(name, suffix)
+			var short string
+			for _, word := range strings.Split(name, "-") {
+				if word != "" {
+					short += strings.ToUpper(word[:1])
+				}
+			}
+			return name, short
+		}
+	}
+	short := name
+	if len(name) > 1 && (strings.HasSuffix(name, "R") || strings.HasSuffix(name, "A")) {
+		short = name[:len(name)-1]
+	}
+	return short, short
+}
+
+func (c *ccfParser) addGrouped(name, header string, rules []*Rule) error {
+	fields := strings.FieldsFunc(header, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(fields) == 0 {
+		return fmt.Errorf("%s: missing AVP code", name)
+	}
+	code, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("%s: invalid AVP code %q", name, fields[0])
+	}
+	var vendor uint64
+	if len(fields) > 1 {
+		if vendor, err = strconv.ParseUint(fields[1], 10, 32); err != nil {
+			return fmt.Errorf("%s: invalid vendor %q", name, fields[1])
+		}
+	}
+	a := c.avp(name)
+	if a.Code != 0 && a.Code != uint32(code) {
+		return fmt.Errorf("%s: AVP code %d is not %d", name, code, a.Code)
+	}
+	a.Code = uint32(code)
+	if vendor != 0 {
+		a.VendorID = uint32(vendor)
+	}
+	a.Data.TypeName = "Grouped"
+	a.Data.Rule = rules
+	return nil
+}
+
+// avp returns the AVP of the application named name, adding it if needed.
+func (c *ccfParser) avp(name string) *AVP {
+	a, ok := c.avps[name]
+	if !ok {
+		a = &AVP{Name: name}
+		c.avps[name] = a
+		c.app.AVP = append(c.app.AVP, a)
+	}
+	return a
+}
+
+// ccfTypes are the abbreviated names of data types used in the AVP
+// tables of RFC 6733.
+var ccfTypes = map[string]string{
+	"DiamIdent": "DiameterIdentity",
+	"DiamURI":   "DiameterURI",
+}
+
+// ccfType returns the data type named s, or an empty string.
+func ccfType(s string) string {
+	if t, ok := ccfTypes[s]; ok {
+		return t
+	}
+	if _, ok := datatype.Available[s]; ok {
+		return s
+	}
+	return ""
+}
+
+// parseRow adds the AVP of a row of an AVP flag rules table. Lines that
+// are not AVPs are ignored.
+func (c *ccfParser) parseRow(line string) error {
+	line = strings.Trim(strings.TrimSpace(strings.ReplaceAll(line, "\t", "|")), "|")
+	cells := strings.Split(line, "|")
+	// The name, code, section and type may share the first cells.
+	var head []string
+	typ, i := "", 0
+	for ; i < len(cells) && typ == ""; i++ {
+		head = append(head, strings.Fields(cells[i])...)
+		if len(head) > 2 {
+			typ = ccfType(head[len(head)-1])
+		}
+	}
+	if typ == "" || len(head) < 3 || !avpName.MatchString(head[0]) {
+		return nil
+	}
+	code, err := strconv.ParseUint(head[1], 10, 32)
+	if err != nil {
+		return nil
+	}
+	flags := cells[i:]
+	for j := range flags {
+		flags[j] = strings.TrimSpace(flags[j])
+	}
+	var must, may, mustNot, encrypt string
+	switch len(flags) {
+	case 0:
+	case 1:
+		must = flags[0]
+	case 2:
+		must, may = flags[0], flags[1]
+	case 3:
+		must, may, mustNot = flags[0], flags[1], flags[2]
+	case 4:
+		must, may, mustNot, encrypt = flags[0], flags[1], flags[2], flags[3]
+	default: // With a "should not" column.
+		must, may, mustNot, encrypt = flags[0], flags[1], flags[3], flags[4]
+	}
+	a := c.avp(head[0])
+	if a.Code != 0 && a.Code != uint32(code) {
+		return fmt.Errorf("%s: AVP code %d is not %d", a.Name, code, a.Code)
+	}
+	a.Code = uint32(code)
+	a.Data.TypeName = typ
+	a.Must = ccfFlags(must)
+	a.May = ccfFlags(may)
+	a.MustNot = ccfFlags(mustNot)
+	switch strings.ToUpper(encrypt) {
+	case "Y", "YES":
+		a.MayEncrypt = "Y"
+	case "N", "NO":
+		a.MayEncrypt = "N"
+	}
+	if parseFlags(must)&avp.Vbit != 0 && a.VendorID == 0 {
+		if len(c.app.Vendor) == 0 {
+			return fmt.Errorf("%s: vendor specific AVP in application %d without vendor", a.Name, c.app.ID)
+		}
+		a.VendorID = c.app.Vendor[0].ID
+	}
+	return nil
+}
+
+// ccfFlags returns the flags of a table cell in the dictionary format.
+func ccfFlags(s string) string {
+	flags := parseFlags(s)
+	if flags == 0 {
+		return ""
+	}
+	return FlagsString(flags)
+}
@@ -0,0 +1,127 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dict_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+var testCCF = `
+7.2.3	Update-Location-Request (ULR) Command
+
+< Update-Location-Request> ::=	< Diameter Header: 316, REQ, PXY, 16777251 >
+			< Session-Id >
+			{ Auth-Session-State }
+			{ Origin-Host }
+			[ Destination-Host ]
+			*[ Supported-Features ]
+			1*2{ Test-Host }
+			*[ AVP ]
+
+<ULA> ::= < Diameter Header: 316, PXY, 16777251 >
+	< Session-Id >
+	[ Result-Code ]
+	[ Subscription-Data ]
+
+Subscription-Data ::= <AVP header: 1400 10415>
+	[ Subscriber-Status ]
+	*5[ Test-Host ]
+
+Table 7.3.1/1: S6a/S6d specific Diameter AVPs
+Attribute Name	AVP Code	Clause defined	Value Type	Must	May	Should not	Must not	May Encr.
+Subscription-Data	1400	7.3.2	Grouped	M, V				No
+Subscriber-Status | 1424 | 7.3.29 | Enumerated | M, V | | | | No
+  Test-Host         9999  9.9.9   DiamIdent  | M  | P   |    |  V  | Y  |
+`
+
+func TestLoadCCF(t *testing.T) {
+	p, err := dict.NewParser("./testdata/base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &dict.App{
+		ID:     16777251,
+		Type:   "auth",
+		Name:   "Test S6a",
+		Vendor: []*dict.Vendor{{ID: 10415, Name: "TGPP"}},
+	}
+	if err = p.LoadCCF(strings.NewReader(testCCF), app); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := p.FindCommand(16777251, 316)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "Update-Location" || cmd.Short != "UL" {
+		t.Errorf("Unexpected command %s", cmd)
+	}
+	wantULR := []dict.Rule{
+		{AVP: "Session-Id", Required: true, Max: 1},
+		{AVP: "Auth-Session-State", Required: true, Max: 1},
+		{AVP: "Origin-Host", Required: true, Max: 1},
+		{AVP: "Destination-Host", Max: 1},
+		{AVP: "Supported-Features"},
+		{AVP: "Test-Host", Required: true, Min: 1, Max: 2},
+		{AVP: "AVP"},
+	}
+	testRules(t, "ULR", cmd.Request.Rule, wantULR)
+	testRules(t, "ULA", cmd.Answer.Rule, []dict.Rule{
+		{AVP: "Session-Id", Required: true, Max: 1},
+		{AVP: "Result-Code", Max: 1},
+		{AVP: "Subscription-Data", Max: 1},
+	})
+	for _, want := range []*dict.AVP{
+		{Name: "Subscription-Data", Code: 1400, Must: "V,M", MayEncrypt: "N", VendorID: 10415,
+			Data: dict.Data{TypeName: "Grouped"}},
+		{Name: "Subscriber-Status", Code: 1424, Must: "V,M", MayEncrypt: "N", VendorID: 10415,
+			Data: dict.Data{TypeName: "Enumerated"}},
+		{Name: "Test-Host", Code: 9999, Must: "M", May: "P", MustNot: "V", MayEncrypt: "Y",
+			Data: dict.Data{TypeName: "DiameterIdentity"}},
+	} {
+		have, err := p.FindAVP(16777251, want.Code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have.Name != want.Name || have.Must != want.Must || have.May != want.May ||
+			have.MustNot != want.MustNot || have.MayEncrypt != want.MayEncrypt ||
+			have.VendorID != want.VendorID || have.Data.TypeName != want.Data.TypeName {
+			t.Errorf("Unexpected AVP.\nWant %#v\nHave %#v", want, have)
+		}
+	}
+	sd, _ := p.FindAVP(16777251, "Subscription-Data")
+	testRules(t, "Subscription-Data", sd.Data.Rule, []dict.Rule{
+		{AVP: "Subscriber-Status", Max: 1},
+		{AVP: "Test-Host", Max: 5},
+	})
+}
+
+func TestParseCCFErrors(t *testing.T) {
+	for _, tc := range []struct{ name, ccf string }{
+		{"missing header", "<ULR> ::= < Session-Id >"},
+		{"invalid code", "<ULR> ::= < Diameter Header: UL, REQ >"},
+		{"other application", "<ULR> ::= < Diameter Header: 316, REQ, 4 >"},
+		{"invalid AVP", "<ULR> ::= < Diameter Header: 316, REQ >\n{ Origin Host }"},
+		{"no vendor", "Subscription-Data | 1400 | 7.3.2 | Grouped | M, V | | | | No"},
+	} {
+		if err := dict.ParseCCF(strings.NewReader(tc.ccf), &dict.App{ID: 16777251}); err == nil {
+			t.Errorf("%s: no error", tc.name)
+		}
+	}
+}
+
+func testRules(t *testing.T, name string, have []*dict.Rule, want []dict.Rule) {
+	t.Helper()
+	var rules []dict.Rule
+	for _, rule := range have {
+		rules = append(rules, *rule)
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("Unexpected rules of %s.\nWant %+v\nHave %+v", name, want, rules)
+	}
+}
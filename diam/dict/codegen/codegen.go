@@ -0,0 +1,406 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package codegen generates Go code from diameter dictionaries.
+//
+// For the applications of a dictionary file it generates typed structs
+// for the requests and answers of each command and for each Grouped AVP,
+// to be used with Message.Marshal and Message.Unmarshal, the constants of
+// the AVP codes, and a package of constants for the items of each
+// Enumerated AVP, in the layout of the diam/avp and diam/constants
+// packages.
+//
+// The cardinality of the fields of the structs follows the dictionary
+// rules: AVPs that may appear more than once (max is not 1) are slices,
+// optional AVPs are pointers and required AVPs are values.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+const header = "// Code generated by go-diameter codegen. DO NOT EDIT.\n\n"
+
+const datatypeImport = `import "github.com/ctrlzy/go-diameter/v4/diam/datatype"`
+
+// Generator generates Go code from dictionary files.
+type Generator struct {
+	// Parser resolves the AVPs referenced by the rules of commands and
+	// Grouped AVPs. It must have the dictionary files to generate loaded,
+	// along with the dictionaries they depend on.
+	Parser *dict.Parser
+
+	// Package is the name of the package of the generated structs.
+	Package string
+}
+
+// New returns a Generator of structs of package pkg, that resolves AVPs
+// with the dictionary Parser p.
+func New(p *dict.Parser, pkg string) *Generator {
+	return &Generator{Parser: p, Package: pkg}
+}
+
+// Generate returns the Go source files generated for the applications of
+// f, indexed by their path relative to the output directory:
+// the structs in messages.go, the AVP codes in avp/codes.go and the
+// Enumerated items in constants/<package>/<avp-name>.go.
+func (g *Generator) Generate(f *dict.File) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	src, err := g.Structs(f)
+	if err != nil {
+		return nil, err
+	}
+	files["messages.go"] = src
+	if files["avp/codes.go"], err = g.Codes(f); err != nil {
+		return nil, err
+	}
+	enums, err := g.Enums(f)
+	if err != nil {
+		return nil, err
+	}
+	for name, src := range enums {
+		files[name] = src
+	}
+	return files, nil
+}
+
+// Structs returns the source of the structs of the requests and answers
+// of the commands of f, and of the Grouped AVPs of f and those referenced
+// by its commands.
+func (g *Generator) Structs(f *dict.File) ([]byte, error) {
+	if !token.IsIdentifier(g.Package) {
+		return nil, fmt.Errorf("invalid package name %q", g.Package)
+	}
+	s := &structGen{
+		g:       g,
+		types:   make(map[string]bool),
+		grouped: make(map[string]string),
+	}
+	// Command names take precedence over the names of Grouped AVPs.
+	// Commands defined by several applications have the application ID
+	// appended to their names, but for the first one: CCR, CCR16777238.
+	suffix := make(map[*dict.Command]string)
+	for _, app := range f.App {
+		for _, cmd := range app.Command {
+			name := goName(cmd.Short)
+			if s.types[name+"R"] || s.types[name+"A"] {
+				suffix[cmd] = strconv.FormatUint(uint64(app.ID), 10)
+			}
+			s.types[name+"R"+suffix[cmd]] = true
+			s.types[name+"A"+suffix[cmd]] = true
+		}
+	}
+	for _, app := range f.App {
+		for _, cmd := range app.Command {
+			if err := s.command(app, cmd, suffix[cmd]); err != nil {
+				return nil, err
+			}
+		}
+		for _, a := range app.AVP {
+			if a.Data.TypeName == "Grouped" {
+				s.groupedType(app.ID, a)
+			}
+		}
+	}
+	for len(s.queue) > 0 {
+		ref := s.queue[0]
+		s.queue = s.queue[1:]
+		if err := s.groupedStruct(ref); err != nil {
+			return nil, err
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%spackage %s\n\n", header, g.Package)
+	if s.datatype {
+		fmt.Fprintf(&b, "%s\n\n", datatypeImport)
+	}
+	b.Write(s.buf.Bytes())
+	return source(&b)
+}
+
+// structGen holds the state of the generation of structs.
+type structGen struct {
+	g        *Generator
+	buf      bytes.Buffer
+	types    map[string]bool   // Type names in use
+	grouped  map[string]string // Type names of Grouped AVPs, by AVP name
+	queue    []groupedRef      // Grouped AVPs to generate
+	datatype bool              // Whether the datatype package is used
+}
+
+// groupedRef is a Grouped AVP referenced in the application appID.
+type groupedRef struct {
+	appID uint32
+	avp   *dict.AVP
+	typ   string
+}
+
+func (s *structGen) command(app *dict.App, cmd *dict.Command, suffix string) error {
+	for _, m := range []struct {
+		suffix, kind string
+		rules        []*dict.Rule
+	}{
+		{"R", "Request", cmd.Request.Rule},
+		{"A", "Answer", cmd.Answer.Rule},
+	} {
+		typ := goName(cmd.Short) + m.suffix + suffix
+		fmt.Fprintf(&s.buf, "// %s is the %s-%s (code %d) of application %d.\n",
+			typ, cmd.Name, m.kind, cmd.Code, app.ID)
+		if err := s.fields(app.ID, typ, m.rules); err != nil {
+			return fmt.Errorf("%s-%s: %v", cmd.Name, m.kind, err)
+		}
+	}
+	return nil
+}
+
+func (s *structGen) groupedStruct(ref groupedRef) error {
+	a := ref.avp
+	fmt.Fprintf(&s.buf, "// %s is the %s AVP (code %d", ref.typ, a.Name, a.Code)
+	if a.VendorID != 0 {
+		fmt.Fprintf(&s.buf, ", vendor %d", a.VendorID)
+	}
+	fmt.Fprintf(&s.buf, ").\n")
+	if err := s.fields(ref.appID, ref.typ, a.Data.Rule); err != nil {
+		return fmt.Errorf("%s AVP: %v", a.Name, err)
+	}
+	return nil
+}
+
+// fields writes the struct typ with a field per rule. The "AVP" rule,
+// that allows any AVP, has no field, and AVPs missing in the dictionary
+// are left as comments.
+func (s *structGen) fields(appID uint32, typ string, rules []*dict.Rule) error {
+	fmt.Fprintf(&s.buf, "type %s struct {\n", typ)
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.AVP == "" || rule.AVP == "AVP" || seen[rule.AVP] {
+			continue
+		}
+		seen[rule.AVP] = true
+		a, err := s.g.findAVP(appID, rule.AVP)
+		if err != nil {
+			fmt.Fprintf(&s.buf, "\t// %s: %v\n", rule.AVP, err)
+			continue
+		}
+		ftyp, err := s.fieldType(appID, a)
+		if err != nil {
+			return err
+		}
+		required := rule.Required || rule.Min > 0
+		switch {
+		case rule.Max != 1:
+			ftyp = "[]" + ftyp
+		case !required || ftyp == typ:
+			// Pointers also break the recursion of Grouped AVPs
+			// that contain themselves.
+			ftyp = "*" + ftyp
+		}
+		tag := rule.AVP
+		if !required {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&s.buf, "\t%s %s `avp:%q`\n", goName(rule.AVP), ftyp, tag)
+	}
+	fmt.Fprintf(&s.buf, "}\n\n")
+	return nil
+}
+
+// findAVP returns the AVP named name as seen from the application appID.
+// AVPs that are not found in the application hierarchy are looked up in
+// the other applications of the Parser, in order.
+func (g *Generator) findAVP(appID uint32, name string) (*dict.AVP, error) {
+	a, err := g.Parser.FindAVP(appID, name)
+	if err == nil {
+		return a, nil
+	}
+	for _, app := range g.Parser.Apps() {
+		for _, a := range app.AVP {
+			if a.Name == name {
+				return a, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("undefined AVP")
+}
+
+func (s *structGen) fieldType(appID uint32, a *dict.AVP) (string, error) {
+	if a.Data.TypeName == "Grouped" {
+		return s.groupedType(appID, a), nil
+	}
+	if _, ok := datatype.Available[a.Data.TypeName]; !ok {
+		return "", fmt.Errorf("unsupported data type %q of AVP %s", a.Data.TypeName, a.Name)
+	}
+	s.datatype = true
+	return "datatype." + a.Data.TypeName, nil
+}
+
+// groupedType returns the type name of the Grouped AVP a, queueing it for
+// generation the first time it is referenced.
+func (s *structGen) groupedType(appID uint32, a *dict.AVP) string {
+	if typ, ok := s.grouped[a.Name]; ok {
+		return typ
+	}
+	typ := goName(a.Name)
+	for s.types[typ] {
+		typ += "AVP"
+	}
+	s.types[typ] = true
+	s.grouped[a.Name] = typ
+	s.queue = append(s.queue, groupedRef{appID, a, typ})
+	return typ
+}
+
+// Codes returns the source of package avp with the constants of the codes
+// of the AVPs of f, named as in the diam/avp package.
+func (g *Generator) Codes(f *dict.File) ([]byte, error) {
+	codes := make(map[string]uint32)
+	var names []string
+	for _, app := range f.App {
+		for _, a := range app.AVP {
+			name := goName(a.Name)
+			if _, ok := codes[name]; ok {
+				continue
+			}
+			codes[name] = a.Code
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%spackage avp\n\n// Diameter AVP codes.\nconst (\n", header)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\t%s = %d\n", name, codes[name])
+	}
+	fmt.Fprintf(&b, ")\n")
+	return source(&b)
+}
+
+// Enums returns the source of a package of constants for the items of
+// each Enumerated AVP of f, indexed by the path of the file. Packages are
+// named after the AVP, like the diam/constants packages.
+func (g *Generator) Enums(f *dict.File) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	seen := make(map[string]bool)
+	for _, app := range f.App {
+		for _, a := range app.AVP {
+			if a.Data.TypeName != "Enumerated" || len(a.Data.Enum) == 0 {
+				continue
+			}
+			pkg := packageName(a.Name)
+			if seen[pkg] {
+				continue
+			}
+			seen[pkg] = true
+			src, err := enumSource(pkg, a)
+			if err != nil {
+				return nil, err
+			}
+			files["constants/"+pkg+"/"+fileName(a.Name)+".go"] = src
+		}
+	}
+	return files, nil
+}
+
+func enumSource(pkg string, a *dict.AVP) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s// Package %s provides the values of the %s AVP (code %d).\n",
+		header, pkg, a.Name, a.Code)
+	fmt.Fprintf(&b, "package %s\n\n%s\n\nconst (\n", pkg, datatypeImport)
+	seen := make(map[string]bool)
+	for _, item := range a.Data.Enum {
+		name := enumName(item.Name)
+		if seen[name] {
+			if item.Code < 0 {
+				name = fmt.Sprintf("%s_N%d", name, -item.Code)
+			} else {
+				name = fmt.Sprintf("%s_%d", name, item.Code)
+			}
+		}
+		seen[name] = true
+		fmt.Fprintf(&b, "\t%s = datatype.Enumerated(%d)\n", name, item.Code)
+	}
+	fmt.Fprintf(&b, ")\n")
+	return source(&b)
+}
+
+// source returns the formatted Go source in b.
+func source(b *bytes.Buffer) ([]byte, error) {
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("invalid generated code: %v", err)
+	}
+	return src, nil
+}
+
+var idSuffix = regexp.MustCompile(`-Id(-|s|$)`)
+
+// goName returns the exported Go name of the dictionary name s, as in
+// the diam/avp package: Session-Id becomes SessionID.
+func goName(s string) string {
+	s = idSuffix.ReplaceAllString(s, "-ID$1")
+	var b strings.Builder
+	for _, r := range s {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	name := []rune(b.String())
+	if len(name) == 0 || !unicode.IsLetter(name[0]) {
+		return "AVP" + string(name)
+	}
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+// packageName returns the name of the package of the items of the AVP
+// named s: Auth-Session-State becomes authsessionstate.
+func packageName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	pkg := b.String()
+	if pkg == "" || !unicode.IsLetter([]rune(pkg)[0]) || token.IsKeyword(pkg) {
+		return "avp" + pkg
+	}
+	return pkg
+}
+
+// fileName returns the name of the file of the items of the AVP named s:
+// Auth-Session-State becomes auth-session-state.
+func fileName(s string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '-'
+	}, s))
+}
+
+// enumName returns the name of the constant of the Enumerated item named
+// s: NO_STATE_MAINTAINED or 3GPP-EPS become NO_STATE_MAINTAINED and
+// V3GPP_EPS.
+func enumName(s string) string {
+	words := strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	name := strings.Join(words, "_")
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		return "V" + name
+	}
+	return name
+}
@@ -0,0 +1,193 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package codegen
+
+import (
+	"bytes"
+	"encoding/xml"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+var testDict = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="16777999" type="auth" name="Test">
+		<command code="9000" short="TE" name="Test">
+			<request>
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Test-Grouped" required="false" max="1"/>
+				<rule avp="Test-Mode" required="true" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="AVP" required="false"/>
+			</request>
+			<answer>
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="false" max="1"/>
+				<rule avp="Test-Missing" required="false" max="1"/>
+			</answer>
+		</command>
+		<avp name="Test-Grouped" code="9001" must="V,M" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Test-Id" required="true" max="1"/>
+				<rule avp="Test-Grouped" required="false" max="1"/>
+				<rule avp="Test-Mode" required="false" min="1" max="3"/>
+			</data>
+		</avp>
+		<avp name="Test-Id" code="9002" must="V,M" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+		<avp name="Test-Mode" code="9003" must="V,M" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="3G"/>
+				<item code="1" name="ON-OFF"/>
+				<item code="2" name="On Off"/>
+			</data>
+		</avp>
+	</application>
+</diameter>`
+
+func TestGenerate(t *testing.T) {
+	p, err := dict.NewParser("../testdata/base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Load(strings.NewReader(testDict)); err != nil {
+		t.Fatal(err)
+	}
+	f := new(dict.File)
+	if err = xml.Unmarshal([]byte(testDict), f); err != nil {
+		t.Fatal(err)
+	}
+	files, err := New(p, "test").Generate(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	want := []string{"avp/codes.go", "constants/testmode/test-mode.go", "messages.go"}
+	if len(names) != len(want) {
+		t.Fatalf("Unexpected files. Want %v, have %v", want, names)
+	}
+	structs := parseStructs(t, files["messages.go"])
+	for typ, fields := range map[string][]string{
+		"TER": {
+			"SessionID datatype.UTF8String `avp:\"Session-Id\"`",
+			"OriginHost datatype.DiameterIdentity `avp:\"Origin-Host\"`",
+			"TestGrouped *TestGrouped `avp:\"Test-Grouped,omitempty\"`",
+			"TestMode datatype.Enumerated `avp:\"Test-Mode\"`",
+			"ProxyInfo []ProxyInfo `avp:\"Proxy-Info,omitempty\"`",
+		},
+		"TEA": {
+			"SessionID datatype.UTF8String `avp:\"Session-Id\"`",
+			"ResultCode *datatype.Unsigned32 `avp:\"Result-Code,omitempty\"`",
+		},
+		"TestGrouped": {
+			"TestID datatype.Unsigned32 `avp:\"Test-Id\"`",
+			"TestGrouped *TestGrouped `avp:\"Test-Grouped,omitempty\"`",
+			"TestMode []datatype.Enumerated `avp:\"Test-Mode\"`",
+		},
+		"ProxyInfo": {
+			"ProxyHost datatype.DiameterIdentity `avp:\"Proxy-Host\"`",
+			"ProxyState datatype.OctetString `avp:\"Proxy-State\"`",
+		},
+	} {
+		if !reflect.DeepEqual(structs[typ], fields) {
+			t.Errorf("Unexpected fields of %s.\nWant %q\nHave %q", typ, fields, structs[typ])
+		}
+	}
+	if len(structs) != 4 {
+		t.Errorf("Unexpected # of structs. Want 4, have %d", len(structs))
+	}
+	codes := string(files["avp/codes.go"])
+	for _, s := range []string{"TestGrouped = 9001", "TestID = 9002", "TestMode = 9003"} {
+		if !strings.Contains(strings.Join(strings.Fields(codes), " "), s) {
+			t.Errorf("Missing %q in codes:\n%s", s, codes)
+		}
+	}
+	enums := string(files["constants/testmode/test-mode.go"])
+	for _, s := range []string{
+		"package testmode",
+		"V3G = datatype.Enumerated(0)",
+		"ON_OFF = datatype.Enumerated(1)",
+		"ON_OFF_2 = datatype.Enumerated(2)",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(enums), " "), s) {
+			t.Errorf("Missing %q in enums:\n%s", s, enums)
+		}
+	}
+}
+
+// TestGenerateDefault checks that valid code is generated for the
+// default dictionaries.
+func TestGenerateDefault(t *testing.T) {
+	f := &dict.File{App: dict.Default.Apps()}
+	files, err := New(dict.Default, "messages").Generate(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if _, err := parser.ParseFile(token.NewFileSet(), name, src, 0); err != nil {
+			t.Errorf("Invalid %s: %v", name, err)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	for _, tc := range []struct {
+		fn       func(string) string
+		in, want string
+	}{
+		{goName, "Session-Id", "SessionID"},
+		{goName, "Session-Ids", "SessionIDs"},
+		{goName, "Vendor-Specific-Application-Id", "VendorSpecificApplicationID"},
+		{goName, "Identity-Set", "IdentitySet"},
+		{goName, "eNodeB-ID_S6", "ENodeBID_S6"},
+		{goName, "3GPP-IMSI", "AVP3GPPIMSI"},
+		{packageName, "Auth-Session-State", "authsessionstate"},
+		{packageName, "Type", "avptype"},
+		{fileName, "User-CSG-Information_S6", "user-csg-information-s6"},
+		{enumName, "NO_STATE_MAINTAINED", "NO_STATE_MAINTAINED"},
+		{enumName, "1 to 1 PoC session", "V1_TO_1_POC_SESSION"},
+		{enumName, "ADSL-CAP - Asymmetric DSL", "ADSL_CAP_ASYMMETRIC_DSL"},
+	} {
+		if have := tc.fn(tc.in); have != tc.want {
+			t.Errorf("Unexpected name of %q. Want %q, have %q", tc.in, tc.want, have)
+		}
+	}
+}
+
+// parseStructs returns the fields of the structs in src, indexed by type.
+func parseStructs(t *testing.T, src []byte) map[string][]string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "messages.go", src, 0)
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, src)
+	}
+	structs := make(map[string][]string)
+	ast.Inspect(file, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		for _, field := range ts.Type.(*ast.StructType).Fields.List {
+			var typ bytes.Buffer
+			printer.Fprint(&typ, fset, field.Type)
+			structs[ts.Name.Name] = append(structs[ts.Name.Name],
+				field.Names[0].Name+" "+typ.String()+" "+field.Tag.Value)
+		}
+		return false
+	})
+	return structs
+}
@@ -15,25 +15,27 @@ import (
 // Base Protocol and Credit Control dictionaries.
 var Default *Parser
 
+// defaultDictionaries are the dictionaries of Default, in load order.
+var defaultDictionaries = []struct{ name, xml string }{
+	{"Base", baseXML},
+	{"Credit Control", creditcontrolXML},
+	{"Gx Charging Control", gxcreditcontrolXML},
+	{"Network Access Server", networkaccessserverXML},
+	{"TGPP", tgpprorfXML},
+	{"TGPP_S6a", tgpps6aXML},
+	{"TGPP_Swx", tgppswxXML},
+	{"TGPP_Sh", tgppshXML},
+	{"TGPP_s6c", tgpps6cXML},
+	{"TGPP_sgd_gdd", tgppsgdgddXML},
+}
+
 func init() {
-	var dictionaries = []struct{ name, xml string }{
-		{"Base", baseXML},
-		{"Credit Control", creditcontrolXML},
-		{"Gx Charging Control", gxcreditcontrolXML},
-		{"Network Access Server", networkaccessserverXML},
-		{"TGPP", tgpprorfXML},
-		{"TGPP_S6a", tgpps6aXML},
-		{"TGPP_Swx", tgppswxXML},
-		{"TGPP_Sh", tgppshXML},
-		{"TGPP_s6c", tgpps6cXML},
-		{"TGPP_sgd_gdd", tgppsgdgddXML},
-	}
 	var err error
 	Default, err = NewParser()
 	if err != nil {
 		panic(err)
 	}
-	for _, dict := range dictionaries {
+	for _, dict := range defaultDictionaries {
 		err = Default.Load(bytes.NewReader([]byte(dict.xml)))
 		if err != nil {
 			panic(fmt.Sprintf("Cannot load %s dictionary: %s", dict.name, err))
@@ -124,7 +126,7 @@ var baseXML = `<?xml version="1.0" encoding="UTF-8"?>
 				<rule avp="User-Name" required="false" max="1"/>
 				<rule avp="Destination-Host" required="false" max="1"/>
 				<rule avp="Accounting-Sub-Session-Id" required="false" max="1"/>
-				<rule avp="Acct-Session-Id" required="false" max="1"/>
+				<rule avp="Accounting-Session-Id" required="false" max="1"/>
 				<rule avp="Acct-Multi-Session-Id" required="false" max="1"/>
 				<rule avp="Acct-Interim-Interval" required="false" max="1"/>
 				<rule avp="Accounting-Realtime-Required" required="false" max="1"/>
@@ -144,7 +146,7 @@ var baseXML = `<?xml version="1.0" encoding="UTF-8"?>
 				<rule avp="Vendor-Specific-Application-Id" required="false" max="1"/>
 				<rule avp="User-Name" required="false" max="1"/>
 				<rule avp="Accounting-Sub-Session-Id" required="false" max="1"/>
-				<rule avp="Acct-Session-Id" required="false" max="1"/>
+				<rule avp="Accounting-Session-Id" required="false" max="1"/>
 				<rule avp="Acct-Multi-Session-Id" required="false" max="1"/>
 				<rule avp="Error-Message" required="false" max="1"/>
 				<rule avp="Error-Reporting-Host" required="false" max="1"/>
@@ -599,7 +601,7 @@ var baseXML = `<?xml version="1.0" encoding="UTF-8"?>
 var creditcontrolXML = `<?xml version="1.0" encoding="UTF-8"?>
 <diameter>
 
-	<application id="4" type="auth" name="Charging Control">
+	<application id="4" type="auth" name="Charging Control" inherits="1">
 		<!-- Diameter Credit Control Application -->
 		<!-- http://tools.ietf.org/html/rfc4006 -->
 
@@ -1102,7 +1104,7 @@ var diametersyXML = `<?xml version="1.0" encoding="UTF-8"?>
 var gxcreditcontrolXML = `<?xml version="1.0" encoding="UTF-8"?>
 <diameter>
 
-    <application id="16777238" type="auth" name="Gx Charging Control">
+    <application id="16777238" type="auth" name="Gx Charging Control" inherits="4">
         <!-- Diameter Gx Credit Control Application -->
         <!-- 3GPP 29.212 -->
 
@@ -1247,7 +1249,7 @@ var gxcreditcontrolXML = `<?xml version="1.0" encoding="UTF-8"?>
             <data type="OctetString"/>
         </avp>
 
-        <avp name="Event-Trigger" code="1006" must="M,V" map="P" may-encrypt="Y" vendor-id="10415">
+        <avp name="Event-Trigger" code="1006" must="M,V" may="P" may-encrypt="Y" vendor-id="10415">
             <!-- 3GPP 29.212 Section 5.3.7 -->
             <data type="Enumerated">
                 <item code="0" name="SGSN_CHANGE"/>
@@ -1297,7 +1299,7 @@ var gxcreditcontrolXML = `<?xml version="1.0" encoding="UTF-8"?>
             <data type="Unsigned32"/>
         </avp>
 
-        <avp name="IP-CAN-Type" code="1027" must="M,V" map="P" may-encrypt="Y" vendor-id="10415">
+        <avp name="IP-CAN-Type" code="1027" must="M,V" may="P" may-encrypt="Y" vendor-id="10415">
             <!-- 3GPP 29.212 Section 5.3.27 -->
             <data type="Enumerated">
                 <item code="0" name="3GPP-GPRS"/>
@@ -1333,7 +1335,7 @@ var gxcreditcontrolXML = `<?xml version="1.0" encoding="UTF-8"?>
             <data type="OctetString"/>
         </avp>
 
-        <avp name="Flow-Information" code="1058" must="V" must-not="M" may="P" may-encryp="y" vendor-id="10415">
+        <avp name="Flow-Information" code="1058" must="V" must-not="M" may="P" may-encrypt="y" vendor-id="10415">
             <!-- 3GPP 29.212 Section 5.3.53 -->
             <data type="Grouped">
                 <rule avp="Flow-Description" required="false" max="1"/>
@@ -1356,7 +1358,7 @@ var gxcreditcontrolXML = `<?xml version="1.0" encoding="UTF-8"?>
             <data type="OctetString"/>
         </avp>
 
-        <avp name="Usage-Monitoring-Information" code="1067" must="V" may="P" must-not="M,V" may-encrypt="y" vendor-id="10415">
+        <avp name="Usage-Monitoring-Information" code="1067" must="V" may="P" must-not="M" may-encrypt="y" vendor-id="10415">
             <!-- 3GPP 29.212 -->
             <data type="Grouped">
                 <rule avp="Monitoring-Key" required="false" max="1"/>
@@ -1390,14 +1392,14 @@ var gxcreditcontrolXML = `<?xml version="1.0" encoding="UTF-8"?>
             </data>
         </avp>
 
-        <avp name="Packet-Filter-Usage" code="1072" must="V" must-not="M" map="P" may-encrypt="Y" vendor-id="10415">
+        <avp name="Packet-Filter-Usage" code="1072" must="V" must-not="M" may="P" may-encrypt="Y" vendor-id="10415">
             <!-- 3GPP 29.212 Section 5.3.66 -->
             <data type="Enumerated">
                 <item code="1" name="SEND_TO_UE"/>
             </data>
         </avp>
 
-        <avp name="Flow-Direction" code="1080" must="V" must-not="M" map="P" may-encrypt="Y" vendor-id="10415">
+        <avp name="Flow-Direction" code="1080" must="V" must-not="M" may="P" may-encrypt="Y" vendor-id="10415">
             <!-- 3GPP 29.212 Section 5.3.65 -->
             <data type="Enumerated">
                 <item code="0" name="UNSPECIFIED"/>
@@ -1680,7 +1682,7 @@ var networkaccessserverXML = `<?xml version="1.0" encoding="UTF-8"?>
 				<rule avp="Called-Station-Id" required="false" max="1"/>
 				<rule avp="Calling-Station-Id" required="false" max="1"/>
 				<rule avp="Originating-Line-Info" required="false" max="1"/>
-				<rule avp="Acct-Session-Id" required="false" max="1"/>
+				<rule avp="Accounting-Session-Id" required="false" max="1"/>
 				<rule avp="Acct-Multi-Session-Id" required="false" max="1"/>
 				<rule avp="State" required="false" max="1"/>
 				<rule avp="Class" required="false"/>
@@ -1779,7 +1781,7 @@ var networkaccessserverXML = `<?xml version="1.0" encoding="UTF-8"?>
 				<rule avp="Called-Station-Id" required="false" max="1"/>
 				<rule avp="Calling-Station-Id" required="false" max="1"/>
 				<rule avp="Originating-Line-Info" required="false" max="1"/>
-				<rule avp="Acct-Session-Id" required="false" max="1"/>
+				<rule avp="Accounting-Session-Id" required="false" max="1"/>
 				<rule avp="Acct-Multi-Session-Id" required="false" max="1"/>
 				<rule avp="State" required="false" max="1"/>
 				<rule avp="Class" required="false"/>
@@ -1819,7 +1821,7 @@ var networkaccessserverXML = `<?xml version="1.0" encoding="UTF-8"?>
 				<rule avp="Acct-Application-Id" required="true" max="1"/>
 				<rule avp="User-Name" required="false" max="1"/>
 				<rule avp="Accounting-Sub-Session-Id" required="false" max="1"/>
-				<rule avp="Acct-Session-Id" required="false" max="1"/>
+				<rule avp="Accounting-Session-Id" required="false" max="1"/>
 				<rule avp="Acct-Multi-Session-Id" required="false" max="1"/>
 				<rule avp="Origin-AAA-Protocol" required="false" max="1"/>
 				<rule avp="Origin-State-Id" required="false" max="1"/>
@@ -1849,7 +1851,7 @@ var networkaccessserverXML = `<?xml version="1.0" encoding="UTF-8"?>
 				<rule avp="Callback-Number" required="false" max="1"/>
 				<rule avp="Called-Station-Id" required="false" max="1"/>
 				<rule avp="Calling-Station-Id" required="false" max="1"/>
-				<rule avp="Connection-Info" required="false"/>
+				<rule avp="Connect-Info" required="false"/>
 				<rule avp="Originating-Line-Info" required="false" max="1"/>
 				<rule avp="Authorization-Lifetime" required="false" max="1"/>
 				<rule avp="Session-Timeout" required="false" max="1"/>
@@ -1899,7 +1901,7 @@ var networkaccessserverXML = `<?xml version="1.0" encoding="UTF-8"?>
 				<rule avp="Acct-Application-Id" required="true" max="1"/>
 				<rule avp="User-Name" required="false" max="1"/>
 				<rule avp="Accounting-Sub-Session-Id" required="false" max="1"/>
-				<rule avp="Acct-Session-Id" required="false" max="1"/>
+				<rule avp="Accounting-Session-Id" required="false" max="1"/>
 				<rule avp="Acct-Multi-Session-Id" required="false" max="1"/>
 				<rule avp="Event-Timestamp" required="false" max="1"/>
 				<rule avp="Error-Message" required="false" max="1"/>
@@ -2142,10 +2144,10 @@ var networkaccessserverXML = `<?xml version="1.0" encoding="UTF-8"?>
 			<data type="OctetString"/>
 		</avp>
 
-		<!--avp name="QoS-Filter-Rule" code="407" must="-" may="" must-not="-" may-encrypt="Y"-->
+		<avp name="QoS-Filter-Rule" code="407" must="-" may="M" must-not="V" may-encrypt="Y">
 			<!-- http://tools.ietf.org/html/rfc7155#section-4.4.9 -->
-			<!--data type="QoSFilterRule"/-->
-		<!--/avp-->
+			<data type="QoSFilterRule"/>
+		</avp>
 
 
 		<avp name="Framed-Protocol" code="7" must="M" may="-" must-not="V" may-encrypt="Y">
@@ -2482,14 +2484,43 @@ var networkaccessserverXML = `<?xml version="1.0" encoding="UTF-8"?>
 			<!-- http://tools.ietf.org/html/rfc7155#section-4.6.11 -->
 			<data type="Unsigned32"/>
 		</avp>
-		
+
+		<avp name="NAS-Identifier" code="32" must="M" may="-" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4005#section-9.3.1 -->
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="NAS-IP-Address" code="4" must="M" may="-" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4005#section-9.3.2 -->
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="NAS-IPv6-Address" code="95" must="M" may="-" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4005#section-9.3.3 -->
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="State" code="24" must="M" may="-" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4005#section-9.3.4 -->
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Origin-AAA-Protocol" code="408" must="M" may="-" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4005#section-9.3.6 -->
+			<data type="Enumerated">
+				<item code="1" name="RADIUS"/>
+			</data>
+		</avp>
+
 	</application>
 </diameter>`
 
 var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
 <diameter>
-	<application id="4" type="auth" name="TGPP">
+	<application id="4" type="auth" name="TGPP" inherits="1">
 		<vendor id="10415" name="TGPP"/>
+		<vendor id="13019" name="ETSI"/>
+		<vendor id="5535" name="TGPP2"/>
 
 		<avp name="TGPP-Charging-Characteristics" code="13" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="UTF8String"/>
@@ -2747,7 +2778,7 @@ var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
 			<data type="Grouped">
 				<rule avp="Application-Server" required="false" max="1"/>
 				<rule avp="Application-Provided-Called-Party-Address" required="false"/>
-				<rule avp="Status- AS-Code" required="false" max="1"/>
+				<rule avp="Status-AS-Code" required="false" max="1"/>
 			</data>
 		</avp>
 
@@ -3273,7 +3304,7 @@ var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
 			<data type="Grouped">
 				<rule avp="ISUP-Cause-Location" required="false" max="1"/>
 				<rule avp="ISUP-Cause-Value" required="false" max="1"/>
-				<rule avp="ISUP-Cause-Diagnostic" required="false" max="1"/>
+				<rule avp="ISUP-Cause-Diagnostics" required="false" max="1"/>
 			</data>
 		</avp>
 
@@ -4526,7 +4557,7 @@ var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
 				<rule avp="Serving-Node" required="false" max="1"/>
 				<rule avp="Validity-Time" required="false" max="1"/>
 				<rule avp="Priority-Indication" required="false" max="1"/>
-				<rule avp="Application-Port-Identifier" required="false" max="1"/>
+				<rule avp="Application-Port-Identifer" required="false" max="1"/>
 			</data>
 		</avp>
 
@@ -4800,7 +4831,7 @@ var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
 				<rule avp="QoS-Information" required="false" max="1"/>
 				<rule avp="Accounting-Input-Octets" required="false" max="1"/>
 				<rule avp="Accounting-Output-Octets" required="false" max="1"/>
-				<rule avp="Change-condition" required="false" max="1"/>
+				<rule avp="Change-Condition" required="false" max="1"/>
 				<rule avp="Change-Time" required="false" max="1"/>
 				<rule avp="TGPP-User-Location-Info" required="false" max="1"/>
 				<rule avp="TGPP-Charging-Id" required="false" max="1"/>
@@ -4942,7 +4973,7 @@ var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
 				<rule avp="ISUP-Location-Number" required="false" max="1"/>
 				<rule avp="VLR-Number" required="false" max="1"/>
 				<rule avp="Forwarding-Pending" required="false" max="1"/>
-				<rule avp="ISUP-Release-Cause" required="false" max="1"/>
+				<rule avp="ISUP-Cause" required="false" max="1"/>
 				<rule avp="Start-Time" required="false" max="1"/>
 				<rule avp="Start-of-Charging" required="false" max="1"/>
 				<rule avp="Stop-Time" required="false" max="1"/>
@@ -4990,7 +5021,7 @@ var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
       <data type="Unsigned32"/>
     </avp>
 
-    <avp name="Extended-APN-AMBR-DL" code="2848" must="V" must-not="M" may= "P" may-encrypt="Y" vendor-id="10415">
+    <avp name="Extended-APN-AMBR-DL" code="2848" must="V" must-not="M" may="P" may-encrypt="Y" vendor-id="10415">
       <data type="Unsigned32"/>
     </avp>
 
@@ -4998,6 +5029,119 @@ var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
       <data type="Unsigned32"/>
     </avp>
 
+		<avp name="Application-Service-Type" code="2102" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="100" name="SENDING"/>
+				<item code="101" name="RECEIVING"/>
+				<item code="102" name="RETRIEVAL"/>
+				<item code="103" name="INVITING"/>
+				<item code="104" name="LEAVING"/>
+				<item code="105" name="JOINING"/>
+			</data>
+		</avp>
+
+		<avp name="Conditional-APN-Aggregate-Max-Bitrate" code="2818" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="APN-Aggregate-Max-Bitrate-UL" required="false" max="1"/>
+				<rule avp="APN-Aggregate-Max-Bitrate-DL" required="false" max="1"/>
+				<rule avp="Extended-APN-AMBR-UL" required="false" max="1"/>
+				<rule avp="Extended-APN-AMBR-DL" required="false" max="1"/>
+				<rule avp="IP-CAN-Type" required="false"/>
+				<rule avp="RAT-Type" required="false"/>
+				<rule avp="AVP" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="DCD-Information" code="2115" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Content-Id" required="false" max="1"/>
+				<rule avp="Content-Provider-Id" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Flow-Number" code="509" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="IM-Information" code="2110" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Total-Number-Of-Messages-Sent" required="false" max="1"/>
+				<rule avp="Total-Number-Of-Messages-Exploded" required="false" max="1"/>
+				<rule avp="Number-Of-Messages-Successfully-Sent" required="false" max="1"/>
+				<rule avp="Number-Of-Messages-Successfully-Exploded" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="IP-CAN-Type" code="1027" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="3GPP-GPRS"/>
+				<item code="1" name="DOCSIS"/>
+				<item code="2" name="xDSL"/>
+				<item code="3" name="WiMAX"/>
+				<item code="4" name="3GPP2"/>
+				<item code="5" name="3GPP-EPS"/>
+				<item code="6" name="Non-3GPP-EPS"/>
+				<item code="7" name="FBA"/>
+				<item code="8" name="3GPP-5GS"/>
+				<item code="9" name="Non-3GPP-5GS"/>
+			</data>
+		</avp>
+
+		<avp name="LCS-Capabilities-Sets" code="2404" must="V" may="-" must-not="M" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Logical-Access-Id" code="302" must="V" may="M,P" must-not="-" may-encrypt="N" vendor-id="13019">
+			<!-- ETSI ES 283 034 -->
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Media-Component-Number" code="518" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MSC-Number" code="2403" must="V,M" may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Physical-Access-Id" code="313" must="V" may="M,P" must-not="-" may-encrypt="N" vendor-id="13019">
+			<!-- ETSI ES 283 034 -->
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Presence-Reporting-Area-Elements-List" code="2820" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Service-Generic-Information" code="1256" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Application-Server-Id" required="false" max="1"/>
+				<rule avp="Application-Service-Type" required="false" max="1"/>
+				<rule avp="Application-Session-Id" required="false" max="1"/>
+				<rule avp="Delivery-Status" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="SGSN-Name" code="2409" must="V" may="-" must-not="M" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
+		</avp>
+
+		<avp name="SGSN-Realm" code="2410" must="V" may="-" must-not="M" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
+		</avp>
+
+		<avp name="TGPP-AAA-Server-Name" code="318" must="V,M" may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
+		</avp>
+
+		<avp name="TGPP2-BSID" code="9010" must="V" may="-" must-not="M" may-encrypt="N" vendor-id="5535">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="TGPP2-MEID" code="1471" must="V,M" may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
 	</application>
 </diameter>`
 
@@ -5007,7 +5151,7 @@ var tgpps6aXML = `<?xml version="1.0" encoding="UTF-8"?>
         3GPP TS 29.272
         See: http://www.etsi.org/deliver/etsi_ts/129200_129299/129272/12.06.00_60/ts_129272v120600p.pdf
     -->
-    <application id="16777251" type="auth" name="TGPP S6A">
+    <application id="16777251" type="auth" name="TGPP S6A" inherits="4">
         <vendor id="10415" name="TGPP"/>
         <command code="316" short="UL" name="Update-Location">
             <request>
@@ -5250,7 +5394,7 @@ var tgpps6aXML = `<?xml version="1.0" encoding="UTF-8"?>
                 <rule avp="UE-SRVCC-Capability" required="false" max="1" />
                 <rule avp="NOR-Flags" required="false" max="1" />
                 <rule avp="Homogeneous-Support-of-IMS-Voice-Over-PS-Sessions" required="false" max="1" />
-                <rule avp="Maximum-UE-Availability-Type" required="false" max="1" />
+                <rule avp="Maximum-UE-Availability-Time" required="false" max="1" />
                 <rule avp="Monitoring-Event-Config-Status" required="false" />
                 <rule avp="Emergency-Services" required="false" max="1" />
                 <rule avp="Proxy-Info" required="false" />
@@ -5499,7 +5643,7 @@ var tgpps6aXML = `<?xml version="1.0" encoding="UTF-8"?>
                 <item code="1" name="O_AND_M_HPLMN"/>
                 <item code="2" name="O_AND_M_VPLMN"/>
                 <item code="3" name="ANONYMOUS_LOCATION"/>
-                <item code="3" name="TARGET_UE_SUBSCRIBED_SERVICE"/>
+                <item code="4" name="TARGET_UE_SUBSCRIBED_SERVICE"/>
             </data>
         </avp>
 
@@ -6011,12 +6155,12 @@ var tgpps6aXML = `<?xml version="1.0" encoding="UTF-8"?>
             </data>
         </avp>
 
-        <avp name="MIP-Home-Agent-Address" code="334" must="M" must-not="V" vendor-id="10415">
+        <avp name="MIP-Home-Agent-Address" code="334" must="M" must-not="V">
             <data type="Address"/>
         </avp>
 
         <!-- RFC 4004 -->
-        <avp name="MIP-Home-Agent-Host" code="348" must="M" may="P" must-not="V" may-encrypt="Y" vendor-id="10415">
+        <avp name="MIP-Home-Agent-Host" code="348" must="M" may="P" must-not="V" may-encrypt="Y">
             <data type="Grouped">
                 <rule avp="Destination-Realm" required="true" max="1"/>
                 <rule avp="Destination-Host" required="true" max="1"/>
@@ -6025,7 +6169,7 @@ var tgpps6aXML = `<?xml version="1.0" encoding="UTF-8"?>
         </avp>
 
         <!-- RFC 5447 Diameter Mobile IPv6: Support for Network Access Server to Diameter Server Interaction -->
-        <avp name="MIP6-Agent-Info" code="486" must="M" may="P" must-not="V" may-encrypt="Y" vendor-id="10415">
+        <avp name="MIP6-Agent-Info" code="486" must="M" may="P" must-not="V" may-encrypt="Y">
             <data type="Grouped">
                 <rule avp="MIP-Home-Agent-Address" required="false" max="2"/>
                 <rule avp="MIP-Home-Agent-Host" required="false" max="1"/>
@@ -6034,7 +6178,7 @@ var tgpps6aXML = `<?xml version="1.0" encoding="UTF-8"?>
             </data>
         </avp>
 
-        <avp name="Service-Selection" code="493" must="M" may="P" must-not="V" may-encrypt="Y" vendor-id="10415">
+        <avp name="Service-Selection" code="493" must="M" may="P" must-not="V" may-encrypt="Y">
             <data type="UTF8String"/>
         </avp>
 
@@ -6042,7 +6186,7 @@ var tgpps6aXML = `<?xml version="1.0" encoding="UTF-8"?>
             <data type="OctetString"/>
         </avp>
 
-        <avp name="MIP6-Home-Link-Prefix" code="125" vendor-id="10415">
+        <avp name="MIP6-Home-Link-Prefix" code="125" must="M" may="P" must-not="V" may-encrypt="Y">
             <data type="OctetString"/>
         </avp>
 
@@ -6050,6 +6194,150 @@ var tgpps6aXML = `<?xml version="1.0" encoding="UTF-8"?>
             <data type="UTF8String"/>
         </avp>
 
+        <avp name="Age-Of-Location-Information" code="1611" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="Unsigned32"/>
+        </avp>
+
+        <avp name="Alert-Reason" code="1434" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="Enumerated">
+                <item code="0" name="UE_PRESENT"/>
+                <item code="1" name="UE_MEMORY_AVAILABLE"/>
+            </data>
+        </avp>
+
+        <avp name="Cell-Global-Identity" code="1604" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="Current-Location-Retrieved" code="1610" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="Enumerated">
+                <item code="0" name="ACTIVE-LOCATION-RETRIEVAL"/>
+            </data>
+        </avp>
+
+        <avp name="E-UTRAN-Cell-Global-Identity" code="1602" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="Emergency-Services" code="1538" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <data type="Unsigned32"/>
+        </avp>
+
+        <avp name="eNodeB-Id" code="4008" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="EPS-Location-Information" code="1496" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="Grouped">
+                <rule avp="MME-Location-Information" required="false" max="1"/>
+                <rule avp="SGSN-Location-Information" required="false" max="1"/>
+                <rule avp="AVP" required="false"/>
+            </data>
+        </avp>
+
+        <avp name="Extended-eNodeB-Id" code="4013" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="Geodetic-Information" code="1609" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="Geographical-Information" code="1608" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="Location-Area-Identity" code="1606" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="Maximum-UE-Availability-Time" code="3329" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <data type="Time"/>
+        </avp>
+
+        <avp name="MME-Location-Information" code="1600" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="Grouped">
+                <rule avp="E-UTRAN-Cell-Global-Identity" required="false" max="1"/>
+                <rule avp="Tracking-Area-Identity" required="false" max="1"/>
+                <rule avp="Geographical-Information" required="false" max="1"/>
+                <rule avp="Geodetic-Information" required="false" max="1"/>
+                <rule avp="Current-Location-Retrieved" required="false" max="1"/>
+                <rule avp="Age-Of-Location-Information" required="false" max="1"/>
+                <rule avp="User-CSG-Information" required="false" max="1"/>
+                <rule avp="eNodeB-Id" required="false" max="1"/>
+                <rule avp="Extended-eNodeB-Id" required="false" max="1"/>
+                <rule avp="AVP" required="false"/>
+            </data>
+        </avp>
+
+        <avp name="Monitoring-Event-Config-Status" code="3142" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <data type="Grouped">
+                <rule avp="Service-Report" required="false"/>
+                <rule avp="SCEF-Reference-ID" required="true" max="1"/>
+                <rule avp="SCEF-ID" required="false" max="1"/>
+                <rule avp="AVP" required="false"/>
+            </data>
+        </avp>
+
+        <avp name="Node-Type" code="3153" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <data type="Unsigned32"/>
+        </avp>
+
+        <avp name="Routing-Area-Identity" code="1605" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="SCEF-ID" code="3125" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="DiameterIdentity"/>
+        </avp>
+
+        <avp name="SCEF-Reference-ID" code="3124" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="Unsigned32"/>
+        </avp>
+
+        <avp name="Service-Area-Identity" code="1607" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="Service-Report" code="3152" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <data type="Grouped">
+                <rule avp="Service-Result" required="false" max="1"/>
+                <rule avp="Node-Type" required="false" max="1"/>
+                <rule avp="AVP" required="false"/>
+            </data>
+        </avp>
+
+        <avp name="Service-Result" code="3146" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <data type="Grouped">
+                <rule avp="Vendor-Id" required="false" max="1"/>
+                <rule avp="Service-Result-Code" required="false" max="1"/>
+                <rule avp="AVP" required="false"/>
+            </data>
+        </avp>
+
+        <avp name="Service-Result-Code" code="3147" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <data type="Unsigned32"/>
+        </avp>
+
+        <avp name="SGSN-Location-Information" code="1601" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="Grouped">
+                <rule avp="Cell-Global-Identity" required="false" max="1"/>
+                <rule avp="Location-Area-Identity" required="false" max="1"/>
+                <rule avp="Service-Area-Identity" required="false" max="1"/>
+                <rule avp="Routing-Area-Identity" required="false" max="1"/>
+                <rule avp="Geographical-Information" required="false" max="1"/>
+                <rule avp="Geodetic-Information" required="false" max="1"/>
+                <rule avp="Current-Location-Retrieved" required="false" max="1"/>
+                <rule avp="Age-Of-Location-Information" required="false" max="1"/>
+                <rule avp="User-CSG-Information" required="false" max="1"/>
+                <rule avp="AVP" required="false"/>
+            </data>
+        </avp>
+
+        <avp name="Tracking-Area-Identity" code="1603" must="M,V" may-encrypt="N" vendor-id="10415">
+            <data type="OctetString"/>
+        </avp>
+
     </application>
 </diameter>`
 
@@ -6124,7 +6412,7 @@ var tgpps6cXML = `<?xml version="1.0" encoding="UTF-8"?>
                 <rule avp="SMSMI-Correlation-ID" max="1" required="false"/>
                 <rule avp="Maximum-UE-Availability-Time" max="1" required="false"/>
                 <rule avp="SMS-GMSC-Alert-Event" max="1" required="false"/>
-                <rule avp="Serving-Node-T4" max="1" required="false"/>
+                <rule avp="Serving-Node" max="1" required="false"/>
                 <rule avp="Supported-Features" required="false"/>
                 <rule avp="AVP" required="false"/>
                 <rule avp="Proxy-Info" required="false"/>
@@ -6392,10 +6680,10 @@ var tgpps6cXML = `<?xml version="1.0" encoding="UTF-8"?>
                 <rule avp="Feature-List" max="1" required="true"/>
             </data>
         </avp>
-        <avp name="Feature-List-ID" code="629" may-encrypt="N" must="V" must-not="V" vendor-id="10415">
+        <avp name="Feature-List-ID" code="629" may-encrypt="N" must="V" must-not="M" vendor-id="10415">
             <data type="Unsigned32"/>
         </avp>
-        <avp name="Feature-List" code="630" may-encrypt="N" must="V" must-not="V" vendor-id="10415">
+        <avp name="Feature-List" code="630" may-encrypt="N" must="V" must-not="M" vendor-id="10415">
             <data type="Unsigned32"/>
         </avp>
 
@@ -6526,10 +6814,10 @@ var tgppsgdgddXML = `<?xml version="1.0" encoding="UTF-8"?>
                 <rule avp="Feature-List" max="1" required="true"/>
             </data>
         </avp>
-        <avp name="Feature-List-ID" code="629" may-encrypt="N" must="V" must-not="V" vendor-id="10415">
+        <avp name="Feature-List-ID" code="629" may-encrypt="N" must="V" must-not="M" vendor-id="10415">
             <data type="Unsigned32"/>
         </avp>
-        <avp name="Feature-List" code="630" may-encrypt="N" must="V" must-not="V" vendor-id="10415">
+        <avp name="Feature-List" code="630" may-encrypt="N" must="V" must-not="M" vendor-id="10415">
             <data type="Unsigned32"/>
         </avp>
         <avp name="SMSMI-Correlation-ID" code="3324" may="P" may-encrypt="N" must="V" vendor-id="10415">
@@ -6541,7 +6829,7 @@ var tgppsgdgddXML = `<?xml version="1.0" encoding="UTF-8"?>
             </data>
         </avp>
         <avp name="HSS-ID" code="3325" may-encrypt="N" must="V" vendor-id="10415">
-            <data type="UTF8String"/>
+            <data type="OctetString"/>
         </avp>
         <avp name="Originating-SIP-URI" code="3326" may-encrypt="N" must="V" vendor-id="10415">
             <data type="UTF8String"/>
@@ -6903,7 +7191,7 @@ var tgppshXML = `<?xml version="1.0" encoding="UTF-8"?>
                 <item code="32" name="IMSI"/>
                 <item code="33" name="IMSPrivateUserIdentity"/>
                 <item code="34" name="IMEISV"/>
-                <item code="34" name="UE-5G-SRVCC-Capability"/>
+                <item code="35" name="UE-5G-SRVCC-Capability"/>
             </data>
         </avp>
         <avp name="Service-Indication" code="704" may="P" may-encrypt="N" must="M,V" vendor-id="10415">
@@ -6962,8 +7250,8 @@ var tgppshXML = `<?xml version="1.0" encoding="UTF-8"?>
         </avp>
         <avp name="Repository-Data-ID" code="715" may-encrypt="N" must="V" must-not="M" vendor-id="10415">
             <data type="Grouped">
-                <rule max="1" name="Service-Indication" required="true"/>
-                <rule max="1" name="Sequence-Number" required="true"/>
+                <rule avp="Service-Indication" max="1" required="true"/>
+                <rule avp="Sequence-Number" max="1" required="true"/>
             </data>
         </avp>
         <avp name="Sequence-Number" code="716" may-encrypt="N" must="V" must-not="M" vendor-id="10415">
@@ -6984,7 +7272,7 @@ var tgppshXML = `<?xml version="1.0" encoding="UTF-8"?>
         <avp name="UDR-Flags" code="719" may-encrypt="N" must="V" must-not="M" vendor-id="10415">
             <data type="Unsigned32"/>
         </avp>
-        <avp name="Call-Reference-Info" code="720" may-encrypt="N" must-not="M" vendor-id="10415">
+        <avp name="Call-Reference-Info" code="720" may-encrypt="N" must="V" must-not="M" vendor-id="10415">
             <data type="Grouped">
                 <rule avp="Call-Reference-Number" max="1" required="true"/>
                 <rule avp="AS-Number" max="1" required="true"/>
@@ -7273,7 +7561,7 @@ var tgppswxXML = `<?xml version="1.0" encoding="UTF-8"?>
         </avp>
 
         <!-- RFC 5447 Diameter Mobile IPv6: Support for Network Access Server to Diameter Server Interaction -->
-        <avp name="MIP6-Agent-Info" code="486" must="M" may="P" must-not="V" may-encrypt="Y" vendor-id="10415">
+        <avp name="MIP6-Agent-Info" code="486" must="M" may="P" must-not="V" may-encrypt="Y">
             <data type="Grouped">
                 <rule avp="MIP-Home-Agent-Address" required="false" max="2"/>
                 <rule avp="MIP-Home-Agent-Host" required="false" max="1"/>
@@ -7282,6 +7570,50 @@ var tgppswxXML = `<?xml version="1.0" encoding="UTF-8"?>
             </data>
         </avp>
 
+        <avp name="MIP-Home-Agent-Address" code="334" must="M" must-not="V">
+            <!-- RFC 4004 -->
+            <data type="Address"/>
+        </avp>
+
+        <avp name="MIP-Home-Agent-Host" code="348" must="M" may="P" must-not="V" may-encrypt="Y">
+            <!-- RFC 4004 -->
+            <data type="Grouped">
+                <rule avp="Destination-Realm" required="true" max="1"/>
+                <rule avp="Destination-Host" required="true" max="1"/>
+                <rule avp="AVP" required="false"/>
+            </data>
+        </avp>
+
+        <avp name="MIP6-Home-Link-Prefix" code="125" must="M" may="P" must-not="V" may-encrypt="Y">
+            <!-- RFC 5447 -->
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="IMEI" code="1402" must="M,V" may-encrypt="N" vendor-id="10415">
+            <!-- 3GPP TS 29.272 Section 7.3.4 -->
+            <data type="UTF8String"/>
+        </avp>
+
+        <avp name="Software-Version" code="1403" must="M,V" may-encrypt="N" vendor-id="10415">
+            <!-- 3GPP TS 29.272 Section 7.3.5 -->
+            <data type="UTF8String"/>
+        </avp>
+
+        <avp name="TGPP2-MEID" code="1471" must="M,V" may-encrypt="N" vendor-id="10415">
+            <!-- 3GPP TS 29.272 Section 7.3.6 -->
+            <data type="OctetString"/>
+        </avp>
+
+        <avp name="Feature-List-ID" code="629" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <!-- 3GPP TS 29.229 Section 6.3.30 -->
+            <data type="Unsigned32"/>
+        </avp>
+
+        <avp name="Feature-List" code="630" must="V" must-not="M" may-encrypt="N" vendor-id="10415">
+            <!-- 3GPP TS 29.229 Section 6.3.31 -->
+            <data type="Unsigned32"/>
+        </avp>
+
         <avp name="Server-Assignment-Type" code="614" must="M,V" may-encrypt="N" vendor-id="10415">
             <!-- http://www.qtc.jp/3GPP/Specs/29273-920.pdf Section 8.2.3.12 -->
             <data type="Enumerated">
@@ -7337,7 +7669,7 @@ var tgppswxXML = `<?xml version="1.0" encoding="UTF-8"?>
                 <rule avp="Session-Timeout" required="false" max="1"/>
                 <rule avp="MIP6-Feature-Vector" required="false" max="1"/>
                 <rule avp="AMBR" required="false" max="1"/>
-                <rule avp="3GPP-Charging-Characteristics" required="false" max="1"/>
+                <rule avp="TGPP-Charging-Characteristics" required="false" max="1"/>
                 <rule avp="Context-Identifier" required="false" max="1"/>
                 <rule avp="APN-OI-Replacement" required="false" max="1"/>
                 <rule avp="APN-Configuration" required="false"/>
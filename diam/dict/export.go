@@ -0,0 +1,71 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Dictionary export.  Part of go-diameter.
+
+package dict
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// WriteXML writes the dictionaries loaded in the Parser to w as a single
+// go-diameter XML dictionary, that can be loaded with Load.
+func (p *Parser) WriteXML(w io.Writer) error {
+	return writeXML(w, &File{App: p.loadedApps()})
+}
+
+// loadedApps returns the applications of all dictionary files loaded in
+// the Parser, in the order they were loaded.
+func (p *Parser) loadedApps() []*App {
+	var apps []*App
+	for _, f := range p.snapshot().file {
+		apps = append(apps, f.App...)
+	}
+	return apps
+}
+
+// mergeApps returns the applications merged by ID, in the order of their
+// first appearance. AVPs defined more than once in an application are
+// only kept once.
+func mergeApps(apps []*App) []*App {
+	var merged []*App
+	byID := make(map[uint32]*App)
+	avps := make(map[nameIdx]bool)
+	for _, app := range apps {
+		m, ok := byID[app.ID]
+		if !ok {
+			m = &App{ID: app.ID, Type: app.Type, Name: app.Name}
+			byID[app.ID] = m
+			merged = append(merged, m)
+		}
+		if app.Inherits != 0 {
+			m.Inherits = app.Inherits
+		}
+		m.Vendor = append(m.Vendor, app.Vendor...)
+		m.Command = append(m.Command, app.Command...)
+		for _, avp := range app.AVP {
+			idx := nameIdx{app.ID, avp.Name, avp.VendorID}
+			if !avps[idx] {
+				avps[idx] = true
+				m.AVP = append(m.AVP, avp)
+			}
+		}
+	}
+	return merged
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
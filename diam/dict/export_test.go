@@ -0,0 +1,174 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dict_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+func TestWriteXML(t *testing.T) {
+	var b bytes.Buffer
+	if err := dict.Default.WriteXML(&b); err != nil {
+		t.Fatal(err)
+	}
+	p, err := dict.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Load(bytes.NewReader(b.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Apps()) != len(dict.Default.Apps()) {
+		t.Fatalf("Unexpected # of apps. Want %d, have %d", len(dict.Default.Apps()), len(p.Apps()))
+	}
+	for _, app := range dict.Default.Apps() {
+		for _, want := range app.AVP {
+			have, err := p.FindAVPWithVendor(app.ID, want.Name, want.VendorID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have.Code != want.Code || have.Data.Type != want.Data.Type ||
+				have.FlagRules() != want.FlagRules() || len(have.Data.Rule) != len(want.Data.Rule) {
+				t.Errorf("Unexpected AVP %s. Want %#v, have %#v", want.Name, want, have)
+			}
+		}
+	}
+	var again bytes.Buffer
+	if err = p.WriteXML(&again); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != b.String() {
+		t.Error("Dictionary written differs once loaded again")
+	}
+}
+
+func TestWriteWireshark(t *testing.T) {
+	var b bytes.Buffer
+	if err := dict.Default.WriteWireshark(&b); err != nil {
+		t.Fatal(err)
+	}
+	type avp struct {
+		Name      string `xml:"name,attr"`
+		VendorID  string `xml:"vendor-id,attr"`
+		Mandatory string `xml:"mandatory,attr"`
+		VendorBit string `xml:"vendor-bit,attr"`
+	}
+	var ws struct {
+		Base struct {
+			AVP []avp `xml:"avp"`
+		} `xml:"base"`
+		App []struct {
+			ID      uint32 `xml:"id,attr"`
+			Command []struct {
+				Name string `xml:"name,attr"`
+			} `xml:"command"`
+			AVP []avp `xml:"avp"`
+		} `xml:"application"`
+		Vendor []struct {
+			ID   string `xml:"vendor-id,attr"`
+			Code uint32 `xml:"code,attr"`
+		} `xml:"vendor"`
+	}
+	if err := xml.Unmarshal(b.Bytes(), &ws); err != nil {
+		t.Fatal(err)
+	}
+	avps := make(map[string]avp)
+	for _, a := range ws.Base.AVP {
+		avps[a.Name] = a
+	}
+	var commands []string
+	for _, app := range ws.App {
+		if app.ID != 16777251 {
+			continue
+		}
+		for _, cmd := range app.Command {
+			commands = append(commands, cmd.Name)
+		}
+		for _, a := range app.AVP {
+			avps[a.Name] = a
+		}
+	}
+	if len(commands) == 0 || commands[0] != "Update-Location" {
+		t.Errorf("Unexpected S6a commands %v", commands)
+	}
+	for _, want := range []avp{
+		{Name: "Session-Id", Mandatory: "must", VendorBit: "mustnot"},
+		{Name: "Subscription-Data", VendorID: "TGPP", Mandatory: "must", VendorBit: "must"},
+	} {
+		if have := avps[want.Name]; have != want {
+			t.Errorf("Unexpected AVP. Want %#v, have %#v", want, have)
+		}
+	}
+	vendors := make(map[string]uint32)
+	for _, v := range ws.Vendor {
+		vendors[v.ID] = v.Code
+	}
+	if len(vendors) != 3 || vendors["TGPP"] != 10415 || vendors["ETSI"] != 13019 || vendors["TGPP2"] != 5535 {
+		t.Errorf("Unexpected vendors %#v", ws.Vendor)
+	}
+}
+
+func TestWriteJSONSchema(t *testing.T) {
+	var b bytes.Buffer
+	if err := dict.Default.WriteJSONSchema(&b); err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema["$schema"] != dict.JSONSchemaVersion {
+		t.Fatalf("Unexpected $schema %v", schema["$schema"])
+	}
+	sid := lookupJSON(t, schema, "#/$defs/Session-Id")
+	if sid["type"] != "string" || sid["x-diameter"].(map[string]interface{})["code"] != 263.0 {
+		t.Errorf("Unexpected Session-Id %v", sid)
+	}
+	ulr := lookupJSON(t, schema, "#/$defs/app-16777251/$defs/ULR")
+	if required := ulr["required"].([]interface{}); required[0] != "Session-Id" {
+		t.Errorf("Unexpected required AVPs of ULR %v", required)
+	}
+	pi := ulr["properties"].(map[string]interface{})["Proxy-Info"].(map[string]interface{})
+	if pi["type"] != "array" {
+		t.Errorf("Unexpected Proxy-Info of ULR %v", pi)
+	}
+	// All references must resolve.
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				lookupJSON(t, schema, ref)
+			}
+			for _, e := range v {
+				walk(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(schema)
+}
+
+// lookupJSON returns the object of the schema at the local reference ref.
+func lookupJSON(t *testing.T, schema map[string]interface{}, ref string) map[string]interface{} {
+	v := schema
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		next, ok := v[key].(map[string]interface{})
+		if !ok {
+			t.Fatalf("Cannot resolve %s", ref)
+		}
+		v = next
+	}
+	return v
+}
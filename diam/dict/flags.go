@@ -0,0 +1,117 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// AVP flag rules.  Part of go-diameter.
+
+package dict
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+)
+
+// FlagRules are the AVP flag rules of an AVP in the dictionary, parsed
+// from its must, may, must-not and may-encrypt attributes. See RFC 6733
+// section 4.5 for details.
+type FlagRules struct {
+	Must       uint8 // Flags the AVP must have
+	May        uint8 // Flags the AVP may have
+	MustNot    uint8 // Flags the AVP must not have
+	MayEncrypt bool
+}
+
+// FlagRules returns the flag rules of the AVP. The 'V' bit is always a
+// must for vendor specific AVPs, and a must not for the others.
+func (a *AVP) FlagRules() FlagRules {
+	r := FlagRules{
+		Must:       parseFlags(a.Must),
+		May:        parseFlags(a.May),
+		MustNot:    parseFlags(a.MustNot),
+		MayEncrypt: parseBool(a.MayEncrypt),
+	}
+	if a.VendorID != 0 {
+		r.Must |= avp.Vbit
+		r.MustNot &^= avp.Vbit
+	} else {
+		r.Must &^= avp.Vbit
+		r.MustNot |= avp.Vbit
+	}
+	r.May &^= r.Must | r.MustNot
+	return r
+}
+
+// Flags returns the default flags of the AVP: the flags it must have.
+func (a *AVP) Flags() uint8 {
+	return a.FlagRules().Must
+}
+
+// Check returns an error if flags do not comply with the rules.
+// The 'P' bit is reserved, and not checked.
+func (r FlagRules) Check(flags uint8) error {
+	if missing := r.Must &^ flags &^ avp.Pbit; missing != 0 {
+		return fmt.Errorf("missing flags %s", FlagsString(missing))
+	}
+	if invalid := r.MustNot & flags &^ avp.Pbit; invalid != 0 {
+		return fmt.Errorf("flags %s must not be set", FlagsString(invalid))
+	}
+	return nil
+}
+
+// String returns the rules in the format of the AVP flag rules tables
+// of RFC 6733, e.g. "must=M may=P must-not=V may-encrypt=N".
+func (r FlagRules) String() string {
+	encrypt := "N"
+	if r.MayEncrypt {
+		encrypt = "Y"
+	}
+	return fmt.Sprintf("must=%s may=%s must-not=%s may-encrypt=%s",
+		FlagsString(r.Must), FlagsString(r.May), FlagsString(r.MustNot), encrypt)
+}
+
+// FlagsString returns the 'V', 'M' and 'P' bits set in flags separated
+// by commas, or "-" if none is set.
+func FlagsString(flags uint8) string {
+	var s []string
+	for _, f := range []struct {
+		bit  uint8
+		name string
+	}{{avp.Vbit, "V"}, {avp.Mbit, "M"}, {avp.Pbit, "P"}} {
+		if flags&f.bit != 0 {
+			s = append(s, f.name)
+		}
+	}
+	if len(s) == 0 {
+		return "-"
+	}
+	return strings.Join(s, ",")
+}
+
+// parseFlags parses flags in the format of the dictionary attributes,
+// such as "M,V", "V M" or "-".
+func parseFlags(s string) uint8 {
+	var flags uint8
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}) {
+		switch strings.ToUpper(f) {
+		case "V":
+			flags |= avp.Vbit
+		case "M":
+			flags |= avp.Mbit
+		case "P":
+			flags |= avp.Pbit
+		}
+	}
+	return flags
+}
+
+func parseBool(s string) bool {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "Y", "YES":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dict_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+func TestAVPFlagRules(t *testing.T) {
+	for _, tc := range []struct {
+		avp   *dict.AVP
+		rules dict.FlagRules
+	}{
+		{
+			&dict.AVP{Must: "M", May: "P", MustNot: "V", MayEncrypt: "Y"},
+			dict.FlagRules{Must: avp.Mbit, May: avp.Pbit, MustNot: avp.Vbit, MayEncrypt: true},
+		},
+		{
+			&dict.AVP{Must: "V,M", May: "P", MustNot: "-", MayEncrypt: "N", VendorID: 10415},
+			dict.FlagRules{Must: avp.Vbit | avp.Mbit, May: avp.Pbit},
+		},
+		{
+			&dict.AVP{Must: "V", May: "P,M", VendorID: 10415},
+			dict.FlagRules{Must: avp.Vbit, May: avp.Mbit | avp.Pbit},
+		},
+		{
+			&dict.AVP{Must: "-", MustNot: "P,V,M"},
+			dict.FlagRules{MustNot: avp.Vbit | avp.Mbit | avp.Pbit},
+		},
+	} {
+		if rules := tc.avp.FlagRules(); rules != tc.rules {
+			t.Errorf("Unexpected rules for %#v. Want %s, have %s", tc.avp, tc.rules, rules)
+		}
+	}
+}
+
+func TestFlagRulesCheck(t *testing.T) {
+	rules := dict.FlagRules{Must: avp.Mbit, May: avp.Pbit, MustNot: avp.Vbit}
+	if err := rules.Check(avp.Mbit | avp.Pbit); err != nil {
+		t.Fatal(err)
+	}
+	if err := rules.Check(0); err == nil {
+		t.Fatal("Missing M-bit not detected")
+	}
+	if err := rules.Check(avp.Mbit | avp.Vbit); err == nil {
+		t.Fatal("Invalid V-bit not detected")
+	}
+}
+
+// TestDefaultFlagRules checks the consistency of the flag rules in the
+// default dictionaries.
+func TestDefaultFlagRules(t *testing.T) {
+	for _, app := range dict.Default.Apps() {
+		for _, a := range app.AVP {
+			if a.VendorID != 0 && (!strings.Contains(a.Must, "V") || strings.Contains(a.MustNot, "V")) {
+				t.Errorf("Vendor specific AVP %s (%d) of app %d without V-bit", a.Name, a.Code, app.ID)
+			}
+			if a.VendorID == 0 && strings.Contains(a.Must, "V") {
+				t.Errorf("AVP %s (%d) of app %d with V-bit and no vendor", a.Name, a.Code, app.ID)
+			}
+			if strings.Contains(a.Must, "M") && strings.Contains(a.MustNot, "M") {
+				t.Errorf("AVP %s (%d) of app %d must and must not have the M-bit", a.Name, a.Code, app.ID)
+			}
+		}
+	}
+}
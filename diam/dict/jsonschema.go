@@ -0,0 +1,219 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// JSON Schema dictionary export.  Part of go-diameter.
+
+package dict
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// JSONSchemaVersion is the JSON Schema dialect written by WriteJSONSchema.
+const JSONSchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// WriteJSONSchema writes the dictionaries loaded in the Parser to w as a
+// JSON Schema of diameter messages, where each AVP is a property named
+// after it.
+//
+// AVPs are defined by name in $defs, and the requests and answers of each
+// application in the $defs of "app-<id>", named after the short name of
+// their command, e.g. #/$defs/app-4/$defs/CCR. The schema validates any
+// of the requests and answers. Dictionary properties of applications,
+// commands and AVPs, such as codes and flag rules, are in "x-diameter"
+// annotations.
+func (p *Parser) WriteJSONSchema(w io.Writer) error {
+	apps := mergeApps(p.loadedApps())
+	defs := make(map[string]interface{})
+	var anyOf []interface{}
+	for _, app := range apps {
+		for _, a := range app.AVP {
+			if _, ok := defs[a.Name]; !ok {
+				defs[a.Name] = jsonAVP(a)
+			}
+		}
+	}
+	resolveGrouped(defs, apps)
+	for _, app := range apps {
+		key := fmt.Sprintf("app-%d", app.ID)
+		cmds := make(map[string]interface{})
+		for _, cmd := range app.Command {
+			for _, m := range []struct {
+				suffix, kind string
+				request      bool
+				rules        []*Rule
+			}{
+				{"R", "Request", true, cmd.Request.Rule},
+				{"A", "Answer", false, cmd.Answer.Rule},
+			} {
+				schema := jsonObject(defs, m.rules)
+				schema["title"] = cmd.Name + "-" + m.kind
+				schema["x-diameter"] = map[string]interface{}{
+					"application-id": app.ID,
+					"command-code":   cmd.Code,
+					"request":        m.request,
+				}
+				cmds[cmd.Short+m.suffix] = schema
+				anyOf = append(anyOf, map[string]interface{}{
+					"$ref": "#/$defs/" + key + "/$defs/" + cmd.Short + m.suffix,
+				})
+			}
+		}
+		defs[key] = map[string]interface{}{
+			"title": app.Name,
+			"x-diameter": map[string]interface{}{
+				"application-id": app.ID,
+				"type":           app.Type,
+			},
+			"$defs": cmds,
+		}
+	}
+	schema := map[string]interface{}{
+		"$schema": JSONSchemaVersion,
+		"title":   "Diameter messages",
+		"$defs":   defs,
+		"anyOf":   anyOf,
+	}
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// jsonAVP returns the schema of the data of the AVP a.
+func jsonAVP(a *AVP) map[string]interface{} {
+	var schema map[string]interface{}
+	switch a.Data.TypeName {
+	case "Grouped":
+		// Properties are added by resolveGrouped, once all AVPs
+		// are defined.
+		schema = map[string]interface{}{"type": "object"}
+	case "Enumerated":
+		schema = jsonInteger(math.MinInt32, math.MaxInt32)
+		var items []interface{}
+		for _, item := range a.Data.Enum {
+			items = append(items, map[string]interface{}{
+				"const": item.Code,
+				"title": item.Name,
+			})
+		}
+		if len(items) > 0 {
+			schema["oneOf"] = items
+		}
+	case "Integer32":
+		schema = jsonInteger(math.MinInt32, math.MaxInt32)
+	case "Integer64":
+		schema = map[string]interface{}{"type": "integer"}
+	case "Unsigned32":
+		schema = jsonInteger(0, int64(math.MaxUint32))
+	case "Unsigned64":
+		schema = jsonInteger(0, nil)
+	case "Float32", "Float64":
+		schema = map[string]interface{}{"type": "number"}
+	case "OctetString":
+		schema = map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+	case "Time":
+		schema = map[string]interface{}{"type": "string", "format": "date-time"}
+	case "IPv4":
+		schema = map[string]interface{}{"type": "string", "format": "ipv4"}
+	case "IPv6":
+		schema = map[string]interface{}{"type": "string", "format": "ipv6"}
+	case "Address":
+		schema = map[string]interface{}{"type": "string", "anyOf": []interface{}{
+			map[string]interface{}{"format": "ipv4"},
+			map[string]interface{}{"format": "ipv6"},
+		}}
+	default:
+		schema = map[string]interface{}{"type": "string"}
+	}
+	rules := a.FlagRules()
+	annotation := map[string]interface{}{
+		"code":        a.Code,
+		"type":        a.Data.TypeName,
+		"must":        FlagsString(rules.Must),
+		"may":         FlagsString(rules.May),
+		"must-not":    FlagsString(rules.MustNot),
+		"may-encrypt": rules.MayEncrypt,
+	}
+	if a.VendorID != 0 {
+		annotation["vendor-id"] = a.VendorID
+	}
+	schema["title"] = a.Name
+	schema["x-diameter"] = annotation
+	return schema
+}
+
+// resolveGrouped adds the properties of the Grouped AVPs in defs, once
+// all AVPs are defined.
+func resolveGrouped(defs map[string]interface{}, apps []*App) {
+	for _, app := range apps {
+		for _, a := range app.AVP {
+			schema, ok := defs[a.Name].(map[string]interface{})
+			if !ok || schema["type"] != "object" || schema["properties"] != nil {
+				continue
+			}
+			for k, v := range jsonObject(defs, a.Data.Rule) {
+				schema[k] = v
+			}
+		}
+	}
+}
+
+func jsonInteger(min, max interface{}) map[string]interface{} {
+	schema := map[string]interface{}{"type": "integer", "minimum": min}
+	if max != nil {
+		schema["maximum"] = max
+	}
+	return schema
+}
+
+// jsonObject returns the schema of an object with the AVPs of rules as
+// properties. Additional properties are only allowed by the "AVP" rule.
+func jsonObject(defs map[string]interface{}, rules []*Rule) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+	additional := false
+	for _, rule := range rules {
+		switch rule.AVP {
+		case "":
+			continue
+		case "AVP":
+			additional = true
+			continue
+		}
+		var prop map[string]interface{}
+		if _, ok := defs[rule.AVP]; ok {
+			prop = map[string]interface{}{"$ref": "#/$defs/" + rule.AVP}
+		} else {
+			prop = map[string]interface{}{"description": "AVP not in the dictionary"}
+		}
+		if rule.Max != 1 {
+			prop = map[string]interface{}{"type": "array", "items": prop}
+			if rule.Min > 0 {
+				prop["minItems"] = rule.Min
+			}
+			if rule.Max > 0 {
+				prop["maxItems"] = rule.Max
+			}
+		}
+		props[rule.AVP] = prop
+		if rule.Required || rule.Min > 0 {
+			required = append(required, rule.AVP)
+		}
+	}
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": additional,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,152 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Dictionary consistency checker.  Part of go-diameter.
+
+package dict
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LintError is an inconsistency of the dictionaries found by Lint.
+type LintError struct {
+	App      *App   // Application of the inconsistency
+	Filename string // File of the application, if loaded with LoadFile
+	Name     string // Name of the command or AVP
+	Err      string
+}
+
+// Error implements the error interface.
+func (e *LintError) Error() string {
+	return fmt.Sprintf("application %d: %s: %s", e.App.ID, e.Name, e.Err)
+}
+
+// Lint checks the consistency of the dictionaries loaded in the Parser,
+// which are loaded even if inconsistent, and returns all the
+// inconsistencies found, in the order of the dictionaries:
+//
+//   - Applications inheriting from applications not loaded.
+//   - Rules of commands and Grouped AVPs referencing AVPs not found in
+//     their application, its parents or the base application.
+//   - Grouped AVPs containing themselves, directly or through other
+//     Grouped AVPs.
+//   - AVPs sharing code and vendor with different data types.
+//   - Enumerated AVPs with duplicate item codes.
+func (p *Parser) Lint() []*LintError {
+	p.mu.Lock()
+	s := p.snapshot()
+	filenames := make(map[*File]string, len(p.sources))
+	for _, src := range p.sources {
+		filenames[src.file] = src.filename
+	}
+	p.mu.Unlock()
+	l := &linter{s: s, avps: make(map[codeIdx]*AVP), cycles: make(map[string]bool)}
+	for _, f := range s.file {
+		l.filename = filenames[f]
+		for _, app := range f.App {
+			l.lintApp(app)
+		}
+	}
+	return l.errs
+}
+
+// linter holds the state of Lint.
+type linter struct {
+	s        *snapshot
+	filename string // File being linted
+	errs     []*LintError
+	avps     map[codeIdx]*AVP // First AVP by code and vendor
+	cycles   map[string]bool  // Grouped AVP cycles reported
+}
+
+func (l *linter) errorf(app *App, name, format string, a ...interface{}) {
+	l.errs = append(l.errs, &LintError{App: app, Filename: l.filename, Name: name, Err: fmt.Sprintf(format, a...)})
+}
+
+func (l *linter) lintApp(app *App) {
+	if app.Inherits != 0 {
+		if _, ok := l.s.appcode[app.Inherits]; !ok {
+			l.errorf(app, app.Name, "inherits from application %d, which is not loaded", app.Inherits)
+		}
+	}
+	for _, cmd := range app.Command {
+		l.lintRules(app, cmd.Name+"-Request", cmd.Request.Rule)
+		l.lintRules(app, cmd.Name+"-Answer", cmd.Answer.Rule)
+	}
+	for _, avp := range app.AVP {
+		l.lintAVP(app, avp)
+	}
+}
+
+// lintRules checks the AVPs of rules are found in the application.
+func (l *linter) lintRules(app *App, name string, rules []*Rule) {
+	for _, rule := range rules {
+		switch rule.AVP {
+		case "AVP":
+			continue
+		case "":
+			l.errorf(app, name, "rule without AVP name")
+			continue
+		}
+		if _, err := l.s.findAVPWithVendorRecursive(app.ID, rule.AVP, UndefinedVendorID, app.ID); err != nil {
+			l.errorf(app, name, "rule references undefined AVP %q", rule.AVP)
+		}
+	}
+}
+
+func (l *linter) lintAVP(app *App, avp *AVP) {
+	idx := codeIdx{code: avp.Code, vendorID: avp.VendorID}
+	if first, ok := l.avps[idx]; !ok {
+		l.avps[idx] = avp
+	} else if first.Data.TypeName != avp.Data.TypeName {
+		l.errorf(app, avp.Name, "code %d and vendor %d of %s AVP in application %d, with %s data type instead of %s",
+			avp.Code, avp.VendorID, first.Name, first.App.ID, avp.Data.TypeName, first.Data.TypeName)
+	}
+	if len(avp.Data.Enum) > 0 {
+		items := make(map[int32]*Enum)
+		for _, item := range avp.Data.Enum {
+			if dup, ok := items[item.Code]; ok {
+				l.errorf(app, avp.Name, "items %s and %s have the same code %d", dup.Name, item.Name, item.Code)
+				continue
+			}
+			items[item.Code] = item
+		}
+	}
+	if len(avp.Data.Rule) > 0 {
+		l.lintRules(app, avp.Name, avp.Data.Rule)
+		if path := l.groupedCycle(app.ID, avp, []string{avp.Name}, make(map[*AVP]bool)); path != nil {
+			// Report each cycle once, for its first AVP.
+			members := append([]string(nil), path[:len(path)-1]...)
+			sort.Strings(members)
+			key := fmt.Sprint(app.ID, members)
+			if !l.cycles[key] {
+				l.cycles[key] = true
+				l.errorf(app, avp.Name, "Grouped AVP contains itself: %s", strings.Join(path, " -> "))
+			}
+		}
+	}
+}
+
+// groupedCycle returns the path from the first AVP of path to itself
+// through the rules of avp, or nil if there is none. Grouped AVPs in
+// visited are not searched again.
+func (l *linter) groupedCycle(appid uint32, avp *AVP, path []string, visited map[*AVP]bool) []string {
+	visited[avp] = true
+	for _, rule := range avp.Data.Rule {
+		if rule.AVP == path[0] {
+			return append(path, rule.AVP)
+		}
+		child, err := l.s.findAVPWithVendorRecursive(appid, rule.AVP, UndefinedVendorID, appid)
+		if err != nil || len(child.Data.Rule) == 0 || visited[child] {
+			continue
+		}
+		if cycle := l.groupedCycle(appid, child, append(path, rule.AVP), visited); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dict_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+var lintXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="1000" name="Lint" inherits="2000">
+		<command code="1000" short="T" name="Test">
+			<request>
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Undefined-AVP" required="false"/>
+				<rule avp="AVP" required="false"/>
+			</request>
+			<answer>
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="true" max="1"/>
+			</answer>
+		</command>
+		<avp name="Self-Grouped" code="1001">
+			<data type="Grouped">
+				<rule avp="Self-Grouped" required="false"/>
+			</data>
+		</avp>
+		<avp name="Grouped-A" code="1002">
+			<data type="Grouped">
+				<rule avp="Session-Id" required="false"/>
+				<rule avp="Grouped-B" required="false"/>
+			</data>
+		</avp>
+		<avp name="Grouped-B" code="1003">
+			<data type="Grouped">
+				<rule avp="Grouped-A" required="false"/>
+			</data>
+		</avp>
+		<avp name="Other-Session-Id" code="263">
+			<data type="Unsigned32"/>
+		</avp>
+		<avp name="Test-Enum" code="1004">
+			<data type="Enumerated">
+				<item code="0" name="ZERO"/>
+				<item code="1" name="ONE"/>
+				<item code="1" name="UNO"/>
+			</data>
+		</avp>
+	</application>
+</diameter>`
+
+func TestLint(t *testing.T) {
+	p, err := dict.NewParser("./testdata/base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "lint.xml")
+	if err = os.WriteFile(name, []byte(lintXML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.LoadFile(name); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"application 1000: Lint: inherits from application 2000, which is not loaded",
+		`application 1000: Test-Request: rule references undefined AVP "Undefined-AVP"`,
+		"application 1000: Self-Grouped: Grouped AVP contains itself: Self-Grouped -> Self-Grouped",
+		"application 1000: Grouped-A: Grouped AVP contains itself: Grouped-A -> Grouped-B -> Grouped-A",
+		"application 1000: Other-Session-Id: code 263 and vendor 0 of Session-Id AVP in application 0, with Unsigned32 data type instead of UTF8String",
+		"application 1000: Test-Enum: items ONE and UNO have the same code 1",
+	}
+	var have []string
+	for _, err := range p.Lint() {
+		if err.Filename == name {
+			have = append(have, err.Error())
+		}
+	}
+	if len(have) != len(want) {
+		t.Fatalf("Unexpected lint errors.\nWant %q\nHave %q", want, have)
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("Unexpected lint error.\nWant %s\nHave %s", want[i], have[i])
+		}
+	}
+}
+
+func TestLint_Default(t *testing.T) {
+	for _, err := range dict.Default.Lint() {
+		t.Error(err)
+	}
+}
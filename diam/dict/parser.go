@@ -13,6 +13,7 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
 )
@@ -31,15 +32,36 @@ const (
 // multiple applications that are composed by multiple AVPs.
 //
 // The Parser element has an index to make pre-loaded AVPs searcheable per App.
+//
+// The dictionaries of the Parser are immutable snapshots: loads build a
+// new snapshot with a copy of the index, that atomically replaces the
+// current one. Lookups never block and may run concurrently with loads
+// and Reload. Use Snapshot to keep using the same dictionaries for a
+// series of lookups.
 type Parser struct {
+	snap    atomic.Pointer[snapshot] // Current dictionaries
+	mu      sync.Mutex               // Serializes loads
+	sources []*source                // Loaded dictionaries, for Reload
+	parents map[uint32]uint32        // Parent applications set with SetParent
+}
+
+// snapshot is an immutable version of the dictionaries of a Parser.
+type snapshot struct {
+	version uint64                // Incremented by each load
 	file    []*File               // Dict supports multiple XML dictionaries
 	appcode map[uint32]*App       // Application index by code
 	apptype map[appIdTypeIdx]*App // Application index by code and type
 	avpname map[nameIdx]*AVP      // AVP index by name
 	avpcode map[codeIdx]*AVP      // AVP index by code
 	command map[codeIdx]*Command  // Command index
-	mu      sync.Mutex            // Protects all maps
-	once    sync.Once
+	parent  map[uint32]uint32     // Parent application index by code
+}
+
+// source is a dictionary file loaded in a Parser.
+type source struct {
+	file     *File
+	filename string // Name of files loaded with LoadFile
+	data     []byte // Contents of files loaded with LoadFile
 }
 
 type codeIdx struct {
@@ -59,6 +81,8 @@ type appIdTypeIdx struct {
 	typ   string
 }
 
+var emptySnapshot = &snapshot{}
+
 // NewParser allocates a new Parser optionally loading dictionary XML files.
 func NewParser(filename ...string) (*Parser, error) {
 	p := new(Parser)
@@ -71,55 +95,258 @@ func NewParser(filename ...string) (*Parser, error) {
 	return p, nil
 }
 
+// snapshot returns the current dictionaries of the Parser.
+func (p *Parser) snapshot() *snapshot {
+	if s := p.snap.Load(); s != nil {
+		return s
+	}
+	return emptySnapshot
+}
+
+// Snapshot returns a Parser with the current dictionaries of p, that are
+// not affected by later loads or reloads of p. Messages keep a snapshot
+// of their dictionary, so they are decoded and encoded consistently.
+func (p *Parser) Snapshot() *Parser {
+	s := new(Parser)
+	s.snap.Store(p.snapshot())
+	return s
+}
+
+// Version returns the version of the dictionaries of the Parser, that is
+// incremented each time dictionaries are loaded or reloaded, and each
+// time a parent application is set.
+func (p *Parser) Version() uint64 {
+	return p.snapshot().version
+}
+
 // LoadFile loads a dictionary XML file. May be used multiple times.
+// The file is read again by Reload.
 func (p *Parser) LoadFile(filename string) error {
-	fd, err := os.Open(filename)
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	f, err := decodeFile(b)
 	if err != nil {
 		return err
 	}
-	defer fd.Close()
-	return p.Load(fd)
+	return p.load(&source{file: f, filename: filename, data: b})
+}
+
+// LoadFileOnce loads the dictionary file filename as LoadFile, unless it
+// is one of the default dictionaries, byte for byte, already loaded in
+// the Parser, as in Default and its snapshots. Files extending default
+// applications are always loaded. It returns the contents of the file in
+// both cases.
+func (p *Parser) LoadFileOnce(filename string) (*File, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	f, err := decodeFile(b)
+	if err != nil {
+		return nil, err
+	}
+	if p.hasDefault(b) {
+		return f, nil
+	}
+	// The loaded copy becomes part of the dictionaries, which must not
+	// be modified by the caller.
+	loaded, _ := decodeFile(b)
+	if err = p.load(&source{file: loaded, filename: filename, data: b}); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// hasDefault reports whether b is the contents of one of the default
+// dictionaries, and it is loaded in the Parser.
+func (p *Parser) hasDefault(b []byte) bool {
+	// The default dictionaries are embedded without the final newline
+	// of their files.
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	defaults := Default.snapshot().file
+	for i, d := range defaultDictionaries {
+		if i >= len(defaults) || d.xml != string(b) {
+			continue
+		}
+		for _, f := range p.snapshot().file {
+			if f == defaults[i] {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // Load loads a dictionary from byte array. May be used multiple times.
 func (p *Parser) Load(r io.Reader) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.once.Do(func() {
-		p.appcode = make(map[uint32]*App)
-		p.apptype = make(map[appIdTypeIdx]*App)
-		p.avpname = make(map[nameIdx]*AVP)
-		p.avpcode = make(map[codeIdx]*AVP)
-		p.command = make(map[codeIdx]*Command)
-	})
 	f := new(File)
 	d := xml.NewDecoder(r)
 	if err := d.Decode(f); err != nil {
 		return err
 	}
-	p.file = append(p.file, f)
+	return p.loadFile(f)
+}
+
+// loadFile loads the applications of the dictionary file f.
+func (p *Parser) loadFile(f *File) error {
+	return p.load(&source{file: f})
+}
+
+// load adds the dictionary src to a copy of the current snapshot, and
+// makes it current. The snapshot is left unchanged on errors.
+func (p *Parser) load(src *source) error {
+	if err := prepareFile(src.file); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.snapshot().clone()
+	if err := s.add(src.file); err != nil {
+		return err
+	}
+	if err := s.setParents(p.parents); err != nil {
+		return err
+	}
+	p.sources = append(p.sources, src)
+	p.snap.Store(s)
+	return nil
+}
+
+// Reload reads again the dictionary files loaded with LoadFile, and if
+// any of them changed, atomically replaces the dictionaries with new
+// ones. Lookups use the previous dictionaries until Reload returns, and
+// the dictionaries are left unchanged on errors.
+func (p *Parser) Reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sources := make([]*source, len(p.sources))
+	changed := false
+	for i, src := range p.sources {
+		sources[i] = src
+		if src.filename == "" {
+			continue
+		}
+		b, err := os.ReadFile(src.filename)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(b, src.data) {
+			continue
+		}
+		f, err := decodeFile(b)
+		if err == nil {
+			err = prepareFile(f)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", src.filename, err)
+		}
+		sources[i] = &source{file: f, filename: src.filename, data: b}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	s := &snapshot{version: p.snapshot().version}
+	s.init()
+	for _, src := range sources {
+		if err := s.add(src.file); err != nil {
+			return err
+		}
+	}
+	if err := s.setParents(p.parents); err != nil {
+		return err
+	}
+	s.version++
+	p.sources = sources
+	p.snap.Store(s)
+	return nil
+}
+
+func decodeFile(b []byte) (*File, error) {
+	f := new(File)
+	if err := xml.Unmarshal(b, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *snapshot) init() {
+	s.appcode = make(map[uint32]*App)
+	s.apptype = make(map[appIdTypeIdx]*App)
+	s.avpname = make(map[nameIdx]*AVP)
+	s.avpcode = make(map[codeIdx]*AVP)
+	s.command = make(map[codeIdx]*Command)
+	s.parent = make(map[uint32]uint32)
+}
+
+// clone returns a copy of the snapshot with the next version.
+func (s *snapshot) clone() *snapshot {
+	c := &snapshot{version: s.version + 1}
+	c.init()
+	c.file = append(c.file, s.file...)
+	for k, v := range s.appcode {
+		c.appcode[k] = v
+	}
+	for k, v := range s.apptype {
+		c.apptype[k] = v
+	}
+	for k, v := range s.avpname {
+		c.avpname[k] = v
+	}
+	for k, v := range s.avpcode {
+		c.avpcode[k] = v
+	}
+	for k, v := range s.command {
+		c.command[k] = v
+	}
+	for k, v := range s.parent {
+		c.parent[k] = v
+	}
+	return c
+}
+
+// add indexes the applications of the dictionary file f, that must have
+// been prepared with prepareFile.
+func (s *snapshot) add(f *File) error {
+	s.file = append(s.file, f)
 	for _, app := range f.App {
 		// Cache supported applications by ID.
-		p.appcode[app.ID] = app
-		p.apptype[appIdTypeIdx{app.ID, app.Type}] = app
+		s.appcode[app.ID] = app
+		s.apptype[appIdTypeIdx{app.ID, app.Type}] = app
+		if app.Inherits != 0 {
+			s.parent[app.ID] = app.Inherits
+		}
 		// Cache commands.
 		for _, cmd := range app.Command {
 			idx := codeIdx{app.ID, cmd.Code, UndefinedVendorID}
-			_, exist := p.command[idx]
+			_, exist := s.command[idx]
 			if exist {
 				return fmt.Errorf("command: %s cannot be added: index exists", cmd)
 			}
-			p.command[idx] = cmd
+			s.command[idx] = cmd
 		}
 		// Cache AVPs.
+		for _, avp := range app.AVP {
+			s.avpname[nameIdx{app.ID, avp.Name, avp.VendorID}] = avp
+			s.avpcode[codeIdx{app.ID, avp.Code, avp.VendorID}] = avp
+			// Index without vendorId
+			s.avpname[nameIdx{app.ID, avp.Name, UndefinedVendorID}] = avp
+			s.avpcode[codeIdx{app.ID, avp.Code, UndefinedVendorID}] = avp
+		}
+	}
+	return nil
+}
+
+// prepareFile links the AVPs of the dictionary file f to their
+// application and checks their types. Files are prepared once, before
+// being added to snapshots, which are not modified once current.
+func prepareFile(f *File) error {
+	for _, app := range f.App {
 		for _, avp := range app.AVP {
 			// Link AVP to its Application
 			avp.App = app
-			p.avpname[nameIdx{app.ID, avp.Name, avp.VendorID}] = avp
-			p.avpcode[codeIdx{app.ID, avp.Code, avp.VendorID}] = avp
-			// Index without vendorId
-			p.avpname[nameIdx{app.ID, avp.Name, UndefinedVendorID}] = avp
-			p.avpcode[codeIdx{app.ID, avp.Code, UndefinedVendorID}] = avp
 			// Check the AVP type.
 			if err := updateType(avp); err != nil {
 				return err
@@ -141,7 +368,7 @@ func updateType(a *AVP) error {
 // String returns the Parser represented in a human readable form.
 func (p *Parser) String() string {
 	var b bytes.Buffer
-	for _, f := range p.file {
+	for _, f := range p.snapshot().file {
 		for _, app := range f.App {
 			fmt.Fprintf(&b, "Application Id: %d\n", app.ID)
 			fmt.Fprintf(&b, "\tVendors:\n")
@@ -164,20 +391,23 @@ func (p *Parser) String() string {
 func printCommand(w io.Writer, cmd *Command) {
 	fmt.Fprintf(w, "\t\t%-4d %s-Request (%sR)\n", cmd.Code, cmd.Name, cmd.Short)
 	for _, rule := range cmd.Request.Rule {
-		if rule.Required && rule.Min == 0 {
-			rule.Min = 1
-		}
-		fmt.Fprintf(w, "\t\t\t% -40s required=%-5t min=%d max=%d\n",
-			rule.AVP, rule.Required, rule.Min, rule.Max)
+		printRule(w, rule)
 	}
 	fmt.Fprintf(w, "\t\t%-4d %s-Answer (%sA)\n", cmd.Code, cmd.Name, cmd.Short)
 	for _, rule := range cmd.Answer.Rule {
-		if rule.Required && rule.Min == 0 {
-			rule.Min = 1
-		}
-		fmt.Fprintf(w, "\t\t\t% -40s required=%-5t min=%d max=%d\n",
-			rule.AVP, rule.Required, rule.Min, rule.Max)
+		printRule(w, rule)
+	}
+}
+
+// printRule prints a rule of a command. Required AVPs have a minimum of
+// one.
+func printRule(w io.Writer, rule *Rule) {
+	min := rule.Min
+	if rule.Required && min == 0 {
+		min = 1
 	}
+	fmt.Fprintf(w, "\t\t\t% -40s required=%-5t min=%d max=%d\n",
+		rule.AVP, rule.Required, min, rule.Max)
 }
 
 func printAVP(w io.Writer, avp *AVP) {
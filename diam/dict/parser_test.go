@@ -5,7 +5,11 @@
 package dict_test
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/ctrlzy/go-diameter/v4/diam/dict"
@@ -41,6 +45,42 @@ func TestLoadFile(t *testing.T) {
 	}
 }
 
+func TestLoadFileOnce(t *testing.T) {
+	p := dict.Default.Snapshot()
+	version := p.Version()
+	f, err := p.LoadFileOnce("./testdata/tgpp_s6a.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.App) != 1 || f.App[0].ID != 16777251 {
+		t.Fatalf("Unexpected applications %v", f.App)
+	}
+	if p.Version() != version {
+		t.Fatal("Default dictionary loaded again")
+	}
+	// Files extending the default applications are loaded.
+	name := filepath.Join(t.TempDir(), "s6a.xml")
+	ext := `<diameter><application id="16777251">
+		<avp name="Test-AVP" code="99999" must="V" vendor-id="10415"><data type="UTF8String"/></avp>
+	</application></diameter>`
+	if err = os.WriteFile(name, []byte(ext), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.LoadFileOnce(name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.FindAVP(16777251, "Test-AVP"); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = dict.NewParser()
+	if _, err = p.LoadFileOnce("./testdata/base.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.App(0); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestLoad(t *testing.T) {
 	for _, newDict := range testDicts {
 		f, err := os.Open(newDict)
@@ -54,3 +94,123 @@ func TestLoad(t *testing.T) {
 		}
 	}
 }
+
+const testReloadDict = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="1000">
+		<avp name="Test-AVP" code="%d">
+			<data type="Unsigned32"/>
+		</avp>
+	</application>
+</diameter>`
+
+func TestReload(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.xml")
+	if err := os.WriteFile(name, []byte(fmt.Sprintf(testReloadDict, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := dict.NewParser("./testdata/base.xml", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version() != 2 {
+		t.Fatalf("Unexpected version. Want 2, have %d", p.Version())
+	}
+	snap := p.Snapshot()
+	// Unchanged files are not reloaded.
+	if err = p.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Version() != 2 {
+		t.Fatalf("Unexpected version after Reload. Want 2, have %d", p.Version())
+	}
+	if err = os.WriteFile(name, []byte(fmt.Sprintf(testReloadDict, 2)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Version() != 3 {
+		t.Fatalf("Unexpected version after Reload. Want 3, have %d", p.Version())
+	}
+	avp, err := p.FindAVP(1000, "Test-AVP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if avp.Code != 2 {
+		t.Errorf("Unexpected code of reloaded AVP. Want 2, have %d", avp.Code)
+	}
+	if _, err = p.FindAVP(1000, "Session-Id"); err != nil {
+		t.Error(err)
+	}
+	// Snapshots keep the previous dictionaries.
+	if avp, err = snap.FindAVP(1000, "Test-AVP"); err != nil || avp.Code != 1 {
+		t.Errorf("Unexpected AVP of snapshot %v: %v", avp, err)
+	}
+	if snap.Version() != 2 {
+		t.Errorf("Unexpected version of snapshot. Want 2, have %d", snap.Version())
+	}
+	// Invalid files leave the dictionaries unchanged.
+	if err = os.WriteFile(name, []byte("<diameter>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Reload(); err == nil {
+		t.Fatal("Reload of an invalid file did not fail")
+	}
+	if avp, err = p.FindAVP(1000, "Test-AVP"); err != nil || avp.Code != 2 || p.Version() != 3 {
+		t.Errorf("Unexpected AVP after failed Reload %v: %v", avp, err)
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	p, err := dict.NewParser("./testdata/base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, apps := p.Version(), len(p.Apps())
+	if err = p.LoadFile("./testdata/base.xml"); err == nil {
+		t.Fatal("Loading commands twice did not fail")
+	}
+	if p.Version() != version || len(p.Apps()) != apps {
+		t.Errorf("Failed load changed the dictionaries")
+	}
+}
+
+func TestConcurrentLoad(t *testing.T) {
+	p, err := dict.NewParser("./testdata/base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			err := p.Load(bytes.NewBufferString(fmt.Sprintf(
+				`<diameter><application id="%d"><avp name="Test-AVP" code="1"><data type="Unsigned32"/></avp></application></diameter>`,
+				2000+i)))
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := p.FindAVP(4, "Session-Id"); err != nil {
+					t.Error(err)
+					return
+				}
+				_ = p.Apps()
+			}
+		}()
+	}
+	wg.Wait()
+	if p.Version() != 11 {
+		t.Errorf("Unexpected version. Want 11, have %d", p.Version())
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := p.App(uint32(2000 + i)); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -20,13 +20,17 @@ type File struct {
 }
 
 // App defines a diameter application in XML and its multiple AVPs.
+//
+// AVPs not defined in an application are searched in the application it
+// inherits from, if any, and then in the base application.
 type App struct {
-	ID      uint32     `xml:"id,attr"`   // Application Id
-	Type    string     `xml:"type,attr"` // Application type
-	Name    string     `xml:"name,attr"` // Application name
-	Vendor  []*Vendor  `xml:"vendor"`    // Support for multiple vendors
-	Command []*Command `xml:"command"`   // Diameter commands
-	AVP     []*AVP     `xml:"avp"`       // Each application support multiple AVPs
+	ID       uint32     `xml:"id,attr"`                 // Application Id
+	Type     string     `xml:"type,attr,omitempty"`     // Application type
+	Name     string     `xml:"name,attr"`               // Application name
+	Inherits uint32     `xml:"inherits,attr,omitempty"` // Parent application Id
+	Vendor   []*Vendor  `xml:"vendor"`                  // Support for multiple vendors
+	Command  []*Command `xml:"command"`                 // Diameter commands
+	AVP      []*AVP     `xml:"avp"`                     // Each application support multiple AVPs
 }
 
 // Vendor defines diameter vendors in XML, that can be used to translate
@@ -61,13 +65,13 @@ type CommandRule struct {
 type AVP struct {
 	Name       string `xml:"name,attr"`
 	Code       uint32 `xml:"code,attr"`
-	Must       string `xml:"must,attr"`
-	May        string `xml:"may,attr"`
-	MustNot    string `xml:"must-not,attr"`
-	MayEncrypt string `xml:"may-encrypt,attr"`
-	VendorID   uint32 `xml:"vendor-id,attr"`
+	Must       string `xml:"must,attr,omitempty"`
+	May        string `xml:"may,attr,omitempty"`
+	MustNot    string `xml:"must-not,attr,omitempty"`
+	MayEncrypt string `xml:"may-encrypt,attr,omitempty"`
+	VendorID   uint32 `xml:"vendor-id,attr,omitempty"`
 	Data       Data   `xml:"data"`
-	App        *App   `xml:"none"` // Link back to diameter application
+	App        *App   `xml:"-"` // Link back to diameter application
 }
 
 // Data of an AVP can be EnumItem or a Parser of multiple AVPs.
@@ -90,6 +94,6 @@ type Enum struct {
 type Rule struct {
 	AVP      string `xml:"avp,attr"` // AVP Name
 	Required bool   `xml:"required,attr"`
-	Min      int    `xml:"min,attr"`
-	Max      int    `xml:"max,attr"`
+	Min      int    `xml:"min,attr,omitempty"`
+	Max      int    `xml:"max,attr,omitempty"`
 }
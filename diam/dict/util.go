@@ -9,27 +9,77 @@ package dict
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
 )
 
-// parentAppIds map allows for hierarchical AVP search dependencies
-// If an AVP code was not found in the app's dictionary, the search will continue in the parent app
-// dictionary and only then in base diameter dictionary
-// Note: care must be taken to avoid creating parent-child loops
-var parentAppIds map[uint32]uint32 = map[uint32]uint32{
-	16777251: 4,
-	16777238: 4,
-	4:        1,
+// SetParent sets parent as the application where AVPs not found in the
+// application appid are searched, before the base application. It overrides
+// the inherits attribute of the application in the dictionaries, also
+// for later loads and reloads. A parent of 0 searches the base
+// application only.
+//
+// SetParent fails if the application hierarchy would have a cycle.
+func (p *Parser) SetParent(appid, parent uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	parents := map[uint32]uint32{appid: parent}
+	for k, v := range p.parents {
+		if k != appid {
+			parents[k] = v
+		}
+	}
+	s := p.snapshot().clone()
+	if err := s.setParents(parents); err != nil {
+		return err
+	}
+	p.parents = parents
+	p.snap.Store(s)
+	return nil
+}
+
+// Parent returns the application where AVPs not found in the application
+// appid are searched, or 0 for the base application only.
+func (p *Parser) Parent(appid uint32) uint32 {
+	return p.snapshot().parent[appid]
+}
+
+// setParents sets the parent applications of parents in the snapshot,
+// and checks the application hierarchy has no cycles.
+func (s *snapshot) setParents(parents map[uint32]uint32) error {
+	for k, v := range parents {
+		s.parent[k] = v
+	}
+	for appid := range s.parent {
+		path := []uint32{appid}
+		seen := map[uint32]bool{appid: true}
+		for id := s.parent[appid]; id != 0; id = s.parent[id] {
+			path = append(path, id)
+			if seen[id] {
+				return fmt.Errorf("application %d inherits from itself: %s", appid, formatPath(path))
+			}
+			seen[id] = true
+		}
+	}
+	return nil
+}
+
+func formatPath(path []uint32) string {
+	var b strings.Builder
+	for i, id := range path {
+		if i > 0 {
+			b.WriteString(" -> ")
+		}
+		fmt.Fprintf(&b, "%d", id)
+	}
+	return b.String()
 }
 
 // Apps return a list of all applications loaded in the Parser object.
-// Apps must never be called concurrently with LoadFile or Load.
 func (p *Parser) Apps() []*App {
-	//p.mu.Lock()
-	//defer p.mu.Unlock()
 	var apps []*App
-	for _, f := range p.file {
+	for _, f := range p.snapshot().file {
 		for _, app := range f.App {
 			apps = append(apps, app)
 		}
@@ -38,16 +88,17 @@ func (p *Parser) Apps() []*App {
 }
 
 // App returns a dictionary application for the given application code
-// if exists. App must never be called concurrently with LoadFile or Load.
+// if exists.
 func (p *Parser) App(code uint32, typ ...string) (*App, error) {
+	s := p.snapshot()
 	var app *App
 	if len(typ) > 0 {
-		app = p.apptype[appIdTypeIdx{code, typ[0]}]
+		app = s.apptype[appIdTypeIdx{code, typ[0]}]
 	}
 	if app != nil {
 		return app, nil
 	}
-	app = p.appcode[code]
+	app = s.appcode[code]
 	if app != nil && (len(app.Type) == 0 || len(typ) == 0 || app.Type == typ[0]) {
 		return app, nil
 	}
@@ -79,14 +130,12 @@ func MakeUnknownAVP(appid, code, vendorID uint32) *AVP {
 // If the AVP code is not found for the given appid it tries with appid=0
 // before returning an error.
 // Code can be either the AVP code (int, uint32) or name (string).
-//
-// FindAVPWithVendor must never be called concurrently with LoadFile or Load.
 func (p *Parser) FindAVPWithVendor(appid uint32, code interface{}, vendorID uint32) (*AVP, error) {
-	return p.findAVPWithVendorRecursive(appid, code, vendorID, appid)
+	return p.snapshot().findAVPWithVendorRecursive(appid, code, vendorID, appid)
 }
 
 // find AVP recursively in the dictionary
-func (p *Parser) findAVPWithVendorRecursive(appid uint32, code interface{}, vendorID uint32, origAppID uint32) (*AVP, error) {
+func (s *snapshot) findAVPWithVendorRecursive(appid uint32, code interface{}, vendorID uint32, origAppID uint32) (*AVP, error) {
 	var (
 		avp *AVP
 		ok  bool
@@ -94,11 +143,11 @@ func (p *Parser) findAVPWithVendorRecursive(appid uint32, code interface{}, vend
 
 	switch codeVal := code.(type) {
 	case string:
-		avp, ok = p.avpname[nameIdx{appid, codeVal, vendorID}]
+		avp, ok = s.avpname[nameIdx{appid, codeVal, vendorID}]
 	case uint32:
-		avp, ok = p.avpcode[codeIdx{appid, uint32(codeVal), vendorID}]
+		avp, ok = s.avpcode[codeIdx{appid, uint32(codeVal), vendorID}]
 	case int:
-		avp, ok = p.avpcode[codeIdx{appid, uint32(codeVal), vendorID}]
+		avp, ok = s.avpcode[codeIdx{appid, uint32(codeVal), vendorID}]
 	default:
 		return nil, fmt.Errorf("unsupported AVP code type %T(%#v)", codeVal, code)
 	}
@@ -116,22 +165,15 @@ func (p *Parser) findAVPWithVendorRecursive(appid uint32, code interface{}, vend
 		return nil, fmt.Errorf("could not find AVP %v for Vendor: %d", code, vendorID)
 	}
 
-	// didn't find in current app, go to parent app for search
-	nextApp := uint32(0)
-	if parentAppId, isScopedApp := parentAppIds[appid]; isScopedApp {
-		nextApp = parentAppId
-	}
-
-	// didn't find the parent app, go to base app for search
-	return p.findAVPWithVendorRecursive(nextApp, code, vendorID, origAppID)
+	// didn't find in current app, go to parent app for search, or to
+	// base app if it has none
+	return s.findAVPWithVendorRecursive(s.parent[appid], code, vendorID, origAppID)
 }
 
 // FindAVP is a helper function that returns a pre-loaded AVP from the Parser.
 // If the AVP code is not found for the given appid it tries with appid=0
 // before returning an error.
 // Code can be either the AVP code (int, uint32) or name (string).
-//
-// FindAVP must never be called concurrently with LoadFile or Load.
 func (p *Parser) FindAVP(appid uint32, code interface{}) (*AVP, error) {
 	return p.FindAVPWithVendor(appid, code, UndefinedVendorID)
 }
@@ -142,28 +184,25 @@ func (p *Parser) FindAVP(appid uint32, code interface{}) (*AVP, error) {
 //
 // ScanAVP is 20x or more slower than FindAVP. Use with care.
 // Code can be either the AVP code (uint32) or name (string).
-//
-// ScanAVP must never be called concurrently with LoadFile or Load.
 func (p *Parser) ScanAVP(code interface{}) (*AVP, error) {
-	//p.mu.Lock()
-	//defer p.mu.Unlock()
+	s := p.snapshot()
 	switch codeVal := code.(type) {
 	case string:
-		for idx, avp := range p.avpname {
+		for idx, avp := range s.avpname {
 			if idx.name == codeVal {
 				return avp, nil
 			}
 		}
 		return nil, fmt.Errorf("could not find AVP %s", code.(string))
 	case uint32:
-		for idx, avp := range p.avpcode {
+		for idx, avp := range s.avpcode {
 			if idx.code == codeVal {
 				return avp, nil
 			}
 		}
 		return nil, fmt.Errorf("could not find AVP code %d", code.(uint32))
 	case int:
-		for idx, avp := range p.avpcode {
+		for idx, avp := range s.avpcode {
 			if idx.code == uint32(codeVal) {
 				return avp, nil
 			}
@@ -174,16 +213,13 @@ func (p *Parser) ScanAVP(code interface{}) (*AVP, error) {
 }
 
 // FindCommand returns a pre-loaded Command from the Parser.
-//
-// FindCommand must never be called concurrently with LoadFile or Load.
 func (p *Parser) FindCommand(appid, code uint32) (*Command, error) {
-	//p.mu.Lock()
-	//defer p.mu.Unlock()
-	if cmd, ok := p.command[codeIdx{appid, code, UndefinedVendorID}]; ok {
+	s := p.snapshot()
+	if cmd, ok := s.command[codeIdx{appid, code, UndefinedVendorID}]; ok {
 		return cmd, nil
 	}
 	// Always fall back to base dict.
-	if cmd, ok := p.command[codeIdx{0, code, UndefinedVendorID}]; ok {
+	if cmd, ok := s.command[codeIdx{0, code, UndefinedVendorID}]; ok {
 		return cmd, nil
 	}
 	return nil, fmt.Errorf("could not find preloaded Command with code %d", code)
@@ -191,16 +227,12 @@ func (p *Parser) FindCommand(appid, code uint32) (*Command, error) {
 
 // Enum is a helper function that returns a pre-loaded Enum item for the
 // given AVP appid, code and n. (n is the enum code in the dictionary)
-//
-// Enum must never be called concurrently with LoadFile or Load.
-func (p *Parser) Enum(appid, code uint32, n int32) (*Enum, error) {
-	avp, err := p.FindAVP(appid, code)
+// Code can be either the AVP code (int, uint32) or name (string).
+func (p *Parser) Enum(appid uint32, code interface{}, n int32) (*Enum, error) {
+	avp, err := p.findEnumAVP(appid, code)
 	if err != nil {
 		return nil, err
 	}
-	if avp.Data.Type != datatype.EnumeratedType {
-		return nil, fmt.Errorf("data of AVP %s (%d) data is not Enumerated", avp.Name, avp.Code)
-	}
 	for _, item := range avp.Data.Enum {
 		if item.Code == n {
 			return item, nil
@@ -209,10 +241,37 @@ func (p *Parser) Enum(appid, code uint32, n int32) (*Enum, error) {
 	return nil, fmt.Errorf("could not find preload Enum %d for AVP %s (%d)", n, avp.Name, avp.Code)
 }
 
+// EnumByName is a helper function that returns a pre-loaded Enum item
+// for the given AVP appid, code and item name.
+// Code can be either the AVP code (int, uint32) or name (string).
+func (p *Parser) EnumByName(appid uint32, code interface{}, name string) (*Enum, error) {
+	avp, err := p.findEnumAVP(appid, code)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range avp.Data.Enum {
+		if item.Name == name {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find preload Enum %s for AVP %s (%d)", name, avp.Name, avp.Code)
+}
+
+// findEnumAVP returns the pre-loaded AVP for the given appid and code,
+// or an error if its data is not Enumerated.
+func (p *Parser) findEnumAVP(appid uint32, code interface{}) (*AVP, error) {
+	avp, err := p.FindAVP(appid, code)
+	if err != nil {
+		return nil, err
+	}
+	if avp.Data.Type != datatype.EnumeratedType {
+		return nil, fmt.Errorf("data of AVP %s (%d) data is not Enumerated", avp.Name, avp.Code)
+	}
+	return avp, nil
+}
+
 // Rule is a helper function that returns a pre-loaded Rule item for the
 // given AVP code and name.
-//
-// Rule must never be called concurrently with LoadFile or Load.
 func (p *Parser) Rule(appid, code uint32, n string) (*Rule, error) {
 	avp, err := p.FindAVP(appid, code)
 	if err != nil {
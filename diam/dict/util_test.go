@@ -117,6 +117,89 @@ func TestFindAVPWithVendor(t *testing.T) {
 	findAVPCodeTest(t, 16777251, "User-Password", dict.UndefinedVendorID, 2)
 }
 
+func TestParent(t *testing.T) {
+	p, err := dict.NewParser("./testdata/base.xml", "./testdata/tgpp_sh.xml", "./testdata/tgpp_s6c.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// User-Identity is defined in Sh.
+	if avp, _ := p.FindAVP(16777312, "User-Identity"); avp != nil {
+		t.Fatal("User-Identity should not be found for S6c")
+	}
+	if avp, _ := p.FindAVP(16777312, uint32(700)); avp.Data.TypeName != "Unknown" {
+		t.Fatalf("Unexpected AVP %s for S6c", avp.Name)
+	}
+	if err = p.SetParent(16777312, 16777217); err != nil {
+		t.Fatal(err)
+	}
+	if p.Parent(16777312) != 16777217 {
+		t.Fatalf("Unexpected parent of S6c %d", p.Parent(16777312))
+	}
+	findParserAVPCodeTest(t, p, 16777312, "User-Identity", 700)
+	// The base application is searched last.
+	findParserAVPCodeTest(t, p, 16777312, "Session-Id", 263)
+	// Applications inheriting in XML.
+	var childXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+  <application id="43" inherits="16777312">
+  </application>
+</diameter>`
+	if err = p.Load(bytes.NewReader([]byte(childXML))); err != nil {
+		t.Fatal(err)
+	}
+	findParserAVPCodeTest(t, p, 43, "User-Identity", 700)
+	// Cycles are not allowed.
+	version := p.Version()
+	if err = p.SetParent(16777217, 43); err == nil {
+		t.Fatal("Cycle 16777217 -> 43 -> 16777312 -> 16777217 not detected")
+	}
+	var cycleXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+  <application id="16777217" inherits="43">
+  </application>
+</diameter>`
+	if err = p.Load(bytes.NewReader([]byte(cycleXML))); err == nil {
+		t.Fatal("Cycle 16777217 -> 43 -> 16777312 -> 16777217 not detected")
+	}
+	if p.Version() != version || p.Parent(16777217) != 0 {
+		t.Fatal("Cycle changed the dictionaries")
+	}
+	// The base application only.
+	if err = p.SetParent(16777312, 0); err != nil {
+		t.Fatal(err)
+	}
+	if avp, _ := p.FindAVP(43, "User-Identity"); avp != nil {
+		t.Fatal("User-Identity should not be found for app 43")
+	}
+}
+
+func TestParent_Default(t *testing.T) {
+	// The hierarchy of the default dictionaries, as it was hardcoded
+	// before applications could declare it.
+	for id, want := range map[uint32]uint32{
+		4:        1,
+		16777238: 4,
+		16777251: 4,
+		16777265: 0,
+		16777302: 0,
+	} {
+		if have := dict.Default.Parent(id); have != want {
+			t.Errorf("Unexpected parent of %d. Want %d, have %d", id, want, have)
+		}
+	}
+}
+
+func findParserAVPCodeTest(t *testing.T, p *dict.Parser, appID uint32, name string, code uint32) {
+	t.Helper()
+	avp, err := p.FindAVP(appID, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if avp.Code != code {
+		t.Fatalf("Unexpected code %d for %s AVP", avp.Code, name)
+	}
+}
+
 func TestFindAVP(t *testing.T) {
 	if _, err := dict.Default.FindAVP(999, 263); err != nil {
 		t.Fatal(err)
@@ -162,6 +245,25 @@ func TestEnum(t *testing.T) {
 	}
 }
 
+func TestEnumByName(t *testing.T) {
+	if item, err := dict.Default.EnumByName(4, "CC-Request-Type", "TERMINATION_REQUEST"); err != nil {
+		t.Fatal(err)
+	} else if item.Code != 3 {
+		t.Errorf("Unexpected value %d, expected 3", item.Code)
+	}
+	if item, err := dict.Default.Enum(4, "CC-Request-Type", 3); err != nil {
+		t.Fatal(err)
+	} else if item.Name != "TERMINATION_REQUEST" {
+		t.Errorf("Unexpected value %s, expected TERMINATION_REQUEST", item.Name)
+	}
+	if _, err := dict.Default.EnumByName(4, "CC-Request-Type", "UNKNOWN"); err == nil {
+		t.Error("Unexpected item UNKNOWN")
+	}
+	if _, err := dict.Default.EnumByName(0, "Session-Id", "UNKNOWN"); err == nil {
+		t.Error("Unexpected item of UTF8String AVP")
+	}
+}
+
 func TestRule(t *testing.T) {
 	if rule, err := dict.Default.Rule(0, 284, "Proxy-Host"); err != nil {
 		t.Fatal(err)
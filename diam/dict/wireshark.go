@@ -0,0 +1,243 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Wireshark dictionary export.  Part of go-diameter.
+
+package dict
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+)
+
+// WriteWireshark writes the dictionaries loaded in the Parser to w in the
+// format of the diameter dictionary of Wireshark. The base protocol
+// (application 0) is written as the base element, and the vendors of
+// vendor specific AVPs are declared with the name of the dictionary
+// vendors.
+//
+// The Wireshark format has no cardinality for the AVPs of Grouped AVPs,
+// IPv4 and IPv6 data are written as IPAddress, and the "AVP" rules that
+// allow any AVP are omitted.
+func (p *Parser) WriteWireshark(w io.Writer) error {
+	d := &wsDictionary{Base: &wsApp{}}
+	apps := mergeApps(p.loadedApps())
+	vendors := make(map[uint32]*wsVendor)
+	for _, app := range apps {
+		for _, v := range app.Vendor {
+			if _, ok := vendors[v.ID]; !ok {
+				vendors[v.ID] = &wsVendor{ID: wsVendorID(v.Name, v.ID), Code: v.ID, Name: v.Name}
+				d.Vendor = append(d.Vendor, vendors[v.ID])
+			}
+		}
+	}
+	for _, app := range apps {
+		wsa := d.Base
+		if app.ID != 0 {
+			wsa = &wsApp{ID: app.ID, Name: app.Name}
+			d.App = append(d.App, wsa)
+		}
+		for _, cmd := range app.Command {
+			wsa.Command = append(wsa.Command, &wsCommand{
+				Name:    cmd.Name,
+				Code:    cmd.Code,
+				Request: wsCommandRules(cmd.Request.Rule),
+				Answer:  wsCommandRules(cmd.Answer.Rule),
+			})
+		}
+		for _, a := range app.AVP {
+			if a.VendorID != 0 && vendors[a.VendorID] == nil {
+				id := wsVendorID("", a.VendorID)
+				vendors[a.VendorID] = &wsVendor{ID: id, Code: a.VendorID, Name: id}
+				d.Vendor = append(d.Vendor, vendors[a.VendorID])
+			}
+			wsa.AVP = append(wsa.AVP, newWsAVP(a, vendors[a.VendorID]))
+		}
+	}
+	d.Base.Typedefn = wsTypedefns
+	return writeXML(w, d)
+}
+
+// wsDictionary is the root element of a Wireshark diameter dictionary.
+type wsDictionary struct {
+	XMLName xml.Name    `xml:"dictionary"`
+	Base    *wsApp      `xml:"base"`
+	App     []*wsApp    `xml:"application"`
+	Vendor  []*wsVendor `xml:"vendor"`
+}
+
+// wsApp is a Wireshark application, or the base protocol.
+type wsApp struct {
+	ID       uint32        `xml:"id,attr,omitempty"`
+	Name     string        `xml:"name,attr,omitempty"`
+	Typedefn []*wsTypedefn `xml:"typedefn"`
+	Command  []*wsCommand  `xml:"command"`
+	AVP      []*wsAVP      `xml:"avp"`
+}
+
+type wsVendor struct {
+	ID   string `xml:"vendor-id,attr"`
+	Code uint32 `xml:"code,attr"`
+	Name string `xml:"name,attr"`
+}
+
+type wsTypedefn struct {
+	Name   string `xml:"type-name,attr"`
+	Parent string `xml:"type-parent,attr,omitempty"`
+}
+
+// wsTypedefns are the data types of AVPs in the Wireshark format.
+var wsTypedefns = []*wsTypedefn{
+	{Name: "OctetString"},
+	{Name: "Integer32"},
+	{Name: "Integer64"},
+	{Name: "Unsigned32"},
+	{Name: "Unsigned64"},
+	{Name: "Float32"},
+	{Name: "Float64"},
+	{Name: "Address", Parent: "OctetString"},
+	{Name: "IPAddress", Parent: "OctetString"},
+	{Name: "Time", Parent: "OctetString"},
+	{Name: "UTF8String", Parent: "OctetString"},
+	{Name: "DiameterIdentity", Parent: "OctetString"},
+	{Name: "DiameterURI", Parent: "OctetString"},
+	{Name: "Enumerated", Parent: "Integer32"},
+	{Name: "IPFilterRule", Parent: "OctetString"},
+	{Name: "QoSFilterRule", Parent: "OctetString"},
+}
+
+type wsCommand struct {
+	Name    string        `xml:"name,attr"`
+	Code    uint32        `xml:"code,attr"`
+	Request wsCommandRule `xml:"requestrules"`
+	Answer  wsCommandRule `xml:"answerrules"`
+}
+
+type wsCommandRule struct {
+	Required wsRules `xml:"required"`
+	Optional wsRules `xml:"optional"`
+}
+
+type wsRules struct {
+	Rule []*wsRule `xml:"avprule"`
+}
+
+type wsRule struct {
+	Name string `xml:"name,attr"`
+	Min  int    `xml:"minimum,attr,omitempty"`
+	Max  int    `xml:"maximum,attr,omitempty"`
+}
+
+func wsCommandRules(rules []*Rule) wsCommandRule {
+	var r wsCommandRule
+	for _, rule := range rules {
+		if rule.AVP == "" || rule.AVP == "AVP" {
+			continue
+		}
+		wsr := &wsRule{Name: rule.AVP, Min: rule.Min, Max: rule.Max}
+		if rule.Required {
+			r.Required.Rule = append(r.Required.Rule, wsr)
+		} else {
+			r.Optional.Rule = append(r.Optional.Rule, wsr)
+		}
+	}
+	return r
+}
+
+type wsAVP struct {
+	Name       string     `xml:"name,attr"`
+	Code       uint32     `xml:"code,attr"`
+	VendorID   string     `xml:"vendor-id,attr,omitempty"`
+	Mandatory  string     `xml:"mandatory,attr"`
+	Protected  string     `xml:"protected,attr"`
+	MayEncrypt string     `xml:"may-encrypt,attr"`
+	VendorBit  string     `xml:"vendor-bit,attr"`
+	Type       *wsType    `xml:"type"`
+	Enum       []*wsEnum  `xml:"enum"`
+	Grouped    *wsGrouped `xml:"grouped"`
+}
+
+type wsType struct {
+	Name string `xml:"type-name,attr"`
+}
+
+type wsEnum struct {
+	Name string `xml:"name,attr"`
+	Code int32  `xml:"code,attr"`
+}
+
+type wsGrouped struct {
+	AVP []*wsGroupedAVP `xml:"gavp"`
+}
+
+type wsGroupedAVP struct {
+	Name string `xml:"name,attr"`
+}
+
+func newWsAVP(a *AVP, vendor *wsVendor) *wsAVP {
+	rules := a.FlagRules()
+	wsa := &wsAVP{
+		Name:       a.Name,
+		Code:       a.Code,
+		Mandatory:  wsFlag(rules, avp.Mbit),
+		Protected:  wsFlag(rules, avp.Pbit),
+		MayEncrypt: "no",
+		VendorBit:  wsFlag(rules, avp.Vbit),
+	}
+	if vendor != nil {
+		wsa.VendorID = vendor.ID
+	}
+	if rules.MayEncrypt {
+		wsa.MayEncrypt = "yes"
+	}
+	switch a.Data.TypeName {
+	case "Grouped":
+		wsa.Grouped = &wsGrouped{}
+		for _, rule := range a.Data.Rule {
+			if rule.AVP == "" || rule.AVP == "AVP" {
+				continue
+			}
+			wsa.Grouped.AVP = append(wsa.Grouped.AVP, &wsGroupedAVP{Name: rule.AVP})
+		}
+	case "IPv4", "IPv6":
+		wsa.Type = &wsType{Name: "IPAddress"}
+	default:
+		wsa.Type = &wsType{Name: a.Data.TypeName}
+	}
+	for _, item := range a.Data.Enum {
+		wsa.Enum = append(wsa.Enum, &wsEnum{Name: item.Name, Code: item.Code})
+	}
+	return wsa
+}
+
+// wsFlag returns the Wireshark rule of the flag bit.
+func wsFlag(rules FlagRules, bit uint8) string {
+	switch {
+	case rules.Must&bit != 0:
+		return "must"
+	case rules.MustNot&bit != 0:
+		return "mustnot"
+	}
+	return "may"
+}
+
+// wsVendorID returns the vendor-id of the vendor named name, a XML name
+// for the references of AVPs to the vendor.
+func wsVendorID(name string, id uint32) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, name)
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		return fmt.Sprintf("Vendor%d", id)
+	}
+	return name
+}
@@ -0,0 +1,282 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Concurrent dispatch of messages to handlers.
+
+package diam
+
+import (
+	"context"
+	"errors"
+	"log"
+	"runtime"
+	"sync"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// errTooBusy is the error of the requests rejected by the dispatcher.
+var errTooBusy = errors.New("too many messages being handled")
+
+// dispatcher calls the handler of the Server with the messages read from
+// a connection, in the reading goroutine, or in goroutines of the worker
+// pool of the Server when srv.Workers is set. It never blocks the reading
+// goroutine: messages wait for a worker in the queue of the pool.
+type dispatcher struct {
+	c        *conn
+	mu       sync.Mutex            // guards the following
+	inFlight int                   // messages being handled or queued
+	sessions map[string][]*Message // messages of sessions being handled
+	running  int                   // messages being handled
+	idle     chan struct{}         // closed when running drops to 0
+}
+
+func newDispatcher(c *conn) *dispatcher {
+	d := &dispatcher{c: c}
+	if c.server.SessionOrder {
+		d.sessions = make(map[string][]*Message)
+	}
+	return d
+}
+
+// dispatch calls the handler with m. With worker pool, requests received
+// when the connection is at its in-flight limit, or the queue of the
+// pool is full, are answered with DIAMETER_TOO_BUSY instead.
+func (d *dispatcher) dispatch(m *Message) {
+	srv := d.c.server
+	// Base protocol messages, such as CER, DWR and DPR, are handled in
+	// the reading goroutine, not to wait for slow handlers.
+	if srv.Workers <= 0 || m.Header.ApplicationID == 0 {
+		d.begin()
+		defer d.end()
+		serverHandler{srv}.ServeDIAM(d.c.writer, m)
+		return
+	}
+	d.mu.Lock()
+	// Answers are never rejected.
+	busy := d.inFlight >= srv.maxInFlight() && m.Header.CommandFlags&RequestFlag != 0
+	if !busy {
+		d.inFlight++
+	}
+	d.mu.Unlock()
+	if busy {
+		d.answerTooBusy(m)
+		return
+	}
+	if d.sessions != nil {
+		if sid, ok := sessionID(m); ok {
+			d.mu.Lock()
+			queue, running := d.sessions[sid]
+			d.sessions[sid] = append(queue, m)
+			d.mu.Unlock()
+			// Messages are handled after the previous ones of
+			// their session, by the same goroutine.
+			if running {
+				return
+			}
+			f := func() { d.serveSession(sid) }
+			if d.start(f) {
+				return
+			}
+			if m.Header.CommandFlags&RequestFlag != 0 {
+				d.mu.Lock()
+				delete(d.sessions, sid)
+				d.mu.Unlock()
+			}
+			d.overflow(m, f)
+			return
+		}
+	}
+	f := func() { d.serve(m) }
+	if !d.start(f) {
+		d.overflow(m, f)
+	}
+}
+
+// overflow handles the message m that does not fit in the queue of the
+// worker pool: requests are answered with DIAMETER_TOO_BUSY, and answers
+// are handled by f in the reading goroutine.
+func (d *dispatcher) overflow(m *Message, f func()) {
+	if m.Header.CommandFlags&RequestFlag == 0 {
+		d.begin()
+		defer d.end()
+		f()
+		return
+	}
+	d.mu.Lock()
+	d.inFlight--
+	d.mu.Unlock()
+	d.answerTooBusy(m)
+}
+
+// answerTooBusy answers the request m with DIAMETER_TOO_BUSY through the
+// handler when it is a MessageErrorHandler, which knows the identity of
+// the node for the answer. The request is discarded otherwise.
+func (d *dispatcher) answerTooBusy(m *Message) {
+	merr := &MessageError{Message: m, Code: TooBusy, Err: errTooBusy}
+	if !(serverHandler{d.c.server}).ServeMessageError(d.c.writer, merr) {
+		log.Printf("diam: discarding request from %v: %v",
+			d.c.rwc.RemoteAddr().String(), errTooBusy)
+	}
+}
+
+// start runs f in a goroutine of the worker pool, or queues it until
+// one is available. It reports false, without running f, when the
+// queue is full.
+func (d *dispatcher) start(f func()) bool {
+	srv := d.c.server
+	d.begin()
+	if srv.pool.run(srv.Workers, srv.maxQueue(), func() {
+		defer d.end()
+		f()
+	}) {
+		return true
+	}
+	d.end()
+	return false
+}
+
+func (d *dispatcher) begin() {
+	d.mu.Lock()
+	d.running++
+	d.mu.Unlock()
+}
+
+func (d *dispatcher) end() {
+	d.mu.Lock()
+	d.running--
+	if d.running == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+	d.mu.Unlock()
+}
+
+// wait waits until no handler is running, or ctx is done.
+func (d *dispatcher) wait(ctx context.Context) error {
+	d.mu.Lock()
+	if d.running == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+	}
+	idle := d.idle
+	d.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveSession handles the messages of the session sid in order, until
+// none is left.
+func (d *dispatcher) serveSession(sid string) {
+	for {
+		d.mu.Lock()
+		m := d.sessions[sid][0]
+		d.mu.Unlock()
+		d.serve(m)
+		d.mu.Lock()
+		queue := d.sessions[sid]
+		if len(queue) == 1 {
+			delete(d.sessions, sid)
+			d.mu.Unlock()
+			return
+		}
+		queue[0] = nil
+		d.sessions[sid] = queue[1:]
+		d.mu.Unlock()
+	}
+}
+
+// serve calls the handler with m in a goroutine of the worker pool.
+func (d *dispatcher) serve(m *Message) {
+	defer func() {
+		d.mu.Lock()
+		d.inFlight--
+		d.mu.Unlock()
+		if err := recover(); err != nil {
+			buf := make([]byte, 4096)
+			buf = buf[:runtime.Stack(buf, false)]
+			log.Printf("diam: panic serving %v: %v\n%s",
+				d.c.rwc.RemoteAddr().String(), err, buf)
+		}
+	}()
+	serverHandler{d.c.server}.ServeDIAM(d.c.writer, m)
+}
+
+// workerPool runs functions in a limited number of goroutines. Functions
+// run when all are busy are queued, and run in order as they free up.
+type workerPool struct {
+	mu      sync.Mutex // guards the following
+	running int        // goroutines running functions
+	queue   []func()   // functions waiting for a goroutine
+}
+
+// run runs f in a goroutine of the pool of the given size, or queues it
+// when the queue has less than max functions. It reports whether f was
+// run or queued.
+func (p *workerPool) run(size, max int, f func()) bool {
+	p.mu.Lock()
+	if p.running >= size {
+		if len(p.queue) >= max {
+			p.mu.Unlock()
+			return false
+		}
+		p.queue = append(p.queue, f)
+		p.mu.Unlock()
+		return true
+	}
+	p.running++
+	p.mu.Unlock()
+	go p.work(f)
+	return true
+}
+
+// work runs f, then the queued functions until none is left.
+func (p *workerPool) work(f func()) {
+	for f != nil {
+		f()
+		p.mu.Lock()
+		f = nil
+		if len(p.queue) > 0 {
+			f = p.queue[0]
+			p.queue[0] = nil
+			p.queue = p.queue[1:]
+		} else {
+			p.running--
+		}
+		p.mu.Unlock()
+	}
+}
+
+// sessionID returns the Session-Id of m, if any.
+func sessionID(m *Message) (string, bool) {
+	for _, a := range m.AVP {
+		if a.Code == avp.SessionID && a.VendorID == 0 {
+			sid, ok := a.Data.(datatype.UTF8String)
+			return string(sid), ok
+		}
+	}
+	return "", false
+}
+
+func (srv *Server) maxInFlight() int {
+	if srv.MaxInFlight > 0 {
+		return srv.MaxInFlight
+	}
+	return DefaultMaxInFlight
+}
+
+func (srv *Server) maxQueue() int {
+	if srv.MaxQueue > 0 {
+		return srv.MaxQueue
+	}
+	return DefaultMaxQueue
+}
@@ -0,0 +1,218 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+)
+
+func TestConcurrentDispatch(t *testing.T) {
+	release := make(chan struct{})
+	smux := diam.NewServeMux()
+	smux.Handle("CCR", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		sid, _ := m.FindAVP(avp.SessionID, 0)
+		n, _ := m.FindAVP(avp.CCRequestNumber, 0)
+		if sid.Data.(datatype.UTF8String) == "slow" && n.Data.(datatype.Unsigned32) == 1 {
+			<-release
+		}
+		a := m.Answer(diam.Success)
+		a.AddAVP(sid)
+		a.AddAVP(n)
+		a.WriteTo(c)
+	}))
+	smux.Handle("DWR", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.Success).WriteTo(c)
+	}))
+	srv := diamtest.NewUnstartedServer(smux, nil)
+	srv.Config.Workers = 4
+	srv.Config.MaxInFlight = 10
+	srv.Config.SessionOrder = true
+	srv.Start()
+	defer srv.Close()
+
+	answers := make(chan string, 10)
+	cmux := diam.NewServeMux()
+	cmux.Handle("CCA", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		sid, _ := m.FindAVP(avp.SessionID, 0)
+		n, _ := m.FindAVP(avp.CCRequestNumber, 0)
+		answers <- fmt.Sprintf("%s/%d", string(sid.Data.(datatype.UTF8String)), n.Data.(datatype.Unsigned32))
+	}))
+	cmux.Handle("DWA", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		answers <- "DWA"
+	}))
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	for _, r := range []struct {
+		sid string
+		n   uint32
+	}{{"slow", 1}, {"slow", 2}, {"fast", 1}} {
+		m := diam.NewRequest(diam.CreditControl, 4, nil)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(r.sid))
+		m.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(r.n))
+		if _, err = m.WriteTo(cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = diam.NewRequest(diam.DeviceWatchdog, 0, nil).WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	// The slow session does not block the others, nor the watchdog.
+	receive := func() string {
+		select {
+		case a := <-answers:
+			return a
+		case err := <-smux.ErrorReports():
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatal("Timed out: no answer received")
+		}
+		return ""
+	}
+	have := map[string]bool{receive(): true, receive(): true}
+	if !have["fast/1"] || !have["DWA"] {
+		t.Fatalf("Unexpected answers %v", have)
+	}
+	close(release)
+	// Messages of a session are handled in order.
+	for _, want := range []string{"slow/1", "slow/2"} {
+		if a := receive(); a != want {
+			t.Fatalf("Unexpected answer. Want %s, have %s", want, a)
+		}
+	}
+}
+
+// tooBusyMux is a ServeMux that answers the requests rejected by the
+// dispatcher, as the state machine of the sm package does.
+type tooBusyMux struct {
+	*diam.ServeMux
+}
+
+func (mux tooBusyMux) ServeMessageError(c diam.Conn, err *diam.MessageError) bool {
+	a := err.Message.Answer(err.Code)
+	a.Header.CommandFlags |= diam.ErrorFlag
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("srv"))
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("localhost"))
+	a.WriteTo(c)
+	return true
+}
+
+func TestDispatch_WorkersBusy(t *testing.T) {
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	smux := tooBusyMux{diam.NewServeMux()}
+	smux.Handle("CCR", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		started <- struct{}{}
+		<-release
+		m.Answer(diam.Success).WriteTo(c)
+	}))
+	smux.Handle("DWR", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.Success).WriteTo(c)
+	}))
+	srv := diamtest.NewUnstartedServer(smux, nil)
+	srv.Config.Workers = 1
+	srv.Config.MaxInFlight = 2
+	srv.Start()
+	defer srv.Close()
+	defer close(release)
+
+	cli, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// The first request takes the only worker, the second waits for it.
+	for i := 0; i < 2; i++ {
+		if _, err = diam.NewRequest(diam.CreditControl, 4, nil).WriteTo(cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-started
+	// The watchdog is still answered, and the requests over the
+	// in-flight limit are rejected.
+	a, err := diam.SendRequest(ctx, cli, diam.NewRequest(diam.DeviceWatchdog, 0, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Header.CommandCode != diam.DeviceWatchdog {
+		t.Fatalf("Unexpected answer %s", a)
+	}
+	a, err = diam.SendRequest(ctx, cli, diam.NewRequest(diam.CreditControl, 4, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := a.FindAVP(avp.ResultCode, 0)
+	if err != nil || rc.Data != datatype.Unsigned32(diam.TooBusy) || a.Header.CommandFlags&diam.ErrorFlag == 0 {
+		t.Fatalf("Unexpected answer %s", a)
+	}
+}
+
+func TestDispatch_QueueFull(t *testing.T) {
+	release := make(chan struct{})
+	smux := tooBusyMux{diam.NewServeMux()}
+	smux.Handle("CCR", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		<-release
+		m.Answer(diam.Success).WriteTo(c)
+	}))
+	srv := diamtest.NewUnstartedServer(smux, nil)
+	srv.Config.Workers = 1
+	srv.Config.MaxQueue = 2
+	srv.Start()
+	defer srv.Close()
+
+	codes := make(chan datatype.Unsigned32, 10)
+	cmux := diam.NewServeMux()
+	cmux.Handle("CCA", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		rc, err := m.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		codes <- rc.Data.(datatype.Unsigned32)
+	}))
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	// A flood of requests for a slow handler: one is handled, two wait
+	// in the queue and the others are rejected.
+	for i := 0; i < cap(codes); i++ {
+		if _, err = diam.NewRequest(diam.CreditControl, 4, nil).WriteTo(cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive := func() datatype.Unsigned32 {
+		select {
+		case code := <-codes:
+			return code
+		case <-time.After(time.Second):
+			t.Fatal("Timed out: no answer received")
+		}
+		return 0
+	}
+	for i := 0; i < cap(codes)-3; i++ {
+		if code := receive(); code != diam.TooBusy {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.TooBusy, code)
+		}
+	}
+	close(release)
+	for i := 0; i < 3; i++ {
+		if code := receive(); code != diam.Success {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.Success, code)
+		}
+	}
+}
@@ -48,5 +48,16 @@ are encoded and decoded using the dictionary automatically.
 The API of clients and servers require that you assign handlers for
 certain messages, similar to how you route HTTP endpoints. In the
 handlers, you'll receive messages already decoded.
+
+Clients that need the answer to a specific request can use SendRequest
+instead of registering answer handlers. It assigns the Hop-by-Hop
+Identifier, writes the request and waits for the matching answer:
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	answer, err := diam.SendRequest(ctx, c, m)
+
+Answers that don't match a pending SendRequest are still dispatched to
+the handlers.
 */
 package diam
@@ -0,0 +1,98 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Hop-by-Hop and End-to-End Identifier generators.
+
+package diam
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// IDGenerator is the interface implemented by Hop-by-Hop and End-to-End
+// Identifier generators. NextID must be safe for concurrent calls.
+type IDGenerator interface {
+	NextID() uint32
+}
+
+// IDGeneratorFunc is an adapter to allow the use of ordinary functions
+// as IDGenerator, typically for deterministic tests.
+type IDGeneratorFunc func() uint32
+
+// NextID calls f().
+func (f IDGeneratorFunc) NextID() uint32 {
+	return f()
+}
+
+// HopByHopIDGenerator generates monotonically increasing Hop-by-Hop
+// Identifiers. See RFC 6733 section 3 for details.
+type HopByHopIDGenerator struct {
+	n uint32
+}
+
+// NewHopByHopIDGenerator creates a HopByHopIDGenerator which first
+// identifier is start. RFC 6733 recommends a random start value.
+func NewHopByHopIDGenerator(start uint32) *HopByHopIDGenerator {
+	return &HopByHopIDGenerator{n: start - 1}
+}
+
+// NextID implements the IDGenerator interface.
+func (g *HopByHopIDGenerator) NextID() uint32 {
+	return atomic.AddUint32(&g.n, 1)
+}
+
+// EndToEndIDGenerator generates End-to-End Identifiers as described in
+// RFC 6733 section 3: the high order 12 bits contain the low order 12
+// bits of the boot time, and the low order 20 bits start at a random
+// value and are incremented for each identifier.
+type EndToEndIDGenerator struct {
+	high uint32
+	low  uint32
+}
+
+// NewEndToEndIDGenerator creates an EndToEndIDGenerator for a node
+// that booted at the given time.
+func NewEndToEndIDGenerator(boot time.Time) *EndToEndIDGenerator {
+	return newEndToEndIDGenerator(boot, rand.Uint32())
+}
+
+func newEndToEndIDGenerator(boot time.Time, low uint32) *EndToEndIDGenerator {
+	return &EndToEndIDGenerator{
+		high: (uint32(boot.Unix()) & 0xfff) << 20,
+		low:  low - 1,
+	}
+}
+
+// NextID implements the IDGenerator interface.
+func (g *EndToEndIDGenerator) NextID() uint32 {
+	return g.high | atomic.AddUint32(&g.low, 1)&0xfffff
+}
+
+var (
+	// HopByHopIDs generates the Hop-by-Hop Identifier of messages
+	// created by NewMessage and NewRequest. Messages sent with
+	// SendRequest get a new one from their connection's generator.
+	//
+	// It may be replaced for deterministic tests, but must never be
+	// replaced concurrently with the creation of messages.
+	HopByHopIDs IDGenerator = NewHopByHopIDGenerator(rand.Uint32())
+
+	// EndToEndIDs generates the End-to-End Identifier of messages
+	// created by NewMessage and NewRequest.
+	//
+	// It may be replaced for deterministic tests, but must never be
+	// replaced concurrently with the creation of messages.
+	EndToEndIDs IDGenerator = NewEndToEndIDGenerator(time.Now())
+
+	// NewConnHopByHopIDs creates the Hop-by-Hop Identifier generator
+	// of each new connection, used by SendRequest.
+	//
+	// It may be replaced for deterministic tests, but must never be
+	// replaced concurrently with the creation of connections.
+	NewConnHopByHopIDs = func() IDGenerator {
+		return NewHopByHopIDGenerator(rand.Uint32())
+	}
+)
@@ -0,0 +1,124 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHopByHopIDGenerator(t *testing.T) {
+	g := NewHopByHopIDGenerator(0xfffffffe)
+	for _, want := range []uint32{0xfffffffe, 0xffffffff, 0, 1} {
+		if have := g.NextID(); have != want {
+			t.Fatalf("Unexpected Hop-by-Hop. Want 0x%x, have 0x%x", want, have)
+		}
+	}
+}
+
+func TestHopByHopIDGenerator_Concurrent(t *testing.T) {
+	g := NewHopByHopIDGenerator(1)
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		seen = make(map[uint32]bool)
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				id := g.NextID()
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("Duplicate Hop-by-Hop 0x%x", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEndToEndIDGenerator(t *testing.T) {
+	boot := time.Unix(0x12345, 0)
+	g := newEndToEndIDGenerator(boot, 0xffffe)
+	for _, want := range []uint32{0x345ffffe, 0x345fffff, 0x34500000, 0x34500001} {
+		if have := g.NextID(); have != want {
+			t.Fatalf("Unexpected End-to-End. Want 0x%x, have 0x%x", want, have)
+		}
+	}
+	g = NewEndToEndIDGenerator(boot)
+	if high := g.NextID() >> 20; high != 0x345 {
+		t.Fatalf("Unexpected End-to-End high order bits. Want 0x345, have 0x%x", high)
+	}
+}
+
+func TestNewMessage_IDGenerators(t *testing.T) {
+	hbh, e2e := HopByHopIDs, EndToEndIDs
+	defer func() { HopByHopIDs, EndToEndIDs = hbh, e2e }()
+	HopByHopIDs = IDGeneratorFunc(func() uint32 { return 10 })
+	EndToEndIDs = IDGeneratorFunc(func() uint32 { return 20 })
+
+	m := NewRequest(CapabilitiesExchange, 0, nil)
+	if m.Header.HopByHopID != 10 || m.Header.EndToEndID != 20 {
+		t.Fatalf("Unexpected identifiers: %s", m.Header)
+	}
+	m = NewMessage(CapabilitiesExchange, RequestFlag, 0, 1, 2, nil)
+	if m.Header.HopByHopID != 1 || m.Header.EndToEndID != 2 {
+		t.Fatalf("Unexpected identifiers: %s", m.Header)
+	}
+}
+
+func TestAnswer_ZeroIdentifiers(t *testing.T) {
+	m := NewRequest(CapabilitiesExchange, 0, nil)
+	m.Header.HopByHopID = 0
+	m.Header.EndToEndID = 0
+	a := m.Answer(Success)
+	if a.Header.HopByHopID != 0 || a.Header.EndToEndID != 0 {
+		t.Fatalf("Unexpected identifiers: %s", a.Header)
+	}
+}
+
+func TestSendRequest_ConnHopByHopIDs(t *testing.T) {
+	newIDs := NewConnHopByHopIDs
+	defer func() { NewConnHopByHopIDs = newIDs }()
+	NewConnHopByHopIDs = func() IDGenerator {
+		return NewHopByHopIDGenerator(100)
+	}
+	ts := newTransactions()
+	for _, want := range []uint32{100, 101, 102} {
+		m := NewRequest(DeviceWatchdog, 0, nil)
+		if _, err := ts.add(m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Header.HopByHopID != want {
+			t.Fatalf("Unexpected Hop-by-Hop. Want %d, have %d", want, m.Header.HopByHopID)
+		}
+	}
+	ts.close()
+	_, err := ts.add(NewRequest(DeviceWatchdog, 0, nil))
+	if err != ErrConnectionClosed {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrConnectionClosed, err)
+	}
+}
+
+func TestSendRequest_NoHopByHopID(t *testing.T) {
+	newIDs := NewConnHopByHopIDs
+	defer func() { NewConnHopByHopIDs = newIDs }()
+	NewConnHopByHopIDs = func() IDGenerator {
+		return IDGeneratorFunc(func() uint32 { return 1 })
+	}
+	ts := newTransactions()
+	if _, err := ts.add(NewRequest(DeviceWatchdog, 0, nil)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ts.add(NewRequest(DeviceWatchdog, 0, nil))
+	if err != ErrNoHopByHopID {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrNoHopByHopID, err)
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net"
 	"sync"
 
@@ -59,11 +58,28 @@ func readerBufferSlice(buf *bytes.Buffer, l int) []byte {
 
 // ReadMessage reads a binary stream from the reader and uses the given
 // dictionary to parse it.
+//
+// Messages that are read completely but cannot be decoded, because of
+// an unknown command, invalid header bits or invalid AVPs, fail with a
+// *MessageError. The reader is left at the start of the next message.
+//
+// The message keeps a snapshot of the dictionary, which is not affected
+// by later loads or reloads of the dictionary.
 func ReadMessage(reader io.Reader, dictionary *dict.Parser) (*Message, error) {
 	buf := newReaderBuffer()
 	defer putReaderBuffer(buf)
-	m := &Message{dictionary: dictionary}
+	m := &Message{dictionary: dictSnapshot(dictionary)}
 	cmd, stream, err := m.readHeader(reader, buf)
+	if merr, ok := err.(*MessageError); ok {
+		m.stream = stream
+		b, err := m.readBodyBytes(reader, buf, stream)
+		if err != nil {
+			return nil, err
+		}
+		// Decode what is possible, for the Session-Id of the answer.
+		m.decodeAVPs(b)
+		return nil, merr
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -74,6 +90,22 @@ func ReadMessage(reader io.Reader, dictionary *dict.Parser) (*Message, error) {
 	return m, nil
 }
 
+// MessageError is returned by ReadMessage when a message is read
+// completely but cannot be decoded. Code is the Result-Code to answer
+// the message with, and AVP the offending AVP to be sent in the
+// Failed-AVP of the answer, if any. See RFC 6733 section 7.
+type MessageError struct {
+	Message *Message // Header and the AVPs decoded before the error
+	Code    uint32   // CommandUnsupported, InvalidHDRBits, InvalidAVPLenght, InvalidAVPValue or TooBusy
+	AVP     *AVP     // Offending AVP, nil for header errors
+	Err     error
+}
+
+// Error implements the error interface.
+func (e *MessageError) Error() string {
+	return e.Err.Error()
+}
+
 // MessageStream returns the stream #, the message was received on (when applicable)
 func (m *Message) MessageStream() uint {
 	return m.stream
@@ -104,18 +136,34 @@ func (m *Message) readHeader(r io.Reader, buf *bytes.Buffer) (*dict.Command, uin
 	if err != nil {
 		return nil, stream, err
 	}
+	if m.Header.MessageLength < HeaderLength {
+		return nil, stream, fmt.Errorf("invalid message length: %d", m.Header.MessageLength)
+	}
+
+	flags := m.Header.CommandFlags
+	if flags&RequestFlag == RequestFlag && flags&ErrorFlag == ErrorFlag {
+		return nil, stream, &MessageError{
+			Message: m,
+			Code:    InvalidHDRBits,
+			Err:     errors.New("request with the 'E' bit set"),
+		}
+	}
 
 	cmd, err = m.Dictionary().FindCommand(
 		m.Header.ApplicationID,
 		m.Header.CommandCode,
 	)
 	if err != nil {
-		return nil, stream, err
+		return nil, stream, &MessageError{
+			Message: m,
+			Code:    CommandUnsupported,
+			Err:     err,
+		}
 	}
 	return cmd, stream, nil
 }
 
-func (m *Message) readBody(r io.Reader, buf *bytes.Buffer, cmd *dict.Command, stream uint) error {
+func (m *Message) readBodyBytes(r io.Reader, buf *bytes.Buffer, stream uint) ([]byte, error) {
 	var err error
 	var n int
 	b := readerBufferSlice(buf, int(m.Header.MessageLength-HeaderLength))
@@ -127,9 +175,17 @@ func (m *Message) readBody(r io.Reader, buf *bytes.Buffer, cmd *dict.Command, st
 	}
 
 	if err != nil {
-		return fmt.Errorf("readBody Error: %v, %d bytes read", err, n)
+		return nil, fmt.Errorf("readBody Error: %v, %d bytes read", err, n)
 	}
-	n = m.maxAVPsFor(cmd)
+	return b, nil
+}
+
+func (m *Message) readBody(r io.Reader, buf *bytes.Buffer, cmd *dict.Command, stream uint) error {
+	b, err := m.readBodyBytes(r, buf, stream)
+	if err != nil {
+		return err
+	}
+	n := m.maxAVPsFor(cmd)
 	if n == 0 {
 		// TODO: fail to load the dictionary instead.
 		return fmt.Errorf(
@@ -155,7 +211,17 @@ func (m *Message) decodeAVPs(b []byte) error {
 	for n := 0; n < len(b); {
 		a, err := DecodeAVP(b[n:], m.Header.ApplicationID, m.Dictionary())
 		if err != nil {
-			return fmt.Errorf("failed to decode AVP: %s", err)
+			err = fmt.Errorf("failed to decode AVP: %w", err)
+			var derr *avpDecodeError
+			if errors.As(err, &derr) {
+				return &MessageError{
+					Message: m,
+					Code:    derr.code,
+					AVP:     derr.failedAVP(),
+					Err:     err,
+				}
+			}
+			return err
 		}
 		m.AVP = append(m.AVP, a)
 		n += a.Len()
@@ -164,12 +230,16 @@ func (m *Message) decodeAVPs(b []byte) error {
 }
 
 // NewMessage creates and initializes a Message.
+//
+// Unset (zero) hopbyhop and endtoend identifiers are taken from the
+// HopByHopIDs and EndToEndIDs generators. The message keeps a snapshot
+// of the dictionary, as ReadMessage.
 func NewMessage(cmd uint32, flags uint8, appid, hopbyhop, endtoend uint32, dictionary *dict.Parser) *Message {
 	if hopbyhop == 0 {
-		hopbyhop = rand.Uint32()
+		hopbyhop = HopByHopIDs.NextID()
 	}
 	if endtoend == 0 {
-		endtoend = rand.Uint32()
+		endtoend = EndToEndIDs.NextID()
 	}
 	return &Message{
 		Header: &Header{
@@ -181,11 +251,20 @@ func NewMessage(cmd uint32, flags uint8, appid, hopbyhop, endtoend uint32, dicti
 			HopByHopID:    hopbyhop,
 			EndToEndID:    endtoend,
 		},
-		dictionary: dictionary,
+		dictionary: dictSnapshot(dictionary),
 		stream:     InvalidStreamID,
 	}
 }
 
+// dictSnapshot returns a snapshot of the dictionary, or of the default
+// dictionary if nil.
+func dictSnapshot(dictionary *dict.Parser) *dict.Parser {
+	if dictionary == nil {
+		dictionary = dict.Default
+	}
+	return dictionary.Snapshot()
+}
+
 // NewRequest creates a new Message with the Request bit set.
 func NewRequest(cmd uint32, appid uint32, dictionary *dict.Parser) *Message {
 	return NewMessage(cmd, RequestFlag, appid, 0, 0, dictionary)
@@ -226,6 +305,18 @@ func (m *Message) NewAVP(code interface{}, flags uint8, vendor uint32, data data
 	return a, nil
 }
 
+// NewDictAVP adds a new AVP to the Message with the code, vendor and
+// default flags of the AVP in the dictionary. See NewDictAVP for
+// details. It is not safe for concurrent calls.
+func (m *Message) NewDictAVP(code interface{}, data datatype.Type) (*AVP, error) {
+	a, err := NewDictAVP(m.Dictionary(), m.Header.ApplicationID, code, data)
+	if err != nil {
+		return nil, err
+	}
+	m.AddAVP(a)
+	return a, nil
+}
+
 // AddAVP adds the AVP to the Message. It is not safe for concurrent calls.
 func (m *Message) AddAVP(a *AVP) {
 	m.AVP = append(m.AVP, a)
@@ -491,6 +582,9 @@ func (m *Message) Answer(resultCode uint32) *Message {
 		m.Header.EndToEndID,
 		m.Dictionary(),
 	)
+	// Zero is a valid identifier, keep it instead of generating a new one.
+	nm.Header.HopByHopID = m.Header.HopByHopID
+	nm.Header.EndToEndID = m.Header.EndToEndID
 	if resultCode != 0 {
 		nm.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(resultCode))
 	}
@@ -530,7 +624,7 @@ func (m *Message) String() string {
 		} else if a.Data.Type() == GroupedAVPType {
 			fmt.Fprintf(&b, "\t%s %s\n", dictAVP.Name, printGrouped("\t", m, a, 1))
 		} else {
-			fmt.Fprintf(&b, "\t%s %s\n", dictAVP.Name, a)
+			fmt.Fprintf(&b, "\t%s %s\n", dictAVP.Name, a.stringWithDictAVP(dictAVP))
 		}
 	}
 	return b.String()
@@ -561,7 +655,7 @@ func printGrouped(prefix string, m *Message, a *AVP, indent int) string {
 				tabs := indentTabs(indent)
 				fmt.Fprintf(&b, "%s%s %s\n", tabs, dictAVP.Name, printGrouped(tabs, m, ga, indent))
 			} else {
-				fmt.Fprintf(&b, "%s\t%s %s,\n", prefix, dictAVP.Name, ga)
+				fmt.Fprintf(&b, "%s\t%s %s,\n", prefix, dictAVP.Name, ga.stringWithDictAVP(dictAVP))
 			}
 		}
 	}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/ctrlzy/go-diameter/v4/diam"
@@ -187,6 +188,57 @@ func TestReadMessageWithVendorID(t *testing.T) {
 	t.Logf("Message:\n%s", msg)
 }
 
+func TestReadMessage_MessageError(t *testing.T) {
+	serialize := func(m *diam.Message) []byte {
+		b, err := m.Serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	command := diam.NewRequest(9999, 4, dict.Default)
+	command.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+
+	hdrBits := newValidCCR()
+	hdrBits.Header.CommandFlags |= diam.ErrorFlag
+
+	// Claim 4 more bytes in the length of the last AVP, an Unsigned32.
+	length := serialize(newValidCCR())
+	length[len(length)-5] += 4
+
+	for _, tc := range []struct {
+		name string
+		b    []byte
+		code uint32
+		avp  uint32
+	}{
+		{"unknown command", serialize(command), diam.CommandUnsupported, 0},
+		{"invalid header bits", serialize(hdrBits), diam.InvalidHDRBits, 0},
+		{"invalid AVP length", length, diam.InvalidAVPLenght, avp.CCRequestNumber},
+	} {
+		// The message is followed by a valid one, to be read next.
+		r := bytes.NewReader(append(tc.b, serialize(newValidCCR())...))
+		_, err := diam.ReadMessage(r, dict.Default)
+		merr, ok := err.(*diam.MessageError)
+		if !ok {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if merr.Code != tc.code {
+			t.Errorf("%s: unexpected code. Want %d, have %d", tc.name, tc.code, merr.Code)
+		}
+		if tc.avp != 0 && (merr.AVP == nil || merr.AVP.Code != tc.avp) {
+			t.Errorf("%s: unexpected AVP %v", tc.name, merr.AVP)
+		}
+		if _, err := merr.Message.FindAVP(avp.SessionID, 0); err != nil {
+			t.Errorf("%s: missing Session-Id: %v", tc.name, err)
+		}
+		if _, err = diam.ReadMessage(r, dict.Default); err != nil {
+			t.Errorf("%s: unexpected error reading the next message: %v", tc.name, err)
+		}
+	}
+}
+
 func TestNewMessage(t *testing.T) {
 	want, _ := diam.ReadMessage(bytes.NewReader(testMessage), dict.Default)
 	m := diam.NewMessage(diam.CapabilitiesExchange, diam.RequestFlag, 0, 0xa8cc407d, 0xa8c1b2b4, dict.Default)
@@ -224,6 +276,31 @@ func TestNewMessage(t *testing.T) {
 	t.Logf("Message:\n%s", hex.Dump(a))
 }
 
+func TestMessageStringEnumerated(t *testing.T) {
+	m := newValidCCR()
+	m.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.SubscriptionIDType, avp.Mbit, 0, datatype.Enumerated(1)),
+			diam.NewAVP(avp.SubscriptionIDData, avp.Mbit, 0, datatype.UTF8String("1234")),
+		},
+	})
+	m.NewAVP(avp.RequestedAction, avp.Mbit, 0, datatype.Enumerated(99))
+	s := m.String()
+	for _, want := range []string{
+		"Enumerated{INITIAL_REQUEST(1)}",
+		"Enumerated{END_USER_IMSI(1)}",
+		"Enumerated{99}",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Missing %s in message:\n%s", want, s)
+		}
+	}
+	a, _ := m.FindAVP(avp.CCRequestType, 0)
+	if s := a.StringWithDict(m.Dictionary(), m.Header.ApplicationID); !strings.Contains(s, "Enumerated{INITIAL_REQUEST(1)}") {
+		t.Errorf("Missing item name in AVP %s", s)
+	}
+}
+
 func TestMessageFindAVP(t *testing.T) {
 	m, _ := diam.ReadMessage(bytes.NewReader(testMessage), dict.Default)
 	a, err := m.FindAVP(avp.OriginStateID, 0)
@@ -36,8 +36,8 @@ func prettyDumpMessage(w io.Writer, m *Message, depth int) {
 		m.Header.EndToEndID)
 
 	// Print Titles
-	fmt.Fprintf(w, "  %-40s %8s %5s  %s %s %s  %-18s  %s\n",
-		"AVP", "Vendor", "Code", "V", "M", "P", "Type", "Value")
+	fmt.Fprintf(w, "  %-40s %8s %5s  %s %s %s  %-12s  %-18s  %s\n",
+		"AVP", "Vendor", "Code", "V", "M", "P", "Must|May|Not", "Type", "Value")
 
 	// Print AVPs
 	for _, a := range m.AVP {
@@ -56,13 +56,14 @@ func prettyDumpAVP(w io.Writer, m *Message, a *AVP, depth int) {
 
 	avpName, avpType, avpData, isGrouped := avpToString(m, a)
 
-	fmt.Fprintf(w, "  %-40s %8d %5d  %s %s %s  %-18s  %s\n",
+	fmt.Fprintf(w, "  %-40s %8d %5d  %s %s %s  %-12s  %-18s  %s\n",
 		indent+avpName,
 		a.VendorID,
 		a.Code,
 		boolToSymbol(a.Flags&avp.Vbit == avp.Vbit),
 		boolToSymbol(a.Flags&avp.Mbit == avp.Mbit),
 		boolToSymbol(a.Flags&avp.Pbit == avp.Pbit),
+		flagRulesToString(m, a),
 		avpType,
 		avpData)
 
@@ -169,12 +170,40 @@ func avpToString(m *Message, a *AVP) (string, string, string, bool) {
 		}
 		avpName = dictAVP.Name
 		avpData = dataValueToString(a.Data)
+		if n, ok := a.Data.(datatype.Enumerated); ok {
+			if item := enumItem(dictAVP, n); item != nil {
+				avpData = fmt.Sprintf("%s(%d)", item.Name, n)
+			}
+		}
 		isGrouped = false
 	}
 
 	return avpName, avpType, avpData, isGrouped
 }
 
+// flagRulesToString returns the flags the AVP must, may and must not
+// have as per the dictionary, followed by "!" if its flags do not
+// comply.
+func flagRulesToString(m *Message, a *AVP) string {
+	dictAVP, err := m.Dictionary().FindAVPWithVendor(
+		m.Header.ApplicationID,
+		a.Code,
+		a.VendorID,
+	)
+	if err != nil || dictAVP.Data.Type == datatype.UnknownType {
+		return "-"
+	}
+	rules := dictAVP.FlagRules()
+	s := fmt.Sprintf("%s|%s|%s",
+		dict.FlagsString(rules.Must),
+		dict.FlagsString(rules.May),
+		dict.FlagsString(rules.MustNot))
+	if rules.Check(a.Flags) != nil {
+		s += "!"
+	}
+	return s
+}
+
 func dataValueToString(data datatype.Type) string {
 
 	switch data.Type() {
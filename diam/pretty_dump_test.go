@@ -57,27 +57,27 @@ func TestPrettyDumpAVP(t *testing.T) {
 		{
 			name:     "Unsigned32",
 			avp:      NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(13)),
-			expected: "  Vendor-Id                                       0   266  ✗ ✓ ✗  Unsigned32          13",
+			expected: "  Vendor-Id                                       0   266  ✗ ✓ ✗  M|P|V         Unsigned32          13",
 		},
 		{
 			name:     "UTF8String",
 			avp:      NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("abc-1234567")),
-			expected: "  Session-Id                                      0   263  ✗ ✓ ✗  UTF8String          abc-1234567",
+			expected: "  Session-Id                                      0   263  ✗ ✓ ✗  M|P|V         UTF8String          abc-1234567",
 		},
 		{
 			name:     "Address",
 			avp:      NewAVP(avp.HostIPAddress, avp.Mbit, 0, datatype.Address(net.ParseIP("10.1.0.1"))),
-			expected: "  Host-IP-Address                                 0   257  ✗ ✓ ✗  Address             10.1.0.1",
+			expected: "  Host-IP-Address                                 0   257  ✗ ✓ ✗  M|P|V         Address             10.1.0.1",
 		},
 		{
 			name:     "AddressIPv6",
 			avp:      NewAVP(avp.GGSNAddress, avp.Mbit, 10415, datatype.Address(net.ParseIP("2001:0db8::ff00:0042:8329"))),
-			expected: "  GGSN-Address                                10415   847  ✓ ✓ ✗  Address             2001:db8::ff00:42:8329",
+			expected: "  GGSN-Address                                10415   847  ✓ ✓ ✗  V,M|P|-       Address             2001:db8::ff00:42:8329",
 		},
 		{
 			name:     "Enumerated",
 			avp:      NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(1)),
-			expected: "  CC-Request-Type                                 0   416  ✗ ✓ ✗  Enumerated          1",
+			expected: "  CC-Request-Type                                 0   416  ✗ ✓ ✗  M|P|V         Enumerated          INITIAL_REQUEST(1)",
 		},
 		{
 			name: "GroupedAVP",
@@ -89,10 +89,10 @@ func TestPrettyDumpAVP(t *testing.T) {
 				},
 			}),
 			expected: strings.Join([]string{
-				"  Multiple-Services-Credit-Control                0   456  ✗ ✓ ✗  Grouped",
-				"    Service-Identifier                            0   439  ✗ ✓ ✗  Unsigned32          7786",
-				"    Rating-Group                                  0   432  ✗ ✓ ✗  Unsigned32          7786",
-				"    TGPP-RAT-Type                             10415    21  ✓ ✓ ✗  OctetString         1234",
+				"  Multiple-Services-Credit-Control                0   456  ✗ ✓ ✗  M|P|V         Grouped",
+				"    Service-Identifier                            0   439  ✗ ✓ ✗  M|P|V         Unsigned32          7786",
+				"    Rating-Group                                  0   432  ✗ ✓ ✗  M|P|V         Unsigned32          7786",
+				"    TGPP-RAT-Type                             10415    21  ✓ ✓ ✗  V|P|M!        OctetString         1234",
 			}, "\n"),
 		},
 		{
@@ -107,10 +107,10 @@ func TestPrettyDumpAVP(t *testing.T) {
 					}),
 				}}),
 			expected: strings.Join([]string{
-				"  Service-Information                         10415   873  ✓ ✓ ✗  Grouped",
-				"    PS-Information                            10415   874  ✓ ✓ ✗  Grouped",
-				"      Called-Station-Id                           0    30  ✗ ✓ ✗  UTF8String          10999",
-				"      Start-Time                              10415  2041  ✓ ✗ ✗  Time                2023-08-21 22:06:14 +0000 UTC",
+				"  Service-Information                         10415   873  ✓ ✓ ✗  V,M|P|-       Grouped",
+				"    PS-Information                            10415   874  ✓ ✓ ✗  V,M|P|-       Grouped",
+				"      Called-Station-Id                           0    30  ✗ ✓ ✗  M|-|V         UTF8String          10999",
+				"      Start-Time                              10415  2041  ✓ ✗ ✗  V,M|P|-!      Time                2023-08-21 22:06:14 +0000 UTC",
 			}, "\n"),
 		},
 	}
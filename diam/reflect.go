@@ -9,7 +9,6 @@ import (
 	"reflect"
 	"strings"
 
-	"github.com/ctrlzy/go-diameter/v4/diam/avp"
 	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
 	"github.com/ctrlzy/go-diameter/v4/diam/dict"
 )
@@ -265,17 +264,9 @@ BASIC_TYPE:
 		}
 	}
 
-	var avpFlags uint8
-	if strings.Contains(fieldAVP.Must, "M") {
-		avpFlags = avp.Mbit
-	}
-	if fieldAVP.VendorID > 0 {
-		avpFlags |= avp.Vbit
-	}
-
 	avp := &AVP{
 		Code:     fieldAVP.Code,
-		Flags:    avpFlags,
+		Flags:    fieldAVP.Flags(),
 		VendorID: fieldAVP.VendorID,
 		Data:     data,
 	}
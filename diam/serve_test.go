@@ -5,6 +5,7 @@
 package diam_test
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -234,3 +235,106 @@ func handleCEA(errc chan error, wait chan struct{}) diam.HandlerFunc {
 		c.Close()
 	}
 }
+
+func TestServerShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	smux := diam.NewServeMux()
+	smux.Handle("CCR", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		close(started)
+		<-release
+		m.Answer(diam.Success).WriteTo(c)
+	}))
+	srv := diamtest.NewUnstartedServer(smux, nil)
+	srv.Config.Workers = 1
+	served := make(chan error, 1)
+	go func() { served <- srv.Config.Serve(srv.Listener) }()
+	answers := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.Handle("CCA", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		answers <- m
+	}))
+	cli, err := diam.Dial(srv.Listener.Addr().String(), cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if _, err = diam.NewRequest(diam.CreditControl, 4, nil).WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- srv.Config.Shutdown(context.Background()) }()
+	select {
+	case err = <-served:
+		if err != diam.ErrServerClosed {
+			t.Fatalf("Unexpected Serve error. Want %v, have %v", diam.ErrServerClosed, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: Serve did not return")
+	}
+	// The handler being run is waited for.
+	select {
+	case err = <-shutdown:
+		t.Fatalf("Shutdown returned before the handler: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-answers:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: no answer received")
+	}
+	if err = <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if _, err = diam.Dial(srv.Listener.Addr().String(), nil, nil); err == nil {
+		t.Fatal("Unexpected connection after Shutdown")
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	smux := diam.NewServeMux()
+	smux.Handle("CCR", diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		<-release
+	}))
+	srv := diamtest.NewServer(smux, nil)
+	cli, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = diam.NewRequest(diam.CreditControl, 4, nil).WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond) // let the handler start
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err = srv.Config.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Unexpected error. Want %v, have %v", context.DeadlineExceeded, err)
+	}
+	// The connection is closed.
+	select {
+	case <-cli.(diam.CloseNotifier).CloseNotify():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: connection not closed")
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	srv := diamtest.NewServer(diam.NewServeMux(), nil)
+	cli, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := cli.(diam.CloseNotifier).CloseNotify()
+	if err = srv.Config.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: connection not closed")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"bufio"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -18,6 +19,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
 	"github.com/ctrlzy/go-diameter/v4/diam/dict"
 )
 
@@ -54,6 +56,47 @@ type CloseNotifier interface {
 	CloseNotify() <-chan struct{}
 }
 
+// The AnswerObserver interface is implemented by Handlers that need
+// to see the answers delivered to SendRequest callers, which are not
+// passed to ServeDIAM. It allows, for example, treating them as peer
+// liveness in a watchdog.
+type AnswerObserver interface {
+	// ObserveAnswer is called with each answer delivered to a
+	// SendRequest caller.
+	ObserveAnswer(c Conn, m *Message)
+}
+
+// The WriteObserver interface is implemented by Handlers that need
+// to see the messages written to their connections, for example to
+// keep the answers sent to requests.
+type WriteObserver interface {
+	// ObserveWrite is called with each message successfully written
+	// to the connection c. The handler must not modify or retain b.
+	ObserveWrite(c Conn, b []byte)
+}
+
+// The MessageErrorHandler interface is implemented by Handlers that
+// can answer the messages that fail to decode with a *MessageError,
+// instead of having their connection closed. It is also called with
+// the requests rejected with TooBusy, see Server.Workers.
+type MessageErrorHandler interface {
+	// ServeMessageError is called with the error of a message read
+	// from c, and reports whether it was handled. The connection is
+	// closed after errors that are not handled.
+	ServeMessageError(c Conn, err *MessageError) bool
+}
+
+// The Disconnecter interface is implemented by Handlers that can
+// gracefully disconnect peers on Server.Shutdown, such as the state
+// machine of the sm package, which knows the Origin-Host and
+// Origin-Realm of the DPR.
+type Disconnecter interface {
+	// DisconnectPeer sends a DPR with the given Disconnect-Cause on
+	// c, and waits for the DPA until ctx is done. It must not close
+	// the connection.
+	DisconnectPeer(ctx context.Context, c Conn, cause datatype.Enumerated) error
+}
+
 // A liveSwitchReader is a switchReader that's safe for concurrent
 // reads and switches, if its mutex is held.
 type liveSwitchReader struct {
@@ -85,6 +128,10 @@ type conn struct {
 	buf      *bufio.ReadWriter    // buffered(sr, rwc)
 	tlsState *tls.ConnectionState // or nil when not using TLS
 	writer   *response            // the diam.Conn exposed to handlers
+	dispatch *dispatcher          // calls the handler with messages
+
+	transactions *transactions // requests waiting for answers
+	hooks        *answerHooks  // hooks of answers to be written
 
 	mu           sync.Mutex // guards the following
 	closeNotifyc chan struct{}
@@ -153,6 +200,9 @@ func (srv *Server) newConn(rwc net.Conn) (c *conn, err error) {
 		c.buf = bufio.NewReadWriter(bufio.NewReader(&c.sr), bufio.NewWriter(rwc))
 	}
 	c.writer = &response{conn: c}
+	c.dispatch = newDispatcher(c)
+	c.transactions = newTransactions()
+	c.hooks = newAnswerHooks()
 	return c, nil
 }
 
@@ -184,6 +234,8 @@ func (c *conn) serve() {
 				c.rwc.RemoteAddr().String(), err, buf)
 		}
 		c.rwc.Close()
+		c.transactions.close()
+		c.server.trackConn(c, false)
 	}()
 	if tlsConn, ok := c.rwc.(*tls.Conn); ok {
 		if err := tlsConn.Handshake(); err != nil {
@@ -194,26 +246,40 @@ func (c *conn) serve() {
 	}
 	for {
 		m, err := c.readMessage()
+		// Keep serving the connection when the handler answers
+		// messages that fail to decode.
+		if merr, ok := err.(*MessageError); ok && (serverHandler{c.server}).ServeMessageError(c.writer, merr) {
+			serverHandler{c.server}.Error(&ErrorReport{c.writer, merr.Message, err})
+			continue
+		}
 		if err != nil {
 			c.rwc.Close()
+			c.transactions.close()
 			c.notifyClientGone()
-			// Report errors to the channel, except EOF.
-			if err != io.EOF && err != io.ErrUnexpectedEOF {
-				h := c.server.Handler
-				if h == nil {
-					h = DefaultServeMux
-				}
-				if er, ok := h.(ErrorReporter); ok {
-					er.Error(&ErrorReport{c.writer, m, err})
-				}
+			// Report errors to the channel, except EOF and
+			// those of connections closed by Shutdown or Close.
+			if err != io.EOF && err != io.ErrUnexpectedEOF && !c.server.shuttingDown() {
+				serverHandler{c.server}.Error(&ErrorReport{c.writer, m, err})
 			}
 			break
 		}
-		// Handle messages in this goroutine.
-		serverHandler{c.server}.ServeDIAM(c.writer, m)
+		// Answers to pending SendRequest calls skip the handler.
+		if c.transactions.deliver(m) {
+			serverHandler{c.server}.ObserveAnswer(c.writer, m)
+			continue
+		}
+		// Handle messages in this goroutine, or in the worker pool.
+		c.dispatch.dispatch(m)
 	}
 }
 
+// disconnect gracefully closes the connection for Server.Shutdown.
+func (c *conn) disconnect(ctx context.Context) {
+	serverHandler{c.server}.DisconnectPeer(ctx, c.writer, c.server.DisconnectCause)
+	c.dispatch.wait(ctx)
+	c.rwc.Close()
+}
+
 // dictionary returns the dictionary parser associated to the Server instance
 // or dict.Default.
 func (c *conn) dictionary() *dict.Parser {
@@ -224,7 +290,8 @@ func (c *conn) dictionary() *dict.Parser {
 }
 
 // A response represents the server side of a diameter response.
-// It implements the Conn and CloseNotifier interfaces.
+// It implements the Conn, CloseNotifier, RequestSender and AnswerHooker
+// interfaces.
 type response struct {
 	mu   sync.Mutex      // guards conn and Write
 	conn *conn           // socket, reader and writer
@@ -234,6 +301,18 @@ type response struct {
 
 // Write writes the message m to the connection.
 func (w *response) Write(b []byte) (int, error) {
+	hooked := w.conn.hooks.apply(b, w.conn.dictionary())
+	n, err := w.write(hooked)
+	if err == nil {
+		serverHandler{w.conn.server}.ObserveWrite(w, hooked)
+	}
+	if n > len(b) {
+		n = len(b)
+	}
+	return n, err
+}
+
+func (w *response) write(b []byte) (int, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	if w.conn.server.WriteTimeout > 0 {
@@ -258,7 +337,15 @@ func (w *response) WriteStream(b []byte, stream uint) (int, error) {
 	// TODO - SetWriteDeadline is not currently supported
 	if msc, isMulti := w.conn.rwc.(MultistreamConn); isMulti {
 		// don't use buffered writer for muti-streamming writes it'll mix up streams
-		return msc.WriteStream(b, stream)
+		hooked := w.conn.hooks.apply(b, w.conn.dictionary())
+		n, err := msc.WriteStream(hooked, stream)
+		if err == nil {
+			serverHandler{w.conn.server}.ObserveWrite(w, hooked)
+		}
+		if n > len(b) {
+			n = len(b)
+		}
+		return n, err
 	}
 	return w.Write(b)
 }
@@ -567,8 +654,59 @@ type Server struct {
 	WriteTimeout time.Duration // maximum duration before timing out write of the response
 	TLSConfig    *tls.Config   // optional TLS config, used by ListenAndServeTLS
 	LocalAddr    net.Addr      // optional Local Address to bind dailer's (Dail...) socket to
+
+	// Workers is the maximum number of handlers running concurrently
+	// in the worker pool of the server, for all connections. Messages
+	// received while all are busy are queued, and handled in order. If
+	// zero, messages are handled one at a time, in the goroutine reading
+	// each connection. Base protocol messages (application 0), such
+	// as CER, DWR and DPR, are always handled in that goroutine.
+	//
+	// Requests over the MaxInFlight and MaxQueue limits are answered
+	// with DIAMETER_TOO_BUSY by the Handler, which must implement
+	// MessageErrorHandler to know the Origin-Host and Origin-Realm of
+	// the answer, as the state machine of the sm package does. They are
+	// discarded otherwise.
+	Workers int
+
+	// MaxInFlight is the maximum number of messages of a connection
+	// being handled or queued in the worker pool, DefaultMaxInFlight
+	// if zero.
+	MaxInFlight int
+
+	// MaxQueue is the maximum number of messages waiting for a worker,
+	// for all connections, DefaultMaxQueue if zero. Answers received
+	// while the queue is full are handled in the goroutine reading
+	// the connection.
+	MaxQueue int
+
+	// SessionOrder makes messages with the same Session-Id be handled
+	// in the order they are read, one at a time, in the worker pool.
+	SessionOrder bool
+
+	// DisconnectCause is the Disconnect-Cause of the DPR sent to the
+	// peers by Shutdown, REBOOTING by default. See the disconnectcause
+	// package for the possible causes.
+	DisconnectCause datatype.Enumerated
+
+	pool workerPool // see Workers
+
+	mu         sync.Mutex // guards the following
+	listeners  map[*net.Listener]struct{}
+	conns      map[*conn]struct{}
+	inShutdown bool
 }
 
+// Default limits of the worker pool of servers, see Server.Workers.
+const (
+	DefaultMaxInFlight = 256
+	DefaultMaxQueue    = 4096
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after a call
+// to Shutdown or Close.
+var ErrServerClosed = errors.New("diam: Server closed")
+
 // serverHandler delegates to either the server's Handler or DefaultServeMux.
 type serverHandler struct {
 	srv *Server
@@ -582,6 +720,42 @@ func (sh serverHandler) ServeDIAM(w Conn, m *Message) {
 	handler.ServeDIAM(w, m)
 }
 
+func (sh serverHandler) ObserveAnswer(w Conn, m *Message) {
+	if o, ok := sh.srv.Handler.(AnswerObserver); ok {
+		o.ObserveAnswer(w, m)
+	}
+}
+
+func (sh serverHandler) ServeMessageError(w Conn, err *MessageError) bool {
+	if h, ok := sh.srv.Handler.(MessageErrorHandler); ok {
+		return h.ServeMessageError(w, err)
+	}
+	return false
+}
+
+func (sh serverHandler) Error(err *ErrorReport) {
+	handler := sh.srv.Handler
+	if handler == nil {
+		handler = DefaultServeMux
+	}
+	if er, ok := handler.(ErrorReporter); ok {
+		er.Error(err)
+	}
+}
+
+func (sh serverHandler) ObserveWrite(w Conn, b []byte) {
+	if o, ok := sh.srv.Handler.(WriteObserver); ok {
+		o.ObserveWrite(w, b)
+	}
+}
+
+func (sh serverHandler) DisconnectPeer(ctx context.Context, w Conn, cause datatype.Enumerated) error {
+	if d, ok := sh.srv.Handler.(Disconnecter); ok {
+		return d.DisconnectPeer(ctx, w, cause)
+	}
+	return nil
+}
+
 // ListenAndServe listens on the network address srv.Addr and then
 // calls Serve to handle requests on incoming connections.  If
 //
@@ -606,12 +780,22 @@ func (srv *Server) ListenAndServe() error {
 // Serve accepts incoming connections on the Listener l, creating a
 // new service goroutine for each.  The service goroutines read requests and
 // then call srv.Handler to reply to them.
+//
+// Serve always returns a non-nil error. After Shutdown or Close, the
+// returned error is ErrServerClosed.
 func (srv *Server) Serve(l net.Listener) error {
 	defer l.Close()
+	if !srv.trackListener(&l, true) {
+		return ErrServerClosed
+	}
+	defer srv.trackListener(&l, false)
 	var tempDelay time.Duration // how long to sleep on accept failure
 	for {
 		rw, e := l.Accept()
 		if e != nil {
+			if srv.shuttingDown() {
+				return ErrServerClosed
+			}
 			if ne, ok := e.(net.Error); ok && ne.Temporary() {
 				if tempDelay == 0 {
 					tempDelay = 5 * time.Millisecond
@@ -639,12 +823,134 @@ func (srv *Server) Serve(l net.Listener) error {
 		if c, err := srv.newConn(rw); err != nil {
 			log.Printf("srv.newConn error: %v", err)
 			continue
+		} else if !srv.trackConn(c, true) {
+			rw.Close()
 		} else {
 			go c.serve()
 		}
 	}
 }
 
+// Shutdown gracefully shuts down the server: it closes all listeners,
+// then disconnects all open connections, and waits for them to be
+// disconnected or ctx to be done, when the remaining connections are
+// closed and the context error is returned.
+//
+// Connections are disconnected by sending a DPR with DisconnectCause,
+// if the Handler implements Disconnecter, waiting for the DPA and the
+// handlers of the messages being handled, and closing the connection.
+// Messages received meanwhile are still handled.
+//
+// Once Shutdown is called, Serve, ListenAndServe and ListenAndServeTLS
+// return ErrServerClosed. Shutdown returns the error of closing the
+// listeners, if any.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.mu.Lock()
+	srv.inShutdown = true
+	err := srv.closeListenersLocked()
+	conns := make([]*conn, 0, len(srv.conns))
+	for c := range srv.conns {
+		conns = append(conns, c)
+	}
+	srv.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for _, c := range conns {
+			wg.Add(1)
+			go func(c *conn) {
+				defer wg.Done()
+				c.disconnect(ctx)
+			}(c)
+		}
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		srv.closeConns()
+		return ctx.Err()
+	}
+}
+
+// Close immediately closes all listeners and connections of the server,
+// without waiting for the handlers of the messages being handled.
+// For a graceful shutdown, use Shutdown.
+//
+// Close returns the error of closing the listeners, if any.
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	srv.inShutdown = true
+	err := srv.closeListenersLocked()
+	srv.mu.Unlock()
+	srv.closeConns()
+	return err
+}
+
+func (srv *Server) shuttingDown() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.inShutdown
+}
+
+// trackListener adds or removes the listener l, and reports whether it
+// was added or removed: listeners are not added during Shutdown.
+func (srv *Server) trackListener(l *net.Listener, add bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if !add {
+		delete(srv.listeners, l)
+		return true
+	}
+	if srv.inShutdown {
+		return false
+	}
+	if srv.listeners == nil {
+		srv.listeners = make(map[*net.Listener]struct{})
+	}
+	srv.listeners[l] = struct{}{}
+	return true
+}
+
+// trackConn adds or removes the connection c, and reports whether it
+// was added or removed: connections are not added during Shutdown.
+func (srv *Server) trackConn(c *conn, add bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if !add {
+		delete(srv.conns, c)
+		return true
+	}
+	if srv.inShutdown {
+		return false
+	}
+	if srv.conns == nil {
+		srv.conns = make(map[*conn]struct{})
+	}
+	srv.conns[c] = struct{}{}
+	return true
+}
+
+func (srv *Server) closeListenersLocked() error {
+	var err error
+	for l := range srv.listeners {
+		if cerr := (*l).Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (srv *Server) closeConns() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for c := range srv.conns {
+		c.rwc.Close()
+	}
+}
+
 // ListenAndServeNetwork listens on the network & addr
 // and then calls Serve with handler to handle requests
 // on incoming connections.
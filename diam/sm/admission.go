@@ -0,0 +1,256 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// AdmissionIdleTimeout is how long AdmissionControl keeps the counters
+// and buckets of the peers that stopped sending requests.
+const AdmissionIdleTimeout = 10 * time.Minute
+
+// admissionSweepInterval is how often Admit removes the idle counters
+// and buckets.
+const admissionSweepInterval = time.Minute
+
+// AdmissionKey identifies the requests of a peer, application and
+// command. OriginHost is the Origin-Host of the peer the requests were
+// received from, as advertised in its CER or CEA, rather than the
+// Origin-Host AVP of the requests.
+type AdmissionKey struct {
+	OriginHost datatype.DiameterIdentity
+	AppID      uint32
+	Command    uint32
+}
+
+// AdmissionRule limits the rate of the requests that match it with a
+// token bucket of Burst tokens, refilled at Rate tokens per second.
+//
+// Empty OriginHost, AppID and Command match all. All matching requests
+// share the same bucket, unless PerPeer is set, in which case each
+// peer has its own.
+type AdmissionRule struct {
+	OriginHost datatype.DiameterIdentity // Origin-Host of the peer, all if empty
+	AppID      uint32                    // Application of requests, all if 0
+	Command    uint32                    // Command code of requests, all if 0
+	PerPeer    bool                      // One bucket per peer
+	Rate       float64                   // Requests per second
+	Burst      int                       // Max requests at once, at least 1
+}
+
+// match reports whether the rule applies to the requests of k.
+func (r *AdmissionRule) match(k AdmissionKey) bool {
+	return (r.OriginHost == "" || r.OriginHost == k.OriginHost) &&
+		(r.AppID == 0 || r.AppID == k.AppID) &&
+		(r.Command == 0 || r.Command == k.Command)
+}
+
+// AdmissionStats are the counters of the requests of an AdmissionKey.
+type AdmissionStats struct {
+	Admitted uint64 // Requests passed to the handler
+	Rejected uint64 // Requests answered with DIAMETER_TOO_BUSY
+}
+
+// admissionCounters are the AdmissionStats of a key and the time of its
+// latest request.
+type admissionCounters struct {
+	AdmissionStats
+	last time.Time
+}
+
+type bucketKey struct {
+	rule int
+	host datatype.DiameterIdentity // Origin-Host of the peer of PerPeer rules
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// AdmissionControl is a middleware that limits the rate of the requests
+// received by its handlers, according to its rules. Requests exceeding
+// the limits are answered with DIAMETER_TOO_BUSY (3004), generated by
+// the state machine with ErrorAnswer, and are not passed to the
+// handlers. It is safe for concurrent use.
+//
+// Handlers of the state machine are wrapped with Handler:
+//
+//	ac := sm.NewAdmissionControl(stateMachine, sm.AdmissionRule{
+//		AppID:   4,
+//		PerPeer: true,
+//		Rate:    100,
+//		Burst:   200,
+//	})
+//	stateMachine.Handle("CCR", ac.Handler(handleCCR))
+//
+// Base protocol requests, such as CER, DWR and DPR, are never limited.
+//
+// The counters and buckets of the peers without requests for
+// AdmissionIdleTimeout are removed, see RemoveIdle.
+type AdmissionControl struct {
+	sm    *StateMachine
+	rules []AdmissionRule
+
+	mu      sync.Mutex // guards the following
+	buckets map[bucketKey]*tokenBucket
+	stats   map[AdmissionKey]*admissionCounters
+	sweep   time.Time // Next removal of idle counters and buckets
+}
+
+// NewAdmissionControl creates and initializes a new AdmissionControl
+// with the given rules, answering with the Origin-Host and Origin-Realm
+// of the state machine sm. Requests must pass all the rules that match
+// them.
+func NewAdmissionControl(sm *StateMachine, rules ...AdmissionRule) *AdmissionControl {
+	return &AdmissionControl{
+		sm:      sm,
+		rules:   append([]AdmissionRule(nil), rules...),
+		buckets: make(map[bucketKey]*tokenBucket),
+		stats:   make(map[AdmissionKey]*admissionCounters),
+	}
+}
+
+// Admit reports whether the request m received from the peer of the
+// connection c is within the limits, and takes a token from the bucket
+// of each of the rules that match it when so.
+func (ac *AdmissionControl) Admit(c diam.Conn, m *diam.Message) bool {
+	if !isApplicationRequest(m) {
+		return true
+	}
+	k := AdmissionKey{connOriginHost(c), m.Header.ApplicationID, m.Header.CommandCode}
+	now := time.Now()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if !now.Before(ac.sweep) {
+		ac.removeIdle(now.Add(-AdmissionIdleTimeout), now)
+		ac.sweep = now.Add(admissionSweepInterval)
+	}
+	var matched []*tokenBucket
+	admit := true
+	for i := range ac.rules {
+		r := &ac.rules[i]
+		if !r.match(k) {
+			continue
+		}
+		b := ac.bucket(i, k, now)
+		if b.tokens < 1 {
+			admit = false
+			break
+		}
+		matched = append(matched, b)
+	}
+	stats, ok := ac.stats[k]
+	if !ok {
+		stats = &admissionCounters{}
+		ac.stats[k] = stats
+	}
+	stats.last = now
+	if !admit {
+		stats.Rejected++
+		return false
+	}
+	for _, b := range matched {
+		b.tokens--
+	}
+	stats.Admitted++
+	return true
+}
+
+// bucket returns the bucket of the rule i for the requests of k, refilled
+// up to now.
+func (ac *AdmissionControl) bucket(i int, k AdmissionKey, now time.Time) *tokenBucket {
+	r := &ac.rules[i]
+	burst := float64(r.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	bk := bucketKey{rule: i}
+	if r.PerPeer {
+		bk.host = k.OriginHost
+	}
+	b, ok := ac.buckets[bk]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		ac.buckets[bk] = b
+		return b
+	}
+	b.refill(r, now)
+	return b
+}
+
+// refill adds the tokens of the rule r since the last refill up to now,
+// and reports whether the bucket is full.
+func (b *tokenBucket) refill(r *AdmissionRule, now time.Time) bool {
+	burst := float64(r.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * r.Rate
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+	}
+	return b.tokens >= burst
+}
+
+// RemoveIdle removes the counters of the keys without requests since t,
+// and the buckets unused since t that are full again, and returns how
+// many counters were removed. Full buckets are created again as needed,
+// so removing them does not change the limits.
+func (ac *AdmissionControl) RemoveIdle(t time.Time) int {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return ac.removeIdle(t, time.Now())
+}
+
+// removeIdle is RemoveIdle, refilling the buckets up to now.
+func (ac *AdmissionControl) removeIdle(t, now time.Time) int {
+	var n int
+	for k, s := range ac.stats {
+		if s.last.Before(t) {
+			delete(ac.stats, k)
+			n++
+		}
+	}
+	for k, b := range ac.buckets {
+		if b.last.Before(t) && b.refill(&ac.rules[k.rule], now) {
+			delete(ac.buckets, k)
+		}
+	}
+	return n
+}
+
+// Handler returns a handler that calls h with the requests admitted by
+// Admit, and answers the others with DIAMETER_TOO_BUSY.
+func (ac *AdmissionControl) Handler(h diam.Handler) diam.Handler {
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		if ac.Admit(c, m) {
+			h.ServeDIAM(c, m)
+			return
+		}
+		ac.sm.answerError(c, m, diam.TooBusy, nil, "request rate limit exceeded")
+	})
+}
+
+// Stats returns a copy of the counters of the requests received so far,
+// by peer, application and command. Counters removed by RemoveIdle are
+// not included.
+func (ac *AdmissionControl) Stats() map[AdmissionKey]AdmissionStats {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	stats := make(map[AdmissionKey]AdmissionStats, len(ac.stats))
+	for k, s := range ac.stats {
+		stats[k] = s.AdmissionStats
+	}
+	return stats
+}
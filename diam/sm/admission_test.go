@@ -0,0 +1,125 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm/smpeer"
+)
+
+// admissionTestConn is a diam.Conn with the metadata of a peer.
+type admissionTestConn struct {
+	diam.Conn
+	host datatype.DiameterIdentity
+}
+
+func (c *admissionTestConn) Context() context.Context {
+	return smpeer.NewContext(context.Background(), &smpeer.Metadata{OriginHost: c.host})
+}
+
+func TestAdmissionControl_Admit(t *testing.T) {
+	ac := sm.NewAdmissionControl(sm.New(serverSettings),
+		sm.AdmissionRule{AppID: diam.CHARGING_CONTROL_APP_ID, PerPeer: true, Rate: 0.001, Burst: 1},
+		sm.AdmissionRule{Command: diam.CreditControl, Rate: 0.001, Burst: 2},
+	)
+	newCCR := func(host string) *diam.Message {
+		m := diam.NewRequest(diam.CreditControl, diam.CHARGING_CONTROL_APP_ID, dict.Default)
+		m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity(host))
+		return m
+	}
+	cli1, cli2 := &admissionTestConn{host: "cli1"}, &admissionTestConn{host: "cli2"}
+	for i, tc := range []struct {
+		c    diam.Conn
+		m    *diam.Message
+		want bool
+	}{
+		{cli1, newCCR("cli1"), true},
+		{cli1, newCCR("other"), false}, // Per peer limit, whatever the Origin-Host AVP
+		{cli2, newCCR("cli1"), true},
+		{&admissionTestConn{host: "cli3"}, newCCR("cli3"), false}, // Shared limit
+		{cli1, diam.NewRequest(diam.DeviceWatchdog, 0, dict.Default), true},
+	} {
+		if have := ac.Admit(tc.c, tc.m); have != tc.want {
+			t.Errorf("%d: unexpected admission. Want %v, have %v", i, tc.want, have)
+		}
+	}
+	k := sm.AdmissionKey{OriginHost: "cli1", AppID: diam.CHARGING_CONTROL_APP_ID, Command: diam.CreditControl}
+	if s := ac.Stats()[k]; s.Admitted != 1 || s.Rejected != 1 {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+}
+
+func TestAdmissionControl_RemoveIdle(t *testing.T) {
+	ac := sm.NewAdmissionControl(sm.New(serverSettings),
+		sm.AdmissionRule{PerPeer: true, Rate: 1000, Burst: 1},
+		sm.AdmissionRule{Rate: 0.001, Burst: 3},
+	)
+	m := diam.NewRequest(diam.CreditControl, diam.CHARGING_CONTROL_APP_ID, dict.Default)
+	for _, host := range []datatype.DiameterIdentity{"cli1", "cli2"} {
+		if !ac.Admit(&admissionTestConn{host: host}, m) {
+			t.Fatalf("Request of %s not admitted", host)
+		}
+	}
+	if n := ac.RemoveIdle(time.Now().Add(-time.Minute)); n != 0 {
+		t.Fatalf("Unexpected number of removed counters. Want 0, have %d", n)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := ac.RemoveIdle(time.Now()); n != 2 {
+		t.Fatalf("Unexpected number of removed counters. Want 2, have %d", n)
+	}
+	if stats := ac.Stats(); len(stats) != 0 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	// The shared bucket is not full, and keeps limiting the requests.
+	if !ac.Admit(&admissionTestConn{host: "cli3"}, m) {
+		t.Fatal("Request of cli3 not admitted")
+	}
+	if ac.Admit(&admissionTestConn{host: "cli4"}, m) {
+		t.Fatal("Request of cli4 admitted over the shared limit")
+	}
+}
+
+func TestAdmissionControl_Handler(t *testing.T) {
+	srvSM := sm.New(serverSettings)
+	ac := sm.NewAdmissionControl(srvSM, sm.AdmissionRule{Rate: 0.001, Burst: 2})
+	srvSM.Handle("CCR", ac.Handler(answerCCR(serverSettings)))
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+
+	cli := newTestClient(sm.New(clientSettings), diam.CHARGING_CONTROL_APP_ID)
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, want := range []uint32{diam.Success, diam.Success, diam.TooBusy} {
+		a, err := diam.SendRequest(ctx, c, newTestCCR("", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := a.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := rc.Data.(datatype.Unsigned32); uint32(code) != want {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", want, code)
+		}
+	}
+	k := sm.AdmissionKey{OriginHost: clientSettings.OriginHost, AppID: diam.CHARGING_CONTROL_APP_ID, Command: diam.CreditControl}
+	if s := ac.Stats()[k]; s.Admitted != 2 || s.Rejected != 1 {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+}
@@ -11,21 +11,50 @@ import (
 )
 
 // handleCEA handles Capabilities-Exchange-Answer messages.
-func handleCEA(sm *StateMachine, errc chan error) diam.HandlerFunc {
+//
+// The CEA is only processed on connections initiated by a Client
+// that are waiting for it, and the result is delivered to the
+// pending handshake.
+func handleCEA(sm *StateMachine) diam.HandlerFunc {
 	return func(c diam.Conn, m *diam.Message) {
+		sm.mu.Lock()
+		p, ok := sm.peers[c]
+		if !ok || !p.initiator || (p.state != PeerWaitICEA && p.state != PeerWaitReturns) {
+			sm.mu.Unlock()
+			return
+		}
+		errc := p.ceac
+		sm.mu.Unlock()
 		cea := new(smparser.CEA)
 		if err := cea.Parse(m, smparser.Client); err != nil {
-			errc <- err
+			sendHandshakeResult(errc, err)
+			return
+		}
+		if !sm.ReconnectAllowed(cea.OriginHost) {
+			sendHandshakeResult(errc, ErrReconnectSuppressed)
 			return
 		}
 		meta := smpeer.FromCEA(cea)
 		c.SetContext(smpeer.NewContext(c.Context(), meta))
+		sm.mu.Lock()
+		p.host = cea.OriginHost
+		sm.mu.Unlock()
+		sm.setPeerState(c, PeerIOpen)
 		// Notify about peer passing the handshake.
 		select {
 		case sm.hsNotifyc <- c:
 		default:
 		}
 		// Done receiving and validating this CEA.
-		close(errc)
+		sendHandshakeResult(errc, nil)
+	}
+}
+
+// sendHandshakeResult delivers the result of a handshake to errc,
+// unless a result is already pending.
+func sendHandshakeResult(errc chan error, err error) {
+	select {
+	case errc <- err:
+	default:
 	}
 }
@@ -19,7 +19,12 @@ import (
 // If mandatory AVPs such as Origin-Host or Origin-Realm
 // are missing, we close the connection.
 //
-// See RFC 6733 section 5.3 for details.
+// A CER from a peer that we are connecting to at the same time
+// triggers the election, and the CER is rejected with ELECTION_LOST
+// when the peer wins. A CER from a peer that we are already connected
+// to is rejected by closing the connection.
+//
+// See RFC 6733 sections 5.3 and 5.6.4 for details.
 func handleCER(sm *StateMachine) diam.HandlerFunc {
 	return func(c diam.Conn, m *diam.Message) {
 		ctx := c.Context()
@@ -27,8 +32,24 @@ func handleCER(sm *StateMachine) diam.HandlerFunc {
 			// Ignore retransmission.
 			return
 		}
+		if _, ok := sm.peer(c); ok {
+			// Ignore CER on connections initiated by clients,
+			// or already being processed.
+			return
+		}
+		sm.addPeer(c, &peer{state: PeerClosed})
 		cer := new(smparser.CER)
 		_, err := cer.Parse(m, smparser.Server)
+		if err == nil {
+			switch {
+			case sm.openTo(c, cer.OriginHost):
+				// R-Reject: already connected to this peer.
+				c.Close()
+				return
+			case !sm.elect(c, cer.OriginHost):
+				err = ErrElectionLost
+			}
+		}
 		if err != nil {
 			err = errorCEA(sm, c, m, cer, err)
 			if err != nil {
@@ -53,6 +74,15 @@ func handleCER(sm *StateMachine) diam.HandlerFunc {
 		}
 		meta := smpeer.FromCER(cer)
 		c.SetContext(smpeer.NewContext(ctx, meta))
+		sm.mu.Lock()
+		if p, ok := sm.peers[c]; ok {
+			p.host = cer.OriginHost
+		}
+		sm.mu.Unlock()
+		sm.setPeerState(c, PeerROpen)
+		if sm.cfg.WatchdogInterval > 0 {
+			sm.startWatchdog(c, sm.cfg.WatchdogInterval, 0)
+		}
 		// Notify about peer passing the handshake.
 		select {
 		case sm.hsNotifyc <- c:
@@ -84,6 +114,8 @@ func errorCEA(sm *StateMachine, c diam.Conn, m *diam.Message, cer *smparser.CER,
 		a = m.Answer(diam.NoCommonSecurity)
 	case smparser.ErrNoCommonApplication:
 		a = m.Answer(diam.NoCommonApplication)
+	case ErrElectionLost:
+		a = m.Answer(diam.ElectionLost)
 	default:
 		a = m.Answer(diam.UnableToComply)
 	}
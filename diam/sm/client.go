@@ -29,6 +29,16 @@ var (
 	// handshake timeout only occurs after all retransmits are
 	// attempted and none has an aswer.
 	ErrHandshakeTimeout = errors.New("handshake timeout (no response)")
+
+	// ErrElectionLost is returned by Dial or DialTLS when the connection
+	// is dropped because a simultaneous connection from the same peer won
+	// the election. See RFC 6733 section 5.6.4 for details.
+	ErrElectionLost = errors.New("handshake aborted (election lost)")
+
+	// ErrReconnectSuppressed is returned by Dial or DialTLS when the peer
+	// disconnected with Disconnect-Cause DO_NOT_WANT_TO_TALK_TO_YOU.
+	// See StateMachine.AllowReconnect.
+	ErrReconnectSuppressed = errors.New("peer does not want to talk to us")
 )
 
 // A Client is a diameter client that automatically performs a handshake
@@ -36,27 +46,41 @@ var (
 //
 // It sends a Capabilities-Exchange-Request with the AVPs defined in it,
 // and expects a Capabilities-Exchange-Answer with a success (2001) result
-// code. If enabled, the client will send Device-Watchdog-Request messages
-// in background until the connection is terminated.
+// code. If enabled, the client runs the watchdog of RFC 3539 in background
+// until the connection is terminated: it sends Device-Watchdog-Request
+// messages when the connection is idle, and closes the connection when
+// the peer stops answering. See StateMachine.WatchdogState for details.
 //
-// By default, retransmission and watchdog are disabled. Retransmission is
-// enabled by setting MaxRetransmits to a number greater than zero, and
-// watchdog is enabled by setting EnableWatchdog to true.
+// By default, retransmission and watchdog are disabled. Retransmission of
+// the CER is enabled by setting MaxRetransmits to a number greater than
+// zero, and watchdog is enabled by setting EnableWatchdog to true.
 //
 // A custom message handler for Device-Watchdog-Answer (DWA) can be registered.
 // However, that will be overwritten if watchdog is enabled.
+//
+// When PeerOriginHost is set, the client takes part in the election of
+// RFC 6733 section 5.6.4 if the peer connects to the client's state
+// machine at the same time, and refuses to connect to a peer that
+// disconnected with DO_NOT_WANT_TO_TALK_TO_YOU.
 type Client struct {
-	Dict                        *dict.Parser  // Dictionary parser (uses dict.Default if unset)
-	Handler                     *StateMachine // Message handler
-	MaxRetransmits              uint          // Max number of retransmissions before aborting
-	RetransmitInterval          time.Duration // Interval between retransmissions (default 1s)
-	EnableWatchdog              bool          // Enable automatic DWR
-	WatchdogInterval            time.Duration // Interval between DWRs (default 5s)
-	WatchdogStream              uint          // Stream to send DWR on (for multistreaming protocols), default is 0
-	SupportedVendorID           []*diam.AVP   // Supported vendor ID
-	AcctApplicationID           []*diam.AVP   // Acct applications
-	AuthApplicationID           []*diam.AVP   // Auth applications
-	VendorSpecificApplicationID []*diam.AVP   // Vendor specific applications
+	Dict                        *dict.Parser              // Dictionary parser (uses dict.Default if unset)
+	Handler                     *StateMachine             // Message handler
+	MaxRetransmits              uint                      // Max number of retransmissions before aborting
+	RetransmitInterval          time.Duration             // Interval between retransmissions (default 1s)
+	EnableWatchdog              bool                      // Enable automatic DWR
+	WatchdogInterval            time.Duration             // Watchdog interval, Twinit of RFC 3539 (default 5s)
+	WatchdogStream              uint                      // Stream to send DWR on (for multistreaming protocols), default is 0
+	SupportedVendorID           []*diam.AVP               // Supported vendor ID
+	AcctApplicationID           []*diam.AVP               // Acct applications
+	AuthApplicationID           []*diam.AVP               // Auth applications
+	VendorSpecificApplicationID []*diam.AVP               // Vendor specific applications
+	PeerOriginHost              datatype.DiameterIdentity // Expected Origin-Host of the peer (optional)
+	ReconnectInterval           time.Duration             // Initial delay between reconnections of managed peers (default 1s)
+	MaxReconnectInterval        time.Duration             // Max delay between reconnections of managed peers (default 30s)
+	MaxRedirects                uint                      // Max redirect indications followed by SendRequest (0 disables)
+	Redirects                   *RedirectCache            // Cache of redirect indications followed by SendRequest (optional)
+	Overload                    *OverloadControl          // DOIC reacting node of SendRequest (optional)
+	Loads                       *LoadTable                // Loads of the answers of SendRequest (optional)
 }
 
 // Dial calls the address set as ip:port, performs a handshake and optionally
@@ -131,6 +155,9 @@ func (cli *Client) dial(f dialFunc) (diam.Conn, error) {
 	if err := cli.validate(); err != nil {
 		return nil, err
 	}
+	if cli.PeerOriginHost != "" && !cli.Handler.ReconnectAllowed(cli.PeerOriginHost) {
+		return nil, ErrReconnectSuppressed
+	}
 	c, err := f()
 	if err != nil {
 		return c, err
@@ -154,6 +181,17 @@ func (cli *Client) validate() error {
 		// Set default WatchdogInterval
 		cli.WatchdogInterval = 5 * time.Second
 	}
+	if cli.ReconnectInterval == 0 {
+		// Set default ReconnectInterval
+		cli.ReconnectInterval = time.Second
+	}
+	if cli.MaxReconnectInterval < cli.ReconnectInterval {
+		// Set default MaxReconnectInterval
+		cli.MaxReconnectInterval = 30 * time.Second
+		if cli.MaxReconnectInterval < cli.ReconnectInterval {
+			cli.MaxReconnectInterval = cli.ReconnectInterval
+		}
+	}
 	// Make sure the applications supplied to Client are supported locally
 	for _, submittedAcctApp := range cli.AcctApplicationID {
 		acctAppID := uint32(submittedAcctApp.Data.(datatype.Unsigned32))
@@ -204,20 +242,18 @@ func (cli *Client) handshake(c diam.Conn) (diam.Conn, error) {
 	}
 
 	m := cli.makeCER(hostAddresses)
-	// Ignore CER, but not DWR.
-	cerClientHandler := func(c diam.Conn, m *diam.Message) {}
-	// See sm.go for Base Diam Idx declarations
-	cli.Handler.mux.HandleIdx(baseCERIdx, diam.HandlerFunc(cerClientHandler))
-	cli.Handler.mux.HandleFunc("CER", cerClientHandler)
-	// Handle CEA and DWA.
-	errc := make(chan error)
-	cli.Handler.mux.Handle("CEA", handleCEA(cli.Handler, errc))
+	p := &peer{
+		state:     PeerWaitConnAck,
+		host:      cli.PeerOriginHost,
+		initiator: true,
+		ceac:      make(chan error, 1),
+	}
+	cli.Handler.addPeer(c, p)
 
-	var dwac chan struct{}
 	if cli.EnableWatchdog {
-		dwac = make(chan struct{})
-		cli.Handler.mux.Handle("DWA", handshakeOK(handleDWA(cli.Handler, dwac)))
+		cli.Handler.mux.Handle("DWA", handshakeOK(handleDWA(cli.Handler)))
 	}
+	cli.Handler.setPeerState(c, PeerWaitICEA)
 	for i := 0; i < (int(cli.MaxRetransmits) + 1); i++ {
 		_, err := m.WriteTo(c)
 		if err != nil {
@@ -225,14 +261,13 @@ func (cli *Client) handshake(c diam.Conn) (diam.Conn, error) {
 			return nil, err
 		}
 		select {
-		case err, ok := <-errc: // Wait for CEA.
-			if ok && err != nil {
-				close(errc)
+		case err := <-p.ceac: // Wait for CEA.
+			if err != nil {
 				c.Close()
 				return nil, err
 			}
 			if cli.EnableWatchdog {
-				go cli.watchdog(c, dwac)
+				cli.Handler.startWatchdog(c, cli.WatchdogInterval, cli.WatchdogStream)
 			}
 			return c, nil
 		case <-time.After(cli.RetransmitInterval):
@@ -282,46 +317,6 @@ func (cli *Client) makeCER(hostIPAddresses []datatype.Address) *diam.Message {
 	return m
 }
 
-func (cli *Client) watchdog(c diam.Conn, dwac chan struct{}) {
-	disconnect := c.(diam.CloseNotifier).CloseNotify()
-	var osid = uint32(cli.Handler.cfg.OriginStateID)
-	for {
-		select {
-		case <-disconnect:
-			return
-		case <-time.After(cli.WatchdogInterval):
-			cli.dwr(c, osid, dwac)
-		}
-	}
-}
-
-func (cli *Client) dwr(c diam.Conn, osid uint32, dwac chan struct{}) {
-	m := cli.makeDWR(osid)
-	for i := 0; i < (int(cli.MaxRetransmits) + 1); i++ {
-		_, err := m.WriteToStream(c, cli.WatchdogStream)
-		if err != nil {
-			return
-		}
-		select {
-		case <-dwac:
-			return
-		case <-time.After(cli.RetransmitInterval):
-		}
-	}
-	// Watchdog failed, disconnect.
-	c.Close()
-}
-
-func (cli *Client) makeDWR(osid uint32) *diam.Message {
-	m := diam.NewRequest(diam.DeviceWatchdog, 0, cli.Dict)
-	m.NewAVP(avp.OriginHost, avp.Mbit, 0, cli.Handler.cfg.OriginHost)
-	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, cli.Handler.cfg.OriginRealm)
-	if cli.Handler.cfg.OriginStateID != 0 {
-		m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(osid))
-	}
-	return m
-}
-
 func getHostsWithoutPort(hosts string) (string, error) {
 	i := len(hosts) - 1
 	for ; i >= 0 && hosts[i] != ':'; i-- {
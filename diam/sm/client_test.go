@@ -16,6 +16,7 @@ import (
 
 	"github.com/ctrlzy/go-diameter/v4/diam"
 	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/redirecthostusage"
 	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
 	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
 	"github.com/ctrlzy/go-diameter/v4/diam/dict"
@@ -463,3 +464,83 @@ func getHostsWithoutPort(hosts string) (string, error) {
 	}
 	return hosts[:i], nil
 }
+
+func TestClient_SendRequest_FollowRedirect(t *testing.T) {
+	var redirects, answers int32
+	conns := make(chan diam.Conn, 2)
+	targetSM := sm.New(serverSettings2)
+	targetSM.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		atomic.AddInt32(&answers, 1)
+		conns <- c
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, serverSettings2.OriginHost)
+		a.NewAVP(avp.OriginRealm, avp.Mbit, 0, serverSettings2.OriginRealm)
+		a.WriteTo(c)
+	})
+	target := diamtest.NewServer(targetSM, dict.Default)
+	defer target.Close()
+
+	redirectSM := sm.New(serverSettings)
+	redirectSM.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		atomic.AddInt32(&redirects, 1)
+		a := m.Answer(diam.RedirectIndication)
+		a.Header.CommandFlags |= diam.ErrorFlag
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, serverSettings.OriginHost)
+		a.NewAVP(avp.OriginRealm, avp.Mbit, 0, serverSettings.OriginRealm)
+		a.NewAVP(avp.RedirectHost, avp.Mbit, 0, datatype.DiameterURI("aaa://"+target.Addr))
+		a.NewAVP(avp.RedirectHostUsage, avp.Mbit, 0, redirecthostusage.ALL_REALM)
+		a.NewAVP(avp.RedirectMaxCacheTime, avp.Mbit, 0, datatype.Unsigned32(60))
+		a.WriteTo(c)
+	})
+	redirect := diamtest.NewServer(redirectSM, dict.Default)
+	defer redirect.Close()
+
+	cli := &sm.Client{
+		Handler: sm.New(clientSettings),
+		AuthApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(diam.CHARGING_CONTROL_APP_ID)),
+		},
+		MaxRedirects: 1,
+		Redirects:    sm.NewRedirectCache(),
+	}
+	c, err := cli.Dial(redirect.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		m := diam.NewRequest(diam.CreditControl, diam.CHARGING_CONTROL_APP_ID, dict.Default)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(fmt.Sprintf("cli;%d", i)))
+		m.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+		m.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+		m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, serverSettings2.OriginRealm)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		a, err := cli.SendRequest(ctx, c, m)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !testResultCode(a, diam.Success) {
+			t.Fatalf("Unexpected answer %s", a)
+		}
+	}
+	if n := atomic.LoadInt32(&redirects); n != 1 {
+		t.Fatalf("Unexpected number of redirects. Want 1 (then cached), have %d", n)
+	}
+	if n := atomic.LoadInt32(&answers); n != 2 {
+		t.Fatalf("Unexpected number of answers. Want 2, have %d", n)
+	}
+	// The connection dialed to the redirect host is reused, and closed
+	// when the redirect indication expires.
+	tc := <-conns
+	if c := <-conns; c.RemoteAddr().String() != tc.RemoteAddr().String() {
+		t.Fatalf("Unexpected connection to the redirect host. Want %s, have %s",
+			tc.RemoteAddr(), c.RemoteAddr())
+	}
+	cli.Redirects.RemoveExpired(time.Now().Add(time.Hour))
+	select {
+	case <-tc.(diam.CloseNotifier).CloseNotify():
+	case <-time.After(time.Second):
+		t.Fatal("Connection to the redirect host not closed")
+	}
+}
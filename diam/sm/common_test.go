@@ -9,6 +9,8 @@ import (
 	"net"
 	"time"
 
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
 	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
 	"github.com/ctrlzy/go-diameter/v4/diam/dict"
 	"github.com/ctrlzy/go-diameter/v4/diam/sm"
@@ -74,3 +76,51 @@ var (
 		HostIPAddresses:  []datatype.Address{localhostAddress},
 	}
 )
+
+// newTestClient returns a client of the state machine handler which
+// advertises the application appID, as an accounting application if
+// so declared in the dictionary.
+func newTestClient(handler *sm.StateMachine, appID uint32) *sm.Client {
+	cli := &sm.Client{Handler: handler}
+	if app, err := dict.Default.App(appID); err == nil && app.Type == "acct" {
+		cli.AcctApplicationID = []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(appID)),
+		}
+	} else {
+		cli.AuthApplicationID = []*diam.AVP{
+			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(appID)),
+		}
+	}
+	return cli
+}
+
+// newTestCCR returns a CCR of clientSettings with the Destination-Realm
+// and Destination-Host AVPs of realm and host, if not empty.
+func newTestCCR(realm, host string) *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, diam.CHARGING_CONTROL_APP_ID, dict.Default)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+	if realm != "" {
+		m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity(realm))
+	}
+	if host != "" {
+		m.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.DiameterIdentity(host))
+	}
+	return m
+}
+
+// newTestCCA returns a successful answer of settings to the CCR m.
+func newTestCCA(m *diam.Message, settings *sm.Settings) *diam.Message {
+	a := m.Answer(diam.Success)
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, settings.OriginHost)
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, settings.OriginRealm)
+	return a
+}
+
+// answerCCR returns a handler answering CCRs with newTestCCA.
+func answerCCR(settings *sm.Settings) diam.HandlerFunc {
+	return func(c diam.Conn, m *diam.Message) {
+		newTestCCA(m, settings).WriteTo(c)
+	}
+}
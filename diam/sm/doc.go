@@ -4,7 +4,51 @@
 
 // Package sm provides diameter state machines for clients and servers.
 //
-// It currently handles CER/CEA handshakes, and automatic DWR/DWA. Peers
-// that pass the handshake get metadata associated to their connection.
-// See the peer sub-package for details on the metadata.
+// It currently handles CER/CEA handshakes, automatic DWR/DWA and DPR/DPA.
+// Peers that pass the handshake get metadata associated to their
+// connection. See the peer sub-package for details on the metadata.
+//
+// The state of each connection follows the peer state machine of
+// RFC 6733 section 5.6, including the election on simultaneous
+// connections, and transitions can be observed with PeerStateNotify.
+// Connections are gracefully closed with Disconnect.
+//
+// Clients and servers may run the watchdog of RFC 3539 on their
+// connections, see Client.EnableWatchdog and Settings.WatchdogInterval.
+// The watchdog state of each connection is available with WatchdogState,
+// and transitions can be observed with WatchdogStateNotify.
+//
+// The peers that pass the handshake are kept in the PeerTable of the
+// state machine, keyed by Origin-Host. Together with a RoutingTable of
+// realms and applications, it allows selecting the peer to send each
+// request to with RoutingTable.SelectPeer, as in RFC 6733 section 6.1.
+//
+// Client.SendRequest follows redirect indications of redirect agents,
+// and may keep them in a RedirectCache according to their
+// Redirect-Host-Usage and Redirect-Max-Cache-Time.
+//
+// Overload control of RFC 7683 (DOIC) is provided by an OverloadReporter
+// on servers, which adds overload reports to the answers of its handlers
+// according to a LoadEstimator, and an OverloadControl on clients, which
+// throttles the requests of Client.SendRequest according to them.
+// Similarly, servers may report their load as in RFC 8583 with a
+// LoadReporter, and clients keep the loads of the answers in a LoadTable
+// to select the least loaded peers with RoutingTable.SelectPeerByLoad.
+//
+// Requests sent with SendRequestFailover are retransmitted with the 'T'
+// flag to an alternate peer when their peer fails over, and servers may
+// detect such duplicates with Settings.DuplicateDetectionTime.
+//
+// Servers may answer protocol and validation errors, such as unknown
+// commands or missing AVPs, with error answers as in RFC 6733 section
+// 7, see Settings.AnswerErrors. Their handlers may be wrapped by an
+// AdmissionControl, which answers the requests exceeding its rate
+// limits with DIAMETER_TOO_BUSY.
+//
+// Long-running clients may use a ManagedPeer, created by Client.DialPeer,
+// to keep a connection to a peer that is re-established automatically.
+//
+// It also provides a Session-Id generator compliant with RFC 6733
+// section 8.8, and a SessionStore to keep track of sessions and the
+// peers that own them.
 package sm
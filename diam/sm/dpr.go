@@ -0,0 +1,133 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/disconnectcause"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm/smparser"
+)
+
+// ErrPeerNotOpen is returned by Disconnect when the connection
+// is not in the R-Open or I-Open state.
+var ErrPeerNotOpen = errors.New("peer connection is not open")
+
+// DisconnectGracePeriod is how long the receiver of a DPR waits for
+// the peer to close the connection after sending the DPA, before
+// closing it. See RFC 6733 section 5.4 for details.
+var DisconnectGracePeriod = 5 * time.Second
+
+// handleDPR handles Disconnect-Peer-Request messages.
+//
+// If mandatory AVPs such as Origin-Host or Origin-Realm are missing,
+// we ignore the message. Otherwise we answer with a DPA and wait for
+// the peer to close the connection. A Disconnect-Cause of
+// DO_NOT_WANT_TO_TALK_TO_YOU suppresses reconnection to the peer.
+//
+// See RFC 6733 section 5.4 for details.
+func handleDPR(sm *StateMachine) diam.HandlerFunc {
+	return func(c diam.Conn, m *diam.Message) {
+		dpr := new(smparser.DPR)
+		err := dpr.Parse(m)
+		if err != nil {
+			sm.Error(&diam.ErrorReport{
+				Conn:    c,
+				Message: m,
+				Error:   err,
+			})
+			return
+		}
+		if cause, ok := dpr.Cause(); ok && cause == disconnectcause.DO_NOT_WANT_TO_TALK_TO_YOU {
+			sm.mu.Lock()
+			sm.noReconnect[dpr.OriginHost] = true
+			sm.mu.Unlock()
+		}
+		sm.setPeerState(c, PeerClosing)
+		a := m.Answer(diam.Success)
+		// Fix for Same H2H and E2E Identifier in success response
+		a.Header.HopByHopID = m.Header.HopByHopID
+		a.Header.EndToEndID = m.Header.EndToEndID
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, sm.cfg.OriginHost)
+		a.NewAVP(avp.OriginRealm, avp.Mbit, 0, sm.cfg.OriginRealm)
+		_, err = a.WriteTo(c)
+		if err != nil {
+			sm.Error(&diam.ErrorReport{
+				Conn:    c,
+				Message: m,
+				Error:   err,
+			})
+			c.Close()
+			return
+		}
+		var disconnect <-chan struct{}
+		if cn, ok := c.(diam.CloseNotifier); ok {
+			disconnect = cn.CloseNotify()
+		}
+		go func() {
+			select {
+			case <-disconnect:
+			case <-time.After(DisconnectGracePeriod):
+			}
+			c.Close()
+		}()
+	}
+}
+
+// handleDPA handles Disconnect-Peer-Answer messages that arrive
+// after Disconnect gave up waiting for them.
+func handleDPA(sm *StateMachine) diam.HandlerFunc {
+	return func(c diam.Conn, m *diam.Message) {}
+}
+
+// Disconnect gracefully closes the open connection c: it sends a DPR
+// with the given Disconnect-Cause, waits up to timeout for the DPA and
+// closes the connection. The connection is closed even when the DPA
+// is not received, in which case the error of the request is returned.
+//
+// See the disconnectcause package for the possible causes, and
+// RFC 6733 section 5.4 for details.
+func (sm *StateMachine) Disconnect(c diam.Conn, cause datatype.Enumerated, timeout time.Duration) error {
+	if !sm.PeerState(c).Open() {
+		return ErrPeerNotOpen
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return sm.DisconnectPeer(ctx, c, cause)
+}
+
+// DisconnectPeer sends a DPR with the given Disconnect-Cause on the open
+// connection c, and waits for the DPA until ctx is done, as Disconnect,
+// but does not close the connection. It implements the diam.Disconnecter
+// interface, used by diam.Server.Shutdown.
+func (sm *StateMachine) DisconnectPeer(ctx context.Context, c diam.Conn, cause datatype.Enumerated) error {
+	if !sm.PeerState(c).Open() {
+		return ErrPeerNotOpen
+	}
+	sm.setPeerState(c, PeerClosing)
+	m := diam.NewRequest(diam.DisconnectPeer, 0, c.Dictionary())
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, sm.cfg.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, sm.cfg.OriginRealm)
+	m.NewAVP(avp.DisconnectCause, avp.Mbit, 0, cause)
+	a, err := diam.SendRequest(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	dpa := new(smparser.DPA)
+	if err = dpa.Parse(a); err != nil {
+		return err
+	}
+	if dpa.ResultCode != diam.Success {
+		return fmt.Errorf("unexpected DPA Result-Code %d", dpa.ResultCode)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/disconnectcause"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm"
+)
+
+// These tests use dictionary, settings and functions from sm_test.go.
+
+func waitPeerState(t *testing.T, events <-chan sm.PeerStateEvent, want sm.PeerState) sm.PeerStateEvent {
+	t.Helper()
+	for {
+		select {
+		case ev := <-events:
+			if ev.To == want {
+				return ev
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for peer state %s", want)
+		}
+	}
+}
+
+func TestStateMachine_Disconnect(t *testing.T) {
+	srvSM := sm.New(serverSettings)
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+	cli := newTestClient(sm.New(clientSettings), 1001)
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if state := cli.Handler.PeerState(c); state != sm.PeerIOpen {
+		t.Fatalf("Unexpected client peer state. Want I-Open, have %s", state)
+	}
+	ev := waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerROpen)
+	if ev.OriginHost != clientSettings.OriginHost {
+		t.Fatalf("Unexpected Origin-Host. Want %s, have %s",
+			clientSettings.OriginHost, ev.OriginHost)
+	}
+	err = cli.Handler.Disconnect(c, disconnectcause.REBOOTING, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerClosing)
+	waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerClosed)
+	waitPeerState(t, cli.Handler.PeerStateNotify(), sm.PeerClosed)
+	if !cli.Handler.ReconnectAllowed(serverSettings.OriginHost) {
+		t.Fatal("Unexpected reconnection suppressed after REBOOTING")
+	}
+	err = cli.Handler.Disconnect(c, disconnectcause.REBOOTING, time.Second)
+	if err != sm.ErrPeerNotOpen {
+		t.Fatalf("Unexpected error. Want %v, have %v", sm.ErrPeerNotOpen, err)
+	}
+}
+
+func TestStateMachine_Disconnect_DoNotWantToTalkToYou(t *testing.T) {
+	srvSM := sm.New(serverSettings)
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+	cli := newTestClient(sm.New(clientSettings), 1001)
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ev := waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerROpen)
+	err = srvSM.Disconnect(ev.Conn, disconnectcause.DO_NOT_WANT_TO_TALK_TO_YOU, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitPeerState(t, cli.Handler.PeerStateNotify(), sm.PeerClosed)
+	if cli.Handler.ReconnectAllowed(serverSettings.OriginHost) {
+		t.Fatal("Unexpected reconnection allowed after DO_NOT_WANT_TO_TALK_TO_YOU")
+	}
+	if _, err = cli.Dial(srv.Addr); err != sm.ErrReconnectSuppressed {
+		t.Fatalf("Unexpected error. Want %v, have %v", sm.ErrReconnectSuppressed, err)
+	}
+	cli.PeerOriginHost = serverSettings.OriginHost
+	if _, err = cli.Dial(srv.Addr); err != sm.ErrReconnectSuppressed {
+		t.Fatalf("Unexpected error. Want %v, have %v", sm.ErrReconnectSuppressed, err)
+	}
+	cli.Handler.AllowReconnect(serverSettings.OriginHost)
+	c, err = cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
+
+func TestStateMachine_PeerTable(t *testing.T) {
+	srvSM := sm.New(serverSettings)
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+	cli := newTestClient(sm.New(clientSettings), 1001)
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerROpen)
+	e, ok := srvSM.PeerTable().Get(clientSettings.OriginHost)
+	if !ok {
+		t.Fatal("Peer not found in the server peer table")
+	}
+	if e.Conn != ev.Conn || e.State != sm.PeerROpen || !e.Available() || !e.Supports(1001) {
+		t.Fatalf("Unexpected peer entry: %+v", e)
+	}
+	if e.Realm != clientSettings.OriginRealm {
+		t.Fatalf("Unexpected realm. Want %s, have %s", clientSettings.OriginRealm, e.Realm)
+	}
+	if _, ok = cli.Handler.PeerTable().Get(serverSettings.OriginHost); !ok {
+		t.Fatal("Peer not found in the client peer table")
+	}
+	c.Close()
+	waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerClosed)
+	if _, ok = srvSM.PeerTable().Get(clientSettings.OriginHost); ok {
+		t.Fatal("Unexpected peer in the server peer table after disconnection")
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	srvSM := sm.New(serverSettings)
+	srv := diamtest.NewUnstartedServer(srvSM, dict.Default)
+	srv.Config.DisconnectCause = disconnectcause.DO_NOT_WANT_TO_TALK_TO_YOU
+	srv.Start()
+	defer srv.Close()
+	cli := newTestClient(sm.New(clientSettings), 1001)
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerROpen)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = srv.Config.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitPeerState(t, cli.Handler.PeerStateNotify(), sm.PeerClosed)
+	if cli.Handler.ReconnectAllowed(serverSettings.OriginHost) {
+		t.Fatal("Unexpected reconnection allowed after DO_NOT_WANT_TO_TALK_TO_YOU")
+	}
+	cli.Handler.AllowReconnect(serverSettings.OriginHost)
+	if _, err = cli.Dial(srv.Addr); err == nil {
+		t.Fatal("Unexpected connection after Shutdown")
+	}
+}
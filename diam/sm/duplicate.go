@@ -0,0 +1,148 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// dupKey identifies a request across retransmissions. See RFC 6733
+// section 3 for details on the End-to-End Identifier.
+type dupKey struct {
+	endToEnd uint32
+	host     datatype.DiameterIdentity // Origin-Host of the request
+}
+
+// dupReceiver is a connection waiting for the answer of a request,
+// identified by its Hop-by-Hop Identifier.
+type dupReceiver struct {
+	c        diam.Conn
+	hopByHop uint32
+}
+
+type dupEntry struct {
+	key     dupKey
+	expires time.Time
+	request dupReceiver   // The request passed to the handler
+	answer  []byte        // Nil until the handler answers
+	waiting []dupReceiver // Duplicates received before the answer
+}
+
+// duplicates is the duplicate detection cache of a state machine. It
+// keeps the answers to the requests received in the last ttl, and
+// answers retransmissions of those requests with them.
+type duplicates struct {
+	ttl time.Duration
+
+	mu      sync.Mutex // guards the following
+	entries map[dupKey]*dupEntry
+	pending map[dupReceiver]*dupEntry // Requests waiting for the handler
+	order   []*dupEntry               // Entries in order of expiration
+}
+
+func newDuplicates(ttl time.Duration) *duplicates {
+	return &duplicates{
+		ttl:     ttl,
+		entries: make(map[dupKey]*dupEntry),
+		pending: make(map[dupReceiver]*dupEntry),
+	}
+}
+
+// check reports whether the request m received on c is a duplicate of
+// a request already received, in which case it is answered with the
+// cached answer, or when the answer is ready if still being handled.
+func (d *duplicates) check(c diam.Conn, m *diam.Message) bool {
+	host, ok := identityAVP(m, avp.OriginHost)
+	if !ok {
+		return false
+	}
+	key := dupKey{m.Header.EndToEndID, host}
+	r := dupReceiver{c, m.Header.HopByHopID}
+	now := time.Now()
+	d.mu.Lock()
+	d.expire(now)
+	e, ok := d.entries[key]
+	if !ok {
+		e = &dupEntry{key: key, expires: now.Add(d.ttl), request: r}
+		d.entries[key] = e
+		d.pending[r] = e
+		d.order = append(d.order, e)
+		d.mu.Unlock()
+		return false
+	}
+	answer := e.answer
+	if answer == nil {
+		e.waiting = append(e.waiting, r)
+	}
+	d.mu.Unlock()
+	if answer != nil {
+		r.write(answer)
+	}
+	return true
+}
+
+// answered stores the message b written to c if it is the answer to a
+// pending request, and sends it to the duplicates of the request.
+func (d *duplicates) answered(c diam.Conn, b []byte) {
+	if len(b) < diam.HeaderLength || b[4]&diam.RequestFlag != 0 {
+		return
+	}
+	r := dupReceiver{c, binary.BigEndian.Uint32(b[12:16])}
+	d.mu.Lock()
+	e, ok := d.pending[r]
+	if !ok {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.pending, r)
+	e.answer = append([]byte(nil), b...)
+	waiting := e.waiting
+	e.waiting = nil
+	d.mu.Unlock()
+	for _, w := range waiting {
+		w.write(e.answer)
+	}
+}
+
+// expire removes the entries expired at now. It must be called with
+// d.mu held.
+func (d *duplicates) expire(now time.Time) {
+	var n int
+	for _, e := range d.order {
+		if now.Before(e.expires) {
+			break
+		}
+		delete(d.entries, e.key)
+		// A newer request may be pending with the same Hop-by-Hop.
+		if d.pending[e.request] == e {
+			delete(d.pending, e.request)
+		}
+		n++
+	}
+	if n > 0 {
+		d.order = append(d.order[:0], d.order[n:]...)
+	}
+}
+
+// write writes a copy of the answer b to the receiver, with its
+// Hop-by-Hop Identifier.
+func (r dupReceiver) write(b []byte) {
+	a := append([]byte(nil), b...)
+	binary.BigEndian.PutUint32(a[12:16], r.hopByHop)
+	r.c.Write(a)
+}
+
+// ObserveWrite implements the diam.WriteObserver interface.
+func (sm *StateMachine) ObserveWrite(c diam.Conn, b []byte) {
+	if sm.dups != nil {
+		sm.dups.answered(c, b)
+	}
+}
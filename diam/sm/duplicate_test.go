@@ -0,0 +1,36 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+func TestDuplicates_ExpirePending(t *testing.T) {
+	d := newDuplicates(time.Minute)
+	newCCR := func(endToEnd uint32) *diam.Message {
+		m := diam.NewMessage(diam.CreditControl, diam.RequestFlag, 4, 1, endToEnd, dict.Default)
+		m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+		return m
+	}
+	// Both requests are pending with the same Hop-by-Hop Identifier.
+	d.check(nil, newCCR(1))
+	d.check(nil, newCCR(2))
+	first := d.entries[dupKey{1, "cli"}]
+	first.expires = time.Now().Add(-time.Second)
+	d.expire(time.Now())
+	if _, ok := d.entries[first.key]; ok {
+		t.Fatal("Expired entry not removed")
+	}
+	if e := d.pending[dupReceiver{nil, 1}]; e == nil || e.key.endToEnd != 2 {
+		t.Fatalf("Unexpected pending entry %+v", e)
+	}
+}
@@ -9,10 +9,9 @@ import (
 	"github.com/ctrlzy/go-diameter/v4/diam/sm/smparser"
 )
 
-var dwaACK = struct{}{}
-
-// handleDWA handles Device-Watchdog-Answer messages.
-func handleDWA(sm *StateMachine, dwac chan struct{}) diam.HandlerFunc {
+// handleDWA handles Device-Watchdog-Answer messages, and reports
+// the successful ones to the watchdog of the connection.
+func handleDWA(sm *StateMachine) diam.HandlerFunc {
 	return func(c diam.Conn, m *diam.Message) {
 		dwa := new(smparser.DWA)
 		if err := dwa.Parse(m); err != nil {
@@ -26,9 +25,11 @@ func handleDWA(sm *StateMachine, dwac chan struct{}) diam.HandlerFunc {
 		if dwa.ResultCode != diam.Success {
 			return
 		}
-		select {
-		case dwac <- dwaACK:
-		default:
+		sm.mu.Lock()
+		p, ok := sm.peers[c]
+		sm.mu.Unlock()
+		if ok && p.wd != nil {
+			p.wd.dwa()
 		}
 	}
 }
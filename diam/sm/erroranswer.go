@@ -0,0 +1,124 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// ErrorAnswer returns the answer to the request m with the given
+// Result-Code, generated by the local node. It has the 'E' bit set for
+// protocol errors, the Session-Id and Proxy-Info AVPs of m, and the
+// Origin-Host and Origin-Realm of the state machine settings.
+func (sm *StateMachine) ErrorAnswer(m *diam.Message, code uint32) *diam.Message {
+	a := m.Answer(code)
+	if code >= 3000 && code < 4000 {
+		a.Header.CommandFlags |= diam.ErrorFlag
+	}
+	if sid, err := m.FindAVP(avp.SessionID, 0); err == nil {
+		a.InsertAVP(sid)
+	}
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, sm.cfg.OriginHost)
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, sm.cfg.OriginRealm)
+	for _, pi := range m.AVP {
+		if pi.Code == avp.ProxyInfo && pi.VendorID == 0 {
+			a.AddAVP(pi)
+		}
+	}
+	return a
+}
+
+// answerError answers the request m received on c with the given
+// Result-Code, the error message msg and the offending AVP failed in
+// the Failed-AVP, if not nil. See RFC 6733 section 7.
+func (sm *StateMachine) answerError(c diam.Conn, m *diam.Message, code uint32, failed *diam.AVP, msg string) {
+	a := sm.ErrorAnswer(m, code)
+	a.NewAVP(avp.ErrorMessage, 0, 0, datatype.UTF8String(msg))
+	if failed != nil {
+		a.NewAVP(avp.FailedAVP, avp.Mbit, 0, &diam.GroupedAVP{
+			AVP: []*diam.AVP{failed},
+		})
+	}
+	if _, err := a.WriteToStream(c, m.MessageStream()); err != nil {
+		sm.Error(&diam.ErrorReport{Conn: c, Message: a, Error: err})
+	}
+}
+
+// ServeMessageError implements the diam.MessageErrorHandler interface.
+//
+// When Settings.AnswerErrors is set, requests that fail to decode are
+// answered with the Result-Code of the error, and the connection is
+// kept. Answers that fail to decode are discarded.
+func (sm *StateMachine) ServeMessageError(c diam.Conn, err *diam.MessageError) bool {
+	if !sm.cfg.AnswerErrors {
+		return false
+	}
+	m := err.Message
+	if m.Header.CommandFlags&diam.RequestFlag == diam.RequestFlag {
+		sm.answerError(c, m, err.Code, err.AVP, err.Error())
+	}
+	return true
+}
+
+// answerInvalid answers the request m received on c with an error if
+// its application is not supported, or it fails validation against the
+// dictionary. It reports whether m was answered.
+func (sm *StateMachine) answerInvalid(c diam.Conn, m *diam.Message) bool {
+	if !sm.supportsApp(m.Header.ApplicationID) {
+		sm.answerError(c, m, diam.ApplicationUnsupported, nil,
+			fmt.Sprintf("application %d is not supported", m.Header.ApplicationID))
+		return true
+	}
+	err := m.Validate()
+	if err == nil && sm.cfg.ValidateEnumerated {
+		err = m.ValidateEnumerated()
+	}
+	if err == nil {
+		return false
+	}
+	var verr *diam.ValidationError
+	if errors.As(err, &verr) {
+		sm.answerError(c, m, verr.Code, verr.AVP, verr.Error())
+	} else {
+		sm.answerError(c, m, diam.UnableToComply, nil, err.Error())
+	}
+	return true
+}
+
+// supportsApp reports whether the application id is supported by the
+// state machine.
+func (sm *StateMachine) supportsApp(id uint32) bool {
+	if id == 0 || id == RelayApplicationID {
+		return true
+	}
+	for _, app := range sm.supportedApps {
+		if app.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// isApplicationRequest reports whether m is a request to be passed to
+// the handlers, rather than a base protocol request handled by the
+// state machine itself.
+func isApplicationRequest(m *diam.Message) bool {
+	if m.Header.CommandFlags&diam.RequestFlag == 0 {
+		return false
+	}
+	if m.Header.ApplicationID != 0 {
+		return true
+	}
+	switch m.Header.CommandCode {
+	case diam.CapabilitiesExchange, diam.DeviceWatchdog, diam.DisconnectPeer:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm"
+)
+
+// These tests use dictionary, settings and functions from sm_test.go
+// and common_test.go.
+
+func newValidCCR() *diam.Message {
+	m := newTestCCR(string(serverSettings.OriginRealm), "")
+	m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(diam.CHARGING_CONTROL_APP_ID))
+	m.NewAVP(avp.ServiceContextID, avp.Mbit, 0, datatype.UTF8String("32251@3gpp.org"))
+	m.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(1))
+	m.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+	return m
+}
+
+func TestStateMachine_AnswerErrors(t *testing.T) {
+	settings := *serverSettings
+	settings.AnswerErrors = true
+	settings.ValidateEnumerated = true
+	srvSM := sm.New(&settings)
+	srvSM.HandleFunc("CCR", answerCCR(serverSettings))
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+	cli := newTestClient(sm.New(clientSettings), diam.CHARGING_CONTROL_APP_ID)
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	hdrBits := newValidCCR()
+	hdrBits.Header.CommandFlags |= diam.ErrorFlag
+
+	enum := newValidCCR()
+	rt, _ := enum.FindAVP(avp.CCRequestType, 0)
+	rt.Data = datatype.Enumerated(99)
+
+	app := diam.NewRequest(diam.DeviceWatchdog, 9999, dict.Default)
+	app.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+	app.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+
+	for _, tc := range []struct {
+		name   string
+		m      *diam.Message
+		code   uint32
+		failed uint32 // Code of the AVP in Failed-AVP, if any
+	}{
+		{"missing AVP", newTestCCR("", ""), diam.MissingAVP, avp.DestinationRealm},
+		{"invalid header bits", hdrBits, diam.InvalidHDRBits, 0},
+		{"undefined Enumerated value", enum, diam.InvalidAVPValue, avp.CCRequestType},
+		{"unsupported application", app, diam.ApplicationUnsupported, 0},
+		{"valid request", newValidCCR(), diam.Success, 0},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		a, err := diam.SendRequest(ctx, c, tc.m)
+		cancel()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !testResultCode(a, tc.code) {
+			t.Errorf("%s: unexpected answer %s", tc.name, a)
+			continue
+		}
+		if tc.code == diam.Success {
+			continue
+		}
+		isErr := a.Header.CommandFlags&diam.ErrorFlag == diam.ErrorFlag
+		if protocol := tc.code < 4000; isErr != protocol {
+			t.Errorf("%s: unexpected 'E' bit in %s", tc.name, a)
+		}
+		if oh, err := a.FindAVP(avp.OriginHost, 0); err != nil || oh.Data != serverSettings.OriginHost {
+			t.Errorf("%s: unexpected Origin-Host in %s", tc.name, a)
+		}
+		if _, err := a.FindAVP(avp.ErrorMessage, 0); err != nil {
+			t.Errorf("%s: missing Error-Message in %s", tc.name, a)
+		}
+		f, err := a.FindAVP(avp.FailedAVP, 0)
+		if tc.failed == 0 {
+			if err == nil {
+				t.Errorf("%s: unexpected Failed-AVP in %s", tc.name, a)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: missing Failed-AVP in %s", tc.name, a)
+			continue
+		}
+		g := f.Data.(*diam.GroupedAVP)
+		if len(g.AVP) != 1 || g.AVP[0].Code != tc.failed {
+			t.Errorf("%s: unexpected Failed-AVP %s", tc.name, f)
+		}
+	}
+}
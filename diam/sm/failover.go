@@ -0,0 +1,80 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"context"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// failoverNotify returns a channel that is closed when connection c
+// fails over: its watchdog leaves OKAY, or it is closed. See RFC 3539
+// section 3.4.1.
+func (sm *StateMachine) failoverNotify(c diam.Conn) <-chan struct{} {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	p, ok := sm.peers[c]
+	if !ok || (p.wd != nil && p.wdState != WatchdogOkay) {
+		failc := make(chan struct{})
+		close(failc)
+		return failc
+	}
+	if p.failc == nil {
+		p.failc = make(chan struct{})
+	}
+	return p.failc
+}
+
+// SendRequestFailover sends the request m to the first available peer
+// of hosts that supports its application, and waits for its answer.
+//
+// If the connection to the peer fails over before the answer arrives,
+// because its watchdog leaves OKAY or the connection is closed, the
+// request is retransmitted with the 'T' flag to the next available
+// peer of hosts. The End-to-End Identifier of m is kept, so that the
+// peers can detect duplicates. See RFC 6733 section 5.5.4.
+//
+// It fails with ErrUnableToDeliver when there are no more peers to
+// try, or with the error of diam.SendRequest when ctx is done first.
+func (sm *StateMachine) SendRequestFailover(ctx context.Context, m *diam.Message, hosts []datatype.DiameterIdentity) (*diam.Message, error) {
+	tried := make(map[datatype.DiameterIdentity]bool, len(hosts))
+	for _, host := range hosts {
+		if tried[host] {
+			continue
+		}
+		e, ok := sm.peerTable.Get(host)
+		if !ok || !e.Available() || !e.Supports(m.Header.ApplicationID) {
+			continue
+		}
+		tried[host] = true
+		a, err := sm.sendRequestUntilFailover(ctx, e.Conn, m)
+		if err == nil {
+			return a, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		m.Header.CommandFlags |= diam.RetransmittedFlag
+	}
+	return nil, ErrUnableToDeliver
+}
+
+// sendRequestUntilFailover sends the request m using c, and gives up
+// waiting for its answer when c fails over.
+func (sm *StateMachine) sendRequestUntilFailover(ctx context.Context, c diam.Conn, m *diam.Message) (*diam.Message, error) {
+	failc := sm.failoverNotify(c)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-failc:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return diam.SendRequest(ctx, c, m)
+}
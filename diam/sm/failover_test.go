@@ -0,0 +1,121 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm"
+)
+
+// These tests use dictionary, settings and functions from sm_test.go.
+
+func TestStateMachine_SendRequestFailover(t *testing.T) {
+	// The first server drops the connection instead of answering.
+	srvSM := sm.New(serverSettings)
+	srvSM.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		c.Close()
+	})
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+	reqc := make(chan *diam.Message, 1)
+	srv2SM := sm.New(serverSettings2)
+	srv2SM.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		reqc <- m
+		answerCCR(serverSettings2)(c, m)
+	})
+	srv2 := diamtest.NewServer(srv2SM, dict.Default)
+	defer srv2.Close()
+
+	cliSM := sm.New(clientSettings)
+	cli := newTestClient(cliSM, diam.CHARGING_CONTROL_APP_ID)
+	for _, addr := range []string{srv.Addr, srv2.Addr} {
+		c, err := cli.Dial(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+	m := newTestCCR("", "")
+	endToEnd := m.Header.EndToEndID
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	hosts := []datatype.DiameterIdentity{serverSettings.OriginHost, serverSettings2.OriginHost}
+	a, err := cliSM.SendRequestFailover(ctx, m, hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testResultCode(a, diam.Success) {
+		t.Fatalf("Unexpected answer %s", a)
+	}
+	req := <-reqc
+	if req.Header.CommandFlags&diam.RetransmittedFlag == 0 {
+		t.Fatal("Retransmitted request without 'T' flag")
+	}
+	if req.Header.EndToEndID != endToEnd {
+		t.Fatalf("Unexpected End-to-End Identifier. Want %#x, have %#x",
+			endToEnd, req.Header.EndToEndID)
+	}
+
+	// No peers left.
+	_, err = cliSM.SendRequestFailover(ctx, newTestCCR("", ""), hosts[:1])
+	if err != sm.ErrUnableToDeliver {
+		t.Fatalf("Unexpected error. Want %v, have %v", sm.ErrUnableToDeliver, err)
+	}
+}
+
+func TestStateMachine_DuplicateDetection(t *testing.T) {
+	settings := *serverSettings
+	settings.DuplicateDetectionTime = time.Minute
+	var calls int32
+	srvSM := sm.New(&settings)
+	srvSM.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		atomic.AddInt32(&calls, 1)
+		answerCCR(serverSettings)(c, m)
+	})
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+	cli := newTestClient(sm.New(clientSettings), diam.CHARGING_CONTROL_APP_ID)
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	send := func(m *diam.Message) *diam.Message {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		a, err := diam.SendRequest(ctx, c, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	m := newTestCCR("", "")
+	send(m)
+	m.Header.CommandFlags |= diam.RetransmittedFlag
+	a := send(m)
+	if !testResultCode(a, diam.Success) {
+		t.Fatalf("Unexpected answer %s", a)
+	}
+	if a.Header.HopByHopID != m.Header.HopByHopID {
+		t.Fatalf("Unexpected Hop-by-Hop Identifier. Want %#x, have %#x",
+			m.Header.HopByHopID, a.Header.HopByHopID)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("Unexpected number of handler calls. Want 1, have %d", n)
+	}
+	send(newTestCCR("", ""))
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("Unexpected number of handler calls. Want 2, have %d", n)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/basetype"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/loadtype"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// MaxLoadValue is the Load-Value of a node without capacity left. Load
+// values range from 0 to MaxLoadValue. See RFC 8583 section 7.1.3.
+const MaxLoadValue = 65535
+
+// LoadSource provides the load of a node, see LoadReporter.
+type LoadSource interface {
+	// Load returns the current load, 0 to MaxLoadValue.
+	Load() uint64
+}
+
+// The LoadSourceFunc type is an adapter to allow the use of ordinary
+// functions as load sources.
+type LoadSourceFunc func() uint64
+
+// Load calls f().
+func (f LoadSourceFunc) Load() uint64 {
+	return f()
+}
+
+// LoadReporter adds the Load AVPs of RFC 8583 to answers, for the
+// clients and agents to select the least loaded servers. Both loads
+// are optional: the HOST load is the load of the node as the server of
+// the requests, and the PEER load its load as the peer that forwards
+// them, such as an agent.
+type LoadReporter struct {
+	SourceID datatype.DiameterIdentity // Identity of the node, usually its Origin-Host
+	Host     LoadSource                // Load-Type HOST (optional)
+	Peer     LoadSource                // Load-Type PEER (optional)
+}
+
+// NewLoadReporter creates and initializes a new LoadReporter of the node
+// identified by sourceID, which reports the given HOST load.
+func NewLoadReporter(sourceID datatype.DiameterIdentity, host LoadSource) *LoadReporter {
+	return &LoadReporter{
+		SourceID: sourceID,
+		Host:     host,
+	}
+}
+
+// loadAVPs returns the Load AVPs of the answer to req.
+func (r *LoadReporter) loadAVPs(req *diam.Message) []*diam.AVP {
+	var avps []*diam.AVP
+	for _, l := range []struct {
+		typ datatype.Enumerated
+		src LoadSource
+	}{
+		{loadtype.HOST, r.Host},
+		{loadtype.PEER, r.Peer},
+	} {
+		if l.src == nil {
+			continue
+		}
+		value := l.src.Load()
+		if value > MaxLoadValue {
+			value = MaxLoadValue
+		}
+		typ, v, id := l.typ, datatype.Unsigned64(value), r.SourceID
+		load := &basetype.Load{LoadType: &typ, LoadValue: &v, SourceID: &id}
+		avps = append(avps, diam.NewAVP(avp.Load, 0, 0, load.Serialize()))
+	}
+	return avps
+}
+
+// Report adds the Load AVPs to the answer ans of the request req,
+// unless ans already has them.
+func (r *LoadReporter) Report(req, ans *diam.Message) {
+	if _, ok := groupedAVP(ans, avp.Load); ok {
+		return
+	}
+	for _, a := range r.loadAVPs(req) {
+		ans.AddAVP(a)
+	}
+}
+
+// Handler returns a handler that calls h, and adds the Load AVPs to the
+// answer h writes to the request before returning, as Report. It uses
+// the diam.AnswerHooker interface of the connection, see
+// OverloadReporter.Handler.
+func (r *LoadReporter) Handler(h diam.Handler) diam.Handler {
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		if ah, ok := c.(diam.AnswerHooker); ok {
+			defer ah.HookAnswer(m, func(a *diam.Message) { r.Report(m, a) })()
+		}
+		h.ServeDIAM(c, m)
+	})
+}
+
+type loadKey struct {
+	typ    datatype.Enumerated
+	source datatype.DiameterIdentity
+}
+
+// LoadTable keeps the latest load reported by each node in the Load
+// AVPs of answers, keyed by Load-Type and SourceID. It is used by
+// RoutingTable.SelectPeerByLoad, and is safe for concurrent use.
+//
+// Clients with a LoadTable record the loads of the answers of the
+// requests sent with Client.SendRequest.
+type LoadTable struct {
+	mu    sync.RWMutex // guards loads
+	loads map[loadKey]uint64
+}
+
+// NewLoadTable creates and initializes a new LoadTable.
+func NewLoadTable() *LoadTable {
+	return &LoadTable{
+		loads: make(map[loadKey]uint64),
+	}
+}
+
+// Update records the loads of the Load AVPs of the answer m, and returns
+// how many were recorded. Load AVPs without Load-Type, Load-Value or
+// SourceID are ignored.
+func (lt *LoadTable) Update(m *diam.Message) int {
+	var n int
+	for _, a := range m.AVP {
+		if a.Code != avp.Load || a.VendorID != 0 {
+			continue
+		}
+		g, ok := a.Data.(*diam.GroupedAVP)
+		if !ok {
+			continue
+		}
+		var (
+			k                 loadKey
+			value             uint64
+			hasType, hasValue bool
+		)
+		for _, ga := range g.AVP {
+			switch v := ga.Data.(type) {
+			case datatype.Enumerated:
+				if ga.Code == avp.LoadType {
+					k.typ, hasType = v, true
+				}
+			case datatype.Unsigned64:
+				if ga.Code == avp.LoadValue {
+					value, hasValue = uint64(v), true
+				}
+			case datatype.DiameterIdentity:
+				if ga.Code == avp.SourceID {
+					k.source = v
+				}
+			}
+		}
+		if !hasType || !hasValue || k.source == "" {
+			continue
+		}
+		if value > MaxLoadValue {
+			value = MaxLoadValue
+		}
+		lt.mu.Lock()
+		lt.loads[k] = value
+		lt.mu.Unlock()
+		n++
+	}
+	return n
+}
+
+// Load returns the latest load of the node identified by host: its
+// PEER load, or its HOST load when it did not report a PEER load.
+func (lt *LoadTable) Load(host datatype.DiameterIdentity) (uint64, bool) {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+	if v, ok := lt.loads[loadKey{loadtype.PEER, host}]; ok {
+		return v, true
+	}
+	v, ok := lt.loads[loadKey{loadtype.HOST, host}]
+	return v, ok
+}
+
+// Delete removes the loads of the node identified by host.
+func (lt *LoadTable) Delete(host datatype.DiameterIdentity) {
+	lt.mu.Lock()
+	delete(lt.loads, loadKey{loadtype.HOST, host})
+	delete(lt.loads, loadKey{loadtype.PEER, host})
+	lt.mu.Unlock()
+}
+
+// choose returns one of peers at random, weighted by the capacity they
+// have left according to their loads. Peers without load are weighted
+// as idle, and peers without capacity left are only chosen when all
+// are in that case.
+func (lt *LoadTable) choose(peers []*PeerEntry) *PeerEntry {
+	weights := make([]int64, len(peers))
+	var total int64
+	for i, e := range peers {
+		load, _ := lt.Load(e.Host)
+		weights[i] = MaxLoadValue - int64(load)
+		total += weights[i]
+	}
+	if total == 0 {
+		return peers[rand.Intn(len(peers))]
+	}
+	n := rand.Int63n(total)
+	for i, w := range weights {
+		if n < w {
+			return peers[i]
+		}
+		n -= w
+	}
+	return peers[len(peers)-1]
+}
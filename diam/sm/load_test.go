@@ -0,0 +1,76 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/basetype"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm"
+)
+
+func TestLoadTable(t *testing.T) {
+	lt := sm.NewLoadTable()
+	a := diam.NewRequest(diam.CreditControl, diam.CHARGING_CONTROL_APP_ID, dict.Default).Answer(diam.Success)
+	r := sm.NewLoadReporter("ocs1", sm.LoadSourceFunc(func() uint64 { return 100000 }))
+	r.Report(nil, a)
+	// Incomplete Load AVPs are ignored.
+	value := datatype.Unsigned64(1)
+	a.NewAVP(avp.Load, 0, 0, (&basetype.Load{LoadValue: &value}).Serialize())
+	if n := lt.Update(a); n != 1 {
+		t.Fatalf("Unexpected number of loads. Want 1, have %d", n)
+	}
+	if load, ok := lt.Load("ocs1"); !ok || load != sm.MaxLoadValue {
+		t.Fatalf("Unexpected load. Want %d, have %d", sm.MaxLoadValue, load)
+	}
+	lt.Delete("ocs1")
+	if load, ok := lt.Load("ocs1"); ok {
+		t.Fatalf("Unexpected load %d", load)
+	}
+}
+
+func TestLoadReporter(t *testing.T) {
+	reporter := sm.NewLoadReporter(serverSettings.OriginHost, sm.LoadSourceFunc(func() uint64 { return 100 }))
+	reporter.Peer = sm.LoadSourceFunc(func() uint64 { return 200 })
+	srvSM := sm.New(serverSettings)
+	srvSM.Handle("CCR", reporter.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		// The handler can send requests on the connection.
+		if _, ok := c.(diam.RequestSender); !ok {
+			t.Errorf("Connection %T does not support SendRequest", c)
+		}
+		answerCCR(serverSettings)(c, m)
+	})))
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+
+	cliSM := sm.New(clientSettings)
+	cli := newTestClient(cliSM, diam.CHARGING_CONTROL_APP_ID)
+	cli.Loads = sm.NewLoadTable()
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	a, err := cli.SendRequest(ctx, c, newTestCCR("", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loads, _ := a.FindAVPs(avp.Load, 0); len(loads) != 2 {
+		t.Fatalf("Unexpected number of Load AVPs. Want 2, have %d", len(loads))
+	}
+	// The PEER load of directly connected peers is preferred.
+	if load, ok := cli.Loads.Load(serverSettings.OriginHost); !ok || load != 200 {
+		t.Fatalf("Unexpected load. Want 200, have %d", load)
+	}
+}
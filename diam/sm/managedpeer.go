@@ -0,0 +1,307 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm/smpeer"
+)
+
+// ErrPeerClosed is returned by ManagedPeer methods after the
+// managed peer is closed, or has stopped reconnecting.
+var ErrPeerClosed = errors.New("managed peer is closed")
+
+// ConnState is the connection state of a ManagedPeer.
+type ConnState int
+
+// Connection states.
+const (
+	ConnConnecting ConnState = iota // Dialing and performing the handshake
+	ConnUp                          // Connected, handshake completed
+	ConnDown                        // Disconnected, waiting to reconnect
+	ConnClosed                      // Closed, no more reconnection attempts
+)
+
+var connStateNames = map[ConnState]string{
+	ConnConnecting: "Connecting",
+	ConnUp:         "Up",
+	ConnDown:       "Down",
+	ConnClosed:     "Closed",
+}
+
+func (s ConnState) String() string {
+	if name, ok := connStateNames[s]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// ConnStateEvent describes a connection state change of a ManagedPeer.
+type ConnStateEvent struct {
+	State ConnState
+	Conn  diam.Conn // The new connection when Up, the lost one when Down
+	Err   error     // The dial error, if any, when Down or Closed
+}
+
+// connStateNotifyBuffer is the capacity of the ConnStateNotify channel.
+// Events are dropped when the channel is full.
+const connStateNotifyBuffer = 16
+
+// A ManagedPeer is a connection to a diameter peer that is automatically
+// re-established by its Client when it goes away.
+//
+// Every connection attempt performs the CER/CEA handshake and starts
+// the watchdog, if enabled in the Client. Failed attempts are retried
+// with exponential backoff and jitter, between the ReconnectInterval and
+// MaxReconnectInterval of the Client. Reconnection stops when the peer
+// disconnected with DO_NOT_WANT_TO_TALK_TO_YOU.
+//
+// It is safe for concurrent use.
+type ManagedPeer struct {
+	cli       *Client
+	dial      dialFunc
+	notifyc   chan ConnStateEvent
+	done      chan struct{} // closed by Close
+	closeOnce sync.Once
+
+	mu    sync.Mutex // guards the following
+	conn  diam.Conn
+	state ConnState
+	err   error         // why the peer is ConnClosed
+	upc   chan struct{} // closed when leaving ConnConnecting or ConnDown
+}
+
+// DialPeer returns a ManagedPeer connected to the network address addr,
+// as in DialNetwork. The first connection is established in background;
+// see ManagedPeer.WaitConn.
+func (cli *Client) DialPeer(network, addr string) (*ManagedPeer, error) {
+	return cli.newManagedPeer(func() (diam.Conn, error) {
+		return cli.DialNetwork(network, addr)
+	})
+}
+
+// DialPeerTLS is like DialPeer, but using TLS.
+func (cli *Client) DialPeerTLS(network, addr, certFile, keyFile string) (*ManagedPeer, error) {
+	return cli.newManagedPeer(func() (diam.Conn, error) {
+		return cli.DialTLSExt(network, addr, certFile, keyFile, 0, nil)
+	})
+}
+
+func (cli *Client) newManagedPeer(f dialFunc) (*ManagedPeer, error) {
+	if err := cli.validate(); err != nil {
+		return nil, err
+	}
+	p := &ManagedPeer{
+		cli:     cli,
+		dial:    f,
+		notifyc: make(chan ConnStateEvent, connStateNotifyBuffer),
+		done:    make(chan struct{}),
+		state:   ConnConnecting,
+		upc:     make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// run establishes connections until the peer is closed.
+func (p *ManagedPeer) run() {
+	backoff := p.cli.ReconnectInterval
+	p.notify(ConnStateEvent{State: ConnConnecting})
+	for {
+		c, err := p.dial()
+		if err != nil {
+			if err == ErrReconnectSuppressed {
+				p.close(err)
+				return
+			}
+			p.setState(ConnDown, nil, err)
+			select {
+			case <-time.After(jitter(backoff)):
+			case <-p.done:
+				return
+			}
+			backoff *= 2
+			if backoff > p.cli.MaxReconnectInterval {
+				backoff = p.cli.MaxReconnectInterval
+			}
+			p.setState(ConnConnecting, nil, nil)
+			continue
+		}
+		backoff = p.cli.ReconnectInterval
+		if !p.setState(ConnUp, c, nil) {
+			c.Close()
+			return
+		}
+		var disconnect <-chan struct{}
+		if cn, ok := c.(diam.CloseNotifier); ok {
+			disconnect = cn.CloseNotify()
+		}
+		select {
+		case <-disconnect:
+		case <-p.done:
+			return
+		}
+		if !p.setState(ConnDown, c, nil) {
+			return
+		}
+		p.setState(ConnConnecting, nil, nil)
+	}
+}
+
+// jitter returns a random duration between d/2 and d.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// setState moves the peer to the given state, and returns false
+// if the peer is already closed.
+func (p *ManagedPeer) setState(state ConnState, c diam.Conn, err error) bool {
+	p.mu.Lock()
+	if p.state == ConnClosed {
+		p.mu.Unlock()
+		return false
+	}
+	p.state = state
+	switch state {
+	case ConnUp:
+		p.conn = c
+		close(p.upc)
+	case ConnDown:
+		p.conn = nil
+		if c != nil {
+			p.upc = make(chan struct{})
+		}
+	}
+	p.mu.Unlock()
+	p.notify(ConnStateEvent{State: state, Conn: c, Err: err})
+	return true
+}
+
+// close moves the peer to ConnClosed.
+func (p *ManagedPeer) close(err error) {
+	p.mu.Lock()
+	if p.state == ConnClosed {
+		p.mu.Unlock()
+		return
+	}
+	if p.state != ConnUp {
+		close(p.upc)
+	}
+	c := p.conn
+	p.conn = nil
+	p.state = ConnClosed
+	p.err = err
+	p.mu.Unlock()
+	p.notify(ConnStateEvent{State: ConnClosed, Conn: c, Err: err})
+}
+
+func (p *ManagedPeer) notify(ev ConnStateEvent) {
+	select {
+	case p.notifyc <- ev:
+	default:
+	}
+}
+
+// State returns the current connection state.
+func (p *ManagedPeer) State() ConnState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// Conn returns the current connection, or nil when the peer is
+// not connected.
+func (p *ManagedPeer) Conn() diam.Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.conn
+}
+
+// OriginHost returns the Origin-Host of the peer, or an empty
+// identity when the peer is not connected.
+func (p *ManagedPeer) OriginHost() datatype.DiameterIdentity {
+	c := p.Conn()
+	if c == nil {
+		return ""
+	}
+	if meta, ok := smpeer.FromContext(c.Context()); ok {
+		return meta.OriginHost
+	}
+	return ""
+}
+
+// WaitConn returns the current connection, waiting for it to be
+// established if needed. It fails with ctx.Err() when ctx is done
+// first, ErrPeerClosed when the peer is closed, or ErrReconnectSuppressed
+// when the peer does not want to talk to us.
+func (p *ManagedPeer) WaitConn(ctx context.Context) (diam.Conn, error) {
+	for {
+		p.mu.Lock()
+		c, state, upc, err := p.conn, p.state, p.upc, p.err
+		p.mu.Unlock()
+		switch {
+		case c != nil:
+			return c, nil
+		case state == ConnClosed:
+			if err == nil {
+				err = ErrPeerClosed
+			}
+			return nil, err
+		}
+		select {
+		case <-upc:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// SendRequest waits for the connection and sends the request m on it,
+// as in Client.SendRequest.
+func (p *ManagedPeer) SendRequest(ctx context.Context, m *diam.Message) (*diam.Message, error) {
+	c, err := p.WaitConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.cli.SendRequest(ctx, c, m)
+}
+
+// ConnStateNotify returns a channel that receives an event for each
+// connection state change.
+func (p *ManagedPeer) ConnStateNotify() <-chan ConnStateEvent {
+	return p.notifyc
+}
+
+// Close stops reconnecting and closes the current connection, if any.
+func (p *ManagedPeer) Close() {
+	p.closeOnce.Do(func() { close(p.done) })
+	c := p.Conn()
+	p.close(nil)
+	if c != nil {
+		c.Close()
+	}
+}
+
+// Disconnect stops reconnecting and gracefully closes the current
+// connection, if any, as in StateMachine.Disconnect.
+func (p *ManagedPeer) Disconnect(cause datatype.Enumerated, timeout time.Duration) error {
+	p.closeOnce.Do(func() { close(p.done) })
+	c := p.Conn()
+	p.close(nil)
+	if c == nil {
+		return nil
+	}
+	return p.cli.Handler.Disconnect(c, cause, timeout)
+}
@@ -0,0 +1,131 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/disconnectcause"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm"
+)
+
+// These tests use dictionary, settings and functions from sm_test.go.
+
+func waitConnState(t *testing.T, p *sm.ManagedPeer, want sm.ConnState) sm.ConnStateEvent {
+	t.Helper()
+	for {
+		select {
+		case ev := <-p.ConnStateNotify():
+			if ev.State == want {
+				return ev
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for connection state %s", want)
+		}
+	}
+}
+
+func TestManagedPeer_Reconnect(t *testing.T) {
+	srvSM := sm.New(serverSettings)
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+	cli := newTestClient(sm.New(clientSettings), 1001)
+	cli.ReconnectInterval = 10 * time.Millisecond
+	p, err := cli.DialPeer("tcp", srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := p.WaitConn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := p.OriginHost(); host != serverSettings.OriginHost {
+		t.Fatalf("Unexpected Origin-Host. Want %s, have %s", serverSettings.OriginHost, host)
+	}
+	waitConnState(t, p, sm.ConnUp)
+	// Drop the connection from the server side.
+	ev := waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerROpen)
+	ev.Conn.Close()
+	if down := waitConnState(t, p, sm.ConnDown); down.Conn != c {
+		t.Fatal("Unexpected connection in Down event")
+	}
+	up := waitConnState(t, p, sm.ConnUp)
+	if up.Conn == c {
+		t.Fatal("Connection not re-established")
+	}
+	if cur := p.Conn(); cur != up.Conn {
+		t.Fatal("Unexpected current connection")
+	}
+	p.Close()
+	waitConnState(t, p, sm.ConnClosed)
+	if _, err = p.WaitConn(ctx); err != sm.ErrPeerClosed {
+		t.Fatalf("Unexpected error. Want %v, have %v", sm.ErrPeerClosed, err)
+	}
+}
+
+func TestManagedPeer_Backoff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	cli := newTestClient(sm.New(clientSettings), 1001)
+	cli.ReconnectInterval = 10 * time.Millisecond
+	cli.MaxReconnectInterval = 20 * time.Millisecond
+	p, err := cli.DialPeer("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if ev := waitConnState(t, p, sm.ConnDown); ev.Err == nil {
+			t.Fatal("Missing dial error")
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = p.WaitConn(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Unexpected error. Want %v, have %v", context.DeadlineExceeded, err)
+	}
+	p.Close()
+	if state := p.State(); state != sm.ConnClosed {
+		t.Fatalf("Unexpected state. Want Closed, have %s", state)
+	}
+}
+
+func TestManagedPeer_DoNotWantToTalkToYou(t *testing.T) {
+	srvSM := sm.New(serverSettings)
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+	cli := newTestClient(sm.New(clientSettings), 1001)
+	cli.ReconnectInterval = 10 * time.Millisecond
+	p, err := cli.DialPeer("tcp", srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	waitConnState(t, p, sm.ConnUp)
+	ev := waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerROpen)
+	err = srvSM.Disconnect(ev.Conn, disconnectcause.DO_NOT_WANT_TO_TALK_TO_YOU, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed := waitConnState(t, p, sm.ConnClosed); closed.Err != sm.ErrReconnectSuppressed {
+		t.Fatalf("Unexpected error. Want %v, have %v", sm.ErrReconnectSuppressed, closed.Err)
+	}
+	_, err = p.SendRequest(context.Background(), diam.NewRequest(diam.DeviceWatchdog, 0, nil))
+	if err != sm.ErrReconnectSuppressed {
+		t.Fatalf("Unexpected error. Want %v, have %v", sm.ErrReconnectSuppressed, err)
+	}
+}
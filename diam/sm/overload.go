@@ -0,0 +1,458 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/basetype"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/ocreporttype"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+var (
+	// ErrTooBusy is returned by Client.SendRequest when the request is
+	// throttled by an overload report and cannot be diverted. It maps
+	// to DIAMETER_TOO_BUSY (3004).
+	ErrTooBusy = errors.New("too busy")
+
+	// ErrNoOverloadReport is returned by ParseOverloadReport when the
+	// message does not carry an OC-OLR of the loss algorithm.
+	ErrNoOverloadReport = errors.New("no overload report")
+)
+
+// OLRDefaultAlgo is the OC-Feature-Vector bit of the loss algorithm,
+// the default overload abatement algorithm. See RFC 7683 section 7.2.
+const OLRDefaultAlgo = datatype.Unsigned64(1)
+
+// Validity of overload reports. See RFC 7683 section 7.4.
+const (
+	DefaultOverloadValidity = 30 * time.Second
+	MaxOverloadValidity     = 86400 * time.Second
+)
+
+// OverloadReport is an overload report (OLR) of the loss algorithm,
+// carried in the OC-OLR AVP of answers. See RFC 7683 section 5.5.
+type OverloadReport struct {
+	SequenceNumber uint64
+	Type           datatype.Enumerated // OC-Report-Type, HOST_REPORT or REALM_REPORT
+	Reduction      uint32              // OC-Reduction-Percentage, 0 to 100
+	Validity       time.Duration       // OC-Validity-Duration, 0 ends the overload
+	Host           datatype.DiameterIdentity
+	Realm          datatype.DiameterIdentity
+}
+
+// ParseOverloadReport parses the overload report of the answer m, sent
+// by the reporting node identified by its Origin-Host and Origin-Realm.
+// Reports of answers whose OC-Supported-Features does not select the
+// loss algorithm are ignored, and ErrNoOverloadReport is returned.
+func ParseOverloadReport(m *diam.Message) (*OverloadReport, error) {
+	if m.Header.CommandFlags&diam.RequestFlag != 0 || !supportsOLRDefaultAlgo(m) {
+		return nil, ErrNoOverloadReport
+	}
+	olr, ok := groupedAVP(m, avp.OCOLR)
+	if !ok {
+		return nil, ErrNoOverloadReport
+	}
+	r := &OverloadReport{Validity: DefaultOverloadValidity}
+	var hasSeq, hasType bool
+	for _, a := range olr.AVP {
+		if a.VendorID != 0 {
+			continue
+		}
+		switch v := a.Data.(type) {
+		case datatype.Unsigned64:
+			if a.Code == avp.OCSequenceNumber {
+				r.SequenceNumber, hasSeq = uint64(v), true
+			}
+		case datatype.Enumerated:
+			if a.Code == avp.OCReportType {
+				r.Type, hasType = v, true
+			}
+		case datatype.Unsigned32:
+			switch a.Code {
+			case avp.OCReductionPercentage:
+				r.Reduction = uint32(v)
+			case avp.OCValidityDuration:
+				r.Validity = time.Duration(v) * time.Second
+			}
+		}
+	}
+	if !hasSeq || !hasType {
+		return nil, ErrNoOverloadReport
+	}
+	if r.Reduction > 100 {
+		r.Reduction = 100
+	}
+	if r.Validity > MaxOverloadValidity {
+		r.Validity = MaxOverloadValidity
+	}
+	r.Host, _ = identityAVP(m, avp.OriginHost)
+	r.Realm, _ = identityAVP(m, avp.OriginRealm)
+	return r, nil
+}
+
+// supportsOLRDefaultAlgo reports whether the OC-Supported-Features of m
+// has the loss algorithm bit set.
+func supportsOLRDefaultAlgo(m *diam.Message) bool {
+	features, ok := groupedAVP(m, avp.OCSupportedFeatures)
+	if !ok {
+		return false
+	}
+	for _, a := range features.AVP {
+		if a.Code == avp.OCFeatureVector && a.VendorID == 0 {
+			v, ok := a.Data.(datatype.Unsigned64)
+			return ok && v&OLRDefaultAlgo != 0
+		}
+	}
+	return false
+}
+
+// groupedAVP returns the value of the Grouped AVP code of m.
+func groupedAVP(m *diam.Message, code uint32) (*diam.GroupedAVP, bool) {
+	for _, a := range m.AVP {
+		if a.Code == code && a.VendorID == 0 {
+			g, ok := a.Data.(*diam.GroupedAVP)
+			return g, ok
+		}
+	}
+	return nil, false
+}
+
+type overloadKey struct {
+	typ datatype.Enumerated
+	id  string // Origin-Host or lower case Origin-Realm
+}
+
+type overloadEntry struct {
+	seq       uint64
+	reduction uint32
+	expires   time.Time
+}
+
+// OverloadControl is the reacting node of Diameter Overload Indication
+// Conveyance (DOIC), as described in RFC 7683. It keeps the overload
+// reports received from reporting nodes, per host and realm, until
+// their validity expires, and throttles the requests sent to them with
+// the loss algorithm: the reduction percentage of the requests is
+// dropped, or diverted to other peers. It is safe for concurrent use.
+//
+// Clients with an OverloadControl advertise DOIC in the requests sent
+// with Client.SendRequest, and apply the reports of their answers.
+type OverloadControl struct {
+	// Divert sends throttled requests without Destination-Host to
+	// another available peer of their Destination-Realm that supports
+	// their application and is not throttled, instead of dropping them.
+	// Requests throttled by a realm report are always dropped.
+	Divert bool
+
+	mu      sync.Mutex // guards entries
+	entries map[overloadKey]*overloadEntry
+}
+
+// NewOverloadControl creates and initializes a new OverloadControl.
+func NewOverloadControl() *OverloadControl {
+	return &OverloadControl{
+		entries: make(map[overloadKey]*overloadEntry),
+	}
+}
+
+func newOverloadKey(typ datatype.Enumerated, id datatype.DiameterIdentity) overloadKey {
+	if typ == ocreporttype.REALM_REPORT {
+		return overloadKey{typ, strings.ToLower(string(id))}
+	}
+	return overloadKey{typ, string(id)}
+}
+
+// Advertise adds an OC-Supported-Features AVP with the loss algorithm
+// to the request m, unless it already has one.
+func (oc *OverloadControl) Advertise(m *diam.Message) {
+	if _, ok := groupedAVP(m, avp.OCSupportedFeatures); ok {
+		return
+	}
+	vector := OLRDefaultAlgo
+	features := &basetype.OCSupportedFeatures{OcFeatureVector: &vector}
+	m.NewAVP(avp.OCSupportedFeatures, 0, 0, features.Serialize())
+}
+
+// Update applies the overload report of the answer m, if any, and
+// reports whether it was applied. Reports are only applied when their
+// sequence number is greater than the one of the report they replace,
+// and a validity of 0 ends the overload.
+func (oc *OverloadControl) Update(m *diam.Message) bool {
+	r, err := ParseOverloadReport(m)
+	if err != nil {
+		return false
+	}
+	return oc.Add(r)
+}
+
+// Add applies the overload report r, as Update.
+func (oc *OverloadControl) Add(r *OverloadReport) bool {
+	var id datatype.DiameterIdentity
+	switch r.Type {
+	case ocreporttype.HOST_REPORT:
+		id = r.Host
+	case ocreporttype.REALM_REPORT:
+		id = r.Realm
+	}
+	if id == "" {
+		return false
+	}
+	k := newOverloadKey(r.Type, id)
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	if e, ok := oc.entries[k]; ok && r.SequenceNumber <= e.seq {
+		return false
+	}
+	if r.Validity <= 0 {
+		// Keep the sequence number, to ignore older reports.
+		oc.entries[k] = &overloadEntry{seq: r.SequenceNumber}
+		return true
+	}
+	oc.entries[k] = &overloadEntry{
+		seq:       r.SequenceNumber,
+		reduction: r.Reduction,
+		expires:   time.Now().Add(r.Validity),
+	}
+	return true
+}
+
+// reduction returns the reduction percentage of the report k.
+func (oc *OverloadControl) reduction(k overloadKey, now time.Time) uint32 {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	e, ok := oc.entries[k]
+	if !ok || !now.Before(e.expires) {
+		return 0
+	}
+	return e.reduction
+}
+
+// Reduction returns the reduction percentage of the host report of the
+// reporting node identified by host, or 0 if there is none.
+func (oc *OverloadControl) Reduction(host datatype.DiameterIdentity) uint32 {
+	return oc.reduction(newOverloadKey(ocreporttype.HOST_REPORT, host), time.Now())
+}
+
+// RealmReduction returns the reduction percentage of the realm report
+// of realm, or 0 if there is none.
+func (oc *OverloadControl) RealmReduction(realm datatype.DiameterIdentity) uint32 {
+	return oc.reduction(newOverloadKey(ocreporttype.REALM_REPORT, realm), time.Now())
+}
+
+// Throttle reports whether the request m, about to be sent to the peer
+// identified by host, is throttled by the loss algorithm. The host
+// report of host, or of the Destination-Host of m, and the realm report
+// of the Destination-Realm of requests without Destination-Host apply.
+func (oc *OverloadControl) Throttle(m *diam.Message, host datatype.DiameterIdentity) bool {
+	return oc.throttleRealm(m) || oc.throttleHost(m, host)
+}
+
+func (oc *OverloadControl) throttleHost(m *diam.Message, host datatype.DiameterIdentity) bool {
+	if dest, ok := identityAVP(m, avp.DestinationHost); ok {
+		host = dest
+	}
+	return host != "" && drop(oc.Reduction(host))
+}
+
+func (oc *OverloadControl) throttleRealm(m *diam.Message) bool {
+	if _, ok := identityAVP(m, avp.DestinationHost); ok {
+		return false
+	}
+	realm, ok := identityAVP(m, avp.DestinationRealm)
+	return ok && drop(oc.RealmReduction(realm))
+}
+
+// drop reports whether a request is dropped with the given reduction
+// percentage.
+func drop(reduction uint32) bool {
+	return reduction > 0 && uint32(rand.Intn(100)) < reduction
+}
+
+// RemoveExpired removes all reports expired at t and returns how many
+// were removed.
+func (oc *OverloadControl) RemoveExpired(t time.Time) int {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	var n int
+	for k, e := range oc.entries {
+		if !t.Before(e.expires) {
+			delete(oc.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+// overloadConn returns the connection to send the request m to instead
+// of c, which may be c itself, or ErrTooBusy when m is dropped.
+func (cli *Client) overloadConn(c diam.Conn, m *diam.Message) (diam.Conn, error) {
+	oc := cli.Overload
+	oc.Advertise(m)
+	if oc.throttleRealm(m) {
+		return nil, ErrTooBusy
+	}
+	if !oc.throttleHost(m, connOriginHost(c)) {
+		return c, nil
+	}
+	if _, ok := identityAVP(m, avp.DestinationHost); ok || !oc.Divert {
+		return nil, ErrTooBusy
+	}
+	realm, ok := identityAVP(m, avp.DestinationRealm)
+	if !ok {
+		return nil, ErrTooBusy
+	}
+	for _, e := range cli.Handler.PeerTable().Peers() {
+		if e.Conn == c || !e.Available() || !e.Supports(m.Header.ApplicationID) ||
+			!strings.EqualFold(string(e.Realm), string(realm)) {
+			continue
+		}
+		if !oc.throttleHost(m, e.Host) {
+			return e.Conn, nil
+		}
+	}
+	return nil, ErrTooBusy
+}
+
+// LoadEstimator estimates the load of a reporting node, see
+// OverloadReporter.
+type LoadEstimator interface {
+	// Reduction returns the percentage of the traffic, 0 to 100, that
+	// reacting nodes should stop sending to the node.
+	Reduction() uint32
+}
+
+// The LoadEstimatorFunc type is an adapter to allow the use of
+// ordinary functions as load estimators.
+type LoadEstimatorFunc func() uint32
+
+// Reduction calls f().
+func (f LoadEstimatorFunc) Reduction() uint32 {
+	return f()
+}
+
+// OverloadReporter is the reporting node of Diameter Overload Indication
+// Conveyance (DOIC), as described in RFC 7683. It answers the requests
+// of reacting nodes that advertise the loss algorithm with the selected
+// OC-Supported-Features, and an overload report while its Estimator
+// asks for a reduction of the traffic. It is safe for concurrent use.
+//
+// The sequence number of the report changes with the reduction, and
+// before the validity of the report expires, so that reacting nodes
+// keep applying it. When the reduction drops to 0, a report with a
+// validity of 0 ends the overload.
+type OverloadReporter struct {
+	Estimator  LoadEstimator       // Load of the node
+	ReportType datatype.Enumerated // OC-Report-Type, HOST_REPORT by default
+	Validity   time.Duration       // OC-Validity-Duration, DefaultOverloadValidity if unset
+
+	mu        sync.Mutex // guards the following
+	seq       uint64
+	reduction uint32
+	issued    time.Time // when seq was issued
+}
+
+// NewOverloadReporter creates and initializes a new OverloadReporter
+// of host reports.
+func NewOverloadReporter(estimator LoadEstimator) *OverloadReporter {
+	return &OverloadReporter{
+		Estimator:  estimator,
+		ReportType: ocreporttype.HOST_REPORT,
+	}
+}
+
+func (r *OverloadReporter) validity() time.Duration {
+	switch {
+	case r.Validity <= 0:
+		return DefaultOverloadValidity
+	case r.Validity > MaxOverloadValidity:
+		return MaxOverloadValidity
+	}
+	return r.Validity
+}
+
+// report returns the current overload report, if any.
+func (r *OverloadReporter) report() (*basetype.OCOLR, bool) {
+	reduction := r.Estimator.Reduction()
+	if reduction > 100 {
+		reduction = 100
+	}
+	validity := r.validity()
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reduction == r.reduction && reduction == 0 {
+		// Keep ending the last overload until its report expires.
+		if r.issued.IsZero() || !now.Before(r.issued.Add(validity)) {
+			return nil, false
+		}
+	} else if reduction != r.reduction || now.Sub(r.issued) >= validity/2 {
+		// Sequence numbers start at the current time, to keep
+		// increasing across restarts.
+		r.seq++
+		if seq := uint64(now.Unix()); seq > r.seq {
+			r.seq = seq
+		}
+		r.reduction = reduction
+		r.issued = now
+	}
+	seconds := datatype.Unsigned32(validity / time.Second)
+	if reduction == 0 {
+		seconds = 0
+	}
+	percentage := datatype.Unsigned32(reduction)
+	return &basetype.OCOLR{
+		OcSequenceNumber:      datatype.Unsigned64(r.seq),
+		OcReportType:          r.ReportType,
+		OcReductionPercentage: &percentage,
+		OcValidityDuration:    &seconds,
+	}, true
+}
+
+// overloadAVPs returns the DOIC AVPs of the answer to req, if any.
+func (r *OverloadReporter) overloadAVPs(req *diam.Message) []*diam.AVP {
+	if !supportsOLRDefaultAlgo(req) {
+		return nil
+	}
+	vector := OLRDefaultAlgo
+	features := &basetype.OCSupportedFeatures{OcFeatureVector: &vector}
+	avps := []*diam.AVP{diam.NewAVP(avp.OCSupportedFeatures, 0, 0, features.Serialize())}
+	if olr, ok := r.report(); ok {
+		avps = append(avps, diam.NewAVP(avp.OCOLR, 0, 0, olr.Serialize()))
+	}
+	return avps
+}
+
+// Report adds the DOIC AVPs to the answer ans of the request req, when
+// req advertises the loss algorithm and ans does not have them yet.
+func (r *OverloadReporter) Report(req, ans *diam.Message) {
+	if _, ok := groupedAVP(ans, avp.OCSupportedFeatures); ok {
+		return
+	}
+	for _, a := range r.overloadAVPs(req) {
+		ans.AddAVP(a)
+	}
+}
+
+// Handler returns a handler that calls h, and adds the DOIC AVPs to the
+// answer h writes to the request before returning, as Report. It uses
+// the diam.AnswerHooker interface of the connection, such as those of
+// diam.Server and diam.Dial. Handlers that answer after returning must
+// call Report themselves.
+func (r *OverloadReporter) Handler(h diam.Handler) diam.Handler {
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		if ah, ok := c.(diam.AnswerHooker); ok {
+			defer ah.HookAnswer(m, func(a *diam.Message) { r.Report(m, a) })()
+		}
+		h.ServeDIAM(c, m)
+	})
+}
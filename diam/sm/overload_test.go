@@ -0,0 +1,196 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/basetype"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/ocreporttype"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm"
+)
+
+// newOverloadTestAnswer returns an answer of host in realm with the
+// given overload report.
+func newOverloadTestAnswer(host, realm string, olr *basetype.OCOLR) *diam.Message {
+	m := newTestCCA(newTestCCR("", ""), &sm.Settings{
+		OriginHost:  datatype.DiameterIdentity(host),
+		OriginRealm: datatype.DiameterIdentity(realm),
+	})
+	vector := sm.OLRDefaultAlgo
+	features := &basetype.OCSupportedFeatures{OcFeatureVector: &vector}
+	m.NewAVP(avp.OCSupportedFeatures, 0, 0, features.Serialize())
+	m.NewAVP(avp.OCOLR, 0, 0, olr.Serialize())
+	return m
+}
+
+func newOverloadTestOLR(seq uint64, typ datatype.Enumerated, reduction, validity uint32) *basetype.OCOLR {
+	percentage := datatype.Unsigned32(reduction)
+	duration := datatype.Unsigned32(validity)
+	return &basetype.OCOLR{
+		OcSequenceNumber:      datatype.Unsigned64(seq),
+		OcReportType:          typ,
+		OcReductionPercentage: &percentage,
+		OcValidityDuration:    &duration,
+	}
+}
+
+func TestParseOverloadReport(t *testing.T) {
+	a := newOverloadTestAnswer("ocs1", "ocs.example", newOverloadTestOLR(7, ocreporttype.REALM_REPORT, 40, 100000))
+	r, err := sm.ParseOverloadReport(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SequenceNumber != 7 || r.Type != ocreporttype.REALM_REPORT || r.Reduction != 40 ||
+		r.Validity != sm.MaxOverloadValidity || r.Host != "ocs1" || r.Realm != "ocs.example" {
+		t.Fatalf("Unexpected overload report %+v", r)
+	}
+	// Reports without the loss algorithm are ignored.
+	a = diam.NewRequest(diam.CreditControl, diam.CHARGING_CONTROL_APP_ID, dict.Default).Answer(diam.Success)
+	a.NewAVP(avp.OCOLR, 0, 0, newOverloadTestOLR(7, ocreporttype.HOST_REPORT, 40, 10).Serialize())
+	if _, err := sm.ParseOverloadReport(a); err != sm.ErrNoOverloadReport {
+		t.Fatalf("Unexpected error. Want %v, have %v", sm.ErrNoOverloadReport, err)
+	}
+}
+
+func TestOverloadControl(t *testing.T) {
+	oc := sm.NewOverloadControl()
+	if !oc.Update(newOverloadTestAnswer("ocs1", "ocs.example", newOverloadTestOLR(2, ocreporttype.HOST_REPORT, 100, 60))) {
+		t.Fatal("Host report not applied")
+	}
+	// Older reports are ignored.
+	if oc.Update(newOverloadTestAnswer("ocs1", "ocs.example", newOverloadTestOLR(1, ocreporttype.HOST_REPORT, 0, 60))) {
+		t.Fatal("Older host report applied")
+	}
+	if !oc.Update(newOverloadTestAnswer("ocs2", "OCS.example", newOverloadTestOLR(1, ocreporttype.REALM_REPORT, 100, 60))) {
+		t.Fatal("Realm report not applied")
+	}
+	for i, tc := range []struct {
+		m    *diam.Message
+		peer datatype.DiameterIdentity
+		want bool
+	}{
+		{newTestCCR("hss.example", ""), "ocs1", true},
+		{newTestCCR("hss.example", "ocs1"), "dra", true},
+		{newTestCCR("hss.example", ""), "dra", false},
+		{newTestCCR("ocs.example", ""), "dra", true},
+		{newTestCCR("ocs.example", "ocs2"), "dra", false},
+	} {
+		if have := oc.Throttle(tc.m, tc.peer); have != tc.want {
+			t.Errorf("%d: unexpected throttling. Want %v, have %v", i, tc.want, have)
+		}
+	}
+	// A validity of 0 ends the overload.
+	if !oc.Update(newOverloadTestAnswer("ocs1", "ocs.example", newOverloadTestOLR(3, ocreporttype.HOST_REPORT, 100, 0))) {
+		t.Fatal("Host report not applied")
+	}
+	if r := oc.Reduction("ocs1"); r != 0 {
+		t.Fatalf("Unexpected reduction. Want 0, have %d", r)
+	}
+	if r := oc.RealmReduction("ocs.EXAMPLE"); r != 100 {
+		t.Fatalf("Unexpected reduction. Want 100, have %d", r)
+	}
+	if n := oc.RemoveExpired(time.Now().Add(time.Hour)); n != 2 {
+		t.Fatalf("Unexpected number of expired reports. Want 2, have %d", n)
+	}
+}
+
+func TestOverloadReporter(t *testing.T) {
+	var reduction, selfReport uint32
+	reporter := sm.NewOverloadReporter(sm.LoadEstimatorFunc(func() uint32 {
+		return atomic.LoadUint32(&reduction)
+	}))
+	srvSM := sm.New(serverSettings)
+	states := make(chan sm.PeerState, 10)
+	srvSM.Handle("CCR", reporter.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		// The handler has the connection of the state machine.
+		states <- srvSM.PeerState(c)
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, serverSettings.OriginHost)
+		a.NewAVP(avp.OriginRealm, avp.Mbit, 0, serverSettings.OriginRealm)
+		if atomic.LoadUint32(&selfReport) == 1 {
+			reporter.Report(m, a)
+		}
+		a.WriteTo(c)
+	})))
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+	settings := *serverSettings
+	settings.OriginHost = "srv3"
+	srv3SM := sm.New(&settings)
+	srv3SM.HandleFunc("CCR", answerCCR(&settings))
+	srv3 := diamtest.NewServer(srv3SM, dict.Default)
+	defer srv3.Close()
+
+	cliSM := sm.New(clientSettings)
+	cli := newTestClient(cliSM, diam.CHARGING_CONTROL_APP_ID)
+	cli.Overload = sm.NewOverloadControl()
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	send := func() (*diam.Message, error) {
+		return cli.SendRequest(ctx, c, newTestCCR("test", ""))
+	}
+
+	// The reporting node is not overloaded.
+	atomic.StoreUint32(&selfReport, 1)
+	a, err := send()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := <-states; !s.Open() {
+		t.Fatalf("Unexpected peer state %s", s)
+	}
+	// The AVPs added by Report are not added again.
+	if avps, _ := a.FindAVPs(avp.OCSupportedFeatures, 0); len(avps) != 1 {
+		t.Fatalf("Unexpected number of OC-Supported-Features. Want 1, have %d", len(avps))
+	}
+	atomic.StoreUint32(&selfReport, 0)
+	if _, err := a.FindAVP(avp.OCOLR, 0); err == nil {
+		t.Fatal("Unexpected OC-OLR in answer")
+	}
+
+	atomic.StoreUint32(&reduction, 100)
+	a, err = send()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := sm.ParseOverloadReport(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Type != ocreporttype.HOST_REPORT || r.Reduction != 100 || r.Validity != sm.DefaultOverloadValidity {
+		t.Fatalf("Unexpected overload report %+v", r)
+	}
+	if _, err = send(); err != sm.ErrTooBusy {
+		t.Fatalf("Unexpected error. Want %v, have %v", sm.ErrTooBusy, err)
+	}
+
+	// Throttled requests are diverted to other peers of the realm.
+	cli.Overload.Divert = true
+	c3, err := cli.Dial(srv3.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c3.Close()
+	if a, err = send(); err != nil {
+		t.Fatal(err)
+	}
+	if host, _ := a.FindAVP(avp.OriginHost, 0); host.Data != datatype.DiameterIdentity("srv3") {
+		t.Fatalf("Unexpected Origin-Host. Want srv3, have %v", host.Data)
+	}
+}
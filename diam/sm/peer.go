@@ -0,0 +1,245 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"sync/atomic"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// PeerState is the state of a peer connection in the peer state
+// machine. See RFC 6733 section 5.6 for details.
+type PeerState int
+
+// Peer states.
+//
+// Connections initiated by a Client go through PeerWaitConnAck and
+// PeerWaitICEA before reaching PeerIOpen. Connections accepted by a
+// server reach PeerROpen after a successful CER/CEA handshake.
+// PeerWaitReturns is the state of a connection initiated by a Client
+// while the election against a simultaneous connection from the same
+// peer is in progress.
+const (
+	PeerClosed PeerState = iota
+	PeerWaitConnAck
+	PeerWaitICEA
+	PeerWaitReturns
+	PeerROpen
+	PeerIOpen
+	PeerClosing
+)
+
+var peerStateNames = map[PeerState]string{
+	PeerClosed:      "Closed",
+	PeerWaitConnAck: "Wait-Conn-Ack",
+	PeerWaitICEA:    "Wait-I-CEA",
+	PeerWaitReturns: "Wait-Returns",
+	PeerROpen:       "R-Open",
+	PeerIOpen:       "I-Open",
+	PeerClosing:     "Closing",
+}
+
+func (s PeerState) String() string {
+	if name, ok := peerStateNames[s]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// Open reports whether the peer may exchange application messages
+// in this state.
+func (s PeerState) Open() bool {
+	return s == PeerROpen || s == PeerIOpen
+}
+
+// PeerStateEvent describes a peer state transition.
+type PeerStateEvent struct {
+	Conn       diam.Conn
+	OriginHost datatype.DiameterIdentity // Empty until known
+	From       PeerState
+	To         PeerState
+}
+
+// The PeerStateNotifier interface is implemented by Handlers
+// that allow observing the peer state machine.
+type PeerStateNotifier interface {
+	// PeerStateNotify returns a channel that receives an event
+	// for each peer state transition.
+	PeerStateNotify() <-chan PeerStateEvent
+}
+
+// peerStateNotifyBuffer is the capacity of the PeerStateNotify channel.
+// Events are dropped when the channel is full.
+const peerStateNotifyBuffer = 64
+
+// peer is the state of a single peer connection.
+type peer struct {
+	state     PeerState
+	host      datatype.DiameterIdentity // Origin-Host of the peer, if known
+	initiator bool                      // Connection initiated by a Client
+	ceac      chan error                // CEA of a pending handshake
+	wd        *watchdog                 // Watchdog, if enabled
+	wdState   WatchdogState
+	failc     chan struct{} // Closed on failover, created on demand
+}
+
+// failover closes the failover channel of p, if any. It must be called
+// with sm.mu held.
+func (p *peer) failover() {
+	if p.failc != nil {
+		close(p.failc)
+		p.failc = nil
+	}
+}
+
+// peer returns the peer of connection c, and false if unknown.
+func (sm *StateMachine) peer(c diam.Conn) (*peer, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	p, ok := sm.peers[c]
+	return p, ok
+}
+
+// addPeer starts tracking the state of connection c, and stops
+// tracking it when c is closed.
+func (sm *StateMachine) addPeer(c diam.Conn, p *peer) {
+	sm.mu.Lock()
+	sm.peers[c] = p
+	sm.mu.Unlock()
+	if p.state != PeerClosed {
+		sm.notifyPeerState(c, p.host, PeerClosed, p.state)
+	}
+	if cn, ok := c.(diam.CloseNotifier); ok {
+		closed := cn.CloseNotify()
+		go func() {
+			<-closed
+			sm.removePeer(c)
+		}()
+	}
+}
+
+// removePeer stops tracking connection c, moving it to PeerClosed.
+func (sm *StateMachine) removePeer(c diam.Conn) {
+	sm.mu.Lock()
+	p, ok := sm.peers[c]
+	delete(sm.peers, c)
+	if ok {
+		p.failover()
+	}
+	sm.mu.Unlock()
+	if ok && p.state != PeerClosed {
+		sm.notifyPeerState(c, p.host, p.state, PeerClosed)
+	}
+}
+
+// setPeerState moves connection c to the given state.
+func (sm *StateMachine) setPeerState(c diam.Conn, to PeerState) {
+	sm.mu.Lock()
+	p, ok := sm.peers[c]
+	if !ok || p.state == to {
+		sm.mu.Unlock()
+		return
+	}
+	from := p.state
+	p.state = to
+	host := p.host
+	if to == PeerClosing && p.wd != nil {
+		atomic.StoreInt32(&p.wd.closing, 1)
+	}
+	sm.mu.Unlock()
+	sm.notifyPeerState(c, host, from, to)
+}
+
+func (sm *StateMachine) notifyPeerState(c diam.Conn, host datatype.DiameterIdentity, from, to PeerState) {
+	sm.peerTable.update(c, host, to, sm.WatchdogState(c))
+	select {
+	case sm.psNotifyc <- PeerStateEvent{Conn: c, OriginHost: host, From: from, To: to}:
+	default:
+	}
+}
+
+// PeerState returns the state of connection c. Connections unknown to
+// the state machine, including the ones already closed, are PeerClosed.
+func (sm *StateMachine) PeerState(c diam.Conn) PeerState {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if p, ok := sm.peers[c]; ok {
+		return p.state
+	}
+	return PeerClosed
+}
+
+// PeerStateNotify implements the PeerStateNotifier interface.
+func (sm *StateMachine) PeerStateNotify() <-chan PeerStateEvent {
+	return sm.psNotifyc
+}
+
+// ReconnectAllowed reports whether connections to the peer identified
+// by host may be established. It is false after the peer disconnected
+// with Disconnect-Cause DO_NOT_WANT_TO_TALK_TO_YOU, until
+// AllowReconnect is called.
+func (sm *StateMachine) ReconnectAllowed(host datatype.DiameterIdentity) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return !sm.noReconnect[host]
+}
+
+// AllowReconnect allows connections to the peer identified by host
+// again, after it disconnected with DO_NOT_WANT_TO_TALK_TO_YOU.
+func (sm *StateMachine) AllowReconnect(host datatype.DiameterIdentity) {
+	sm.mu.Lock()
+	delete(sm.noReconnect, host)
+	sm.mu.Unlock()
+}
+
+// elect looks for a connection initiated to host that is waiting for
+// its CEA, and performs the election of RFC 6733 section 5.6.4 against
+// the connection c from the same peer. It returns true when there is no
+// such connection or the local node won the election, in which case the
+// initiated connection is dropped.
+func (sm *StateMachine) elect(c diam.Conn, host datatype.DiameterIdentity) bool {
+	if host == sm.cfg.OriginHost {
+		return true
+	}
+	sm.mu.Lock()
+	var (
+		ic diam.Conn
+		ip *peer
+	)
+	for pc, p := range sm.peers {
+		if pc != c && p.initiator && p.host == host && p.state == PeerWaitICEA {
+			ic, ip = pc, p
+			break
+		}
+	}
+	if ip == nil {
+		sm.mu.Unlock()
+		return true
+	}
+	ip.state = PeerWaitReturns
+	sm.mu.Unlock()
+	sm.notifyPeerState(ic, host, PeerWaitICEA, PeerWaitReturns)
+	if sm.cfg.OriginHost > host {
+		// Won the election: I-Disc.
+		sendHandshakeResult(ip.ceac, ErrElectionLost)
+		return true
+	}
+	return false
+}
+
+// openTo reports whether there is an open connection, other than c,
+// initiated to the peer identified by host.
+func (sm *StateMachine) openTo(c diam.Conn, host datatype.DiameterIdentity) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for pc, p := range sm.peers {
+		if pc != c && p.initiator && p.host == host && p.state == PeerIOpen {
+			return true
+		}
+	}
+	return false
+}
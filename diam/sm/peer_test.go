@@ -0,0 +1,82 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// testConn is a diam.Conn used as key of the peer table.
+type testConn struct {
+	diam.Conn
+	id int
+}
+
+func (c *testConn) Context() context.Context { return context.Background() }
+
+func TestPeerState_String(t *testing.T) {
+	for state, want := range map[PeerState]string{
+		PeerClosed:    "Closed",
+		PeerWaitICEA:  "Wait-I-CEA",
+		PeerIOpen:     "I-Open",
+		PeerState(99): "Unknown",
+	} {
+		if have := state.String(); have != want {
+			t.Fatalf("Unexpected state name. Want %s, have %s", want, have)
+		}
+	}
+}
+
+func TestElection(t *testing.T) {
+	for _, tc := range []struct {
+		local, remote string
+		win           bool
+	}{
+		{"b.example", "a.example", true},
+		{"a.example", "b.example", false},
+	} {
+		sm := New(&Settings{OriginHost: datatype.DiameterIdentity(tc.local), OriginRealm: "example"})
+		ic, rc := &testConn{id: 1}, &testConn{id: 2}
+		p := &peer{
+			state:     PeerWaitICEA,
+			host:      datatype.DiameterIdentity(tc.remote),
+			initiator: true,
+			ceac:      make(chan error, 1),
+		}
+		sm.addPeer(ic, p)
+		if win := sm.elect(rc, datatype.DiameterIdentity(tc.remote)); win != tc.win {
+			t.Fatalf("Unexpected election result for %s against %s. Want %t, have %t",
+				tc.local, tc.remote, tc.win, win)
+		}
+		if state := sm.PeerState(ic); state != PeerWaitReturns {
+			t.Fatalf("Unexpected state. Want Wait-Returns, have %s", state)
+		}
+		select {
+		case err := <-p.ceac:
+			if !tc.win || err != ErrElectionLost {
+				t.Fatalf("Unexpected handshake result: %v", err)
+			}
+		default:
+			if tc.win {
+				t.Fatal("Initiated connection not dropped after winning the election")
+			}
+		}
+	}
+}
+
+func TestElection_NoPendingConnection(t *testing.T) {
+	sm := New(&Settings{OriginHost: "a.example", OriginRealm: "example"})
+	sm.addPeer(&testConn{id: 1}, &peer{state: PeerIOpen, host: "b.example", initiator: true})
+	if !sm.elect(&testConn{id: 2}, "b.example") {
+		t.Fatal("Unexpected election lost without pending connection")
+	}
+	if !sm.openTo(&testConn{id: 2}, "b.example") {
+		t.Fatal("Open connection to b.example not found")
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm/smpeer"
+)
+
+// RelayApplicationID is the Application Id advertised by relay agents,
+// which support all applications. See RFC 6733 section 2.4.
+const RelayApplicationID = 0xffffffff
+
+// PeerEntry is an entry of the peer table. See RFC 6733 section 2.6.
+type PeerEntry struct {
+	Host         datatype.DiameterIdentity // Origin-Host of the peer
+	Realm        datatype.DiameterIdentity // Origin-Realm of the peer
+	Conn         diam.Conn
+	State        PeerState
+	Watchdog     WatchdogState
+	Applications []uint32 // Acct or Auth IDs advertised by the peer
+}
+
+// Available reports whether requests may be sent to the peer: the
+// connection is open and its watchdog is OKAY.
+func (e *PeerEntry) Available() bool {
+	return e.State.Open() && e.Watchdog == WatchdogOkay
+}
+
+// Supports reports whether the peer advertised the given application.
+// All peers support the base protocol, and relays support all
+// applications.
+func (e *PeerEntry) Supports(appID uint32) bool {
+	if appID == 0 {
+		return true
+	}
+	for _, id := range e.Applications {
+		if id == appID || id == RelayApplicationID {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerTable is the table of the peers of a node, keyed by Origin-Host.
+// It is safe for concurrent use.
+//
+// The table of a StateMachine is maintained automatically as peers
+// pass the handshake, change state, and disconnect.
+type PeerTable struct {
+	mu    sync.RWMutex // guards peers
+	peers map[datatype.DiameterIdentity]*PeerEntry
+}
+
+// NewPeerTable creates and initializes a new PeerTable.
+func NewPeerTable() *PeerTable {
+	return &PeerTable{
+		peers: make(map[datatype.DiameterIdentity]*PeerEntry),
+	}
+}
+
+// Put adds or replaces the entry of the peer e.Host.
+func (t *PeerTable) Put(e *PeerEntry) {
+	t.mu.Lock()
+	t.peers[e.Host] = copyPeerEntry(e)
+	t.mu.Unlock()
+}
+
+// Get returns a copy of the entry of the peer identified by host.
+func (t *PeerTable) Get(host datatype.DiameterIdentity) (*PeerEntry, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	e, ok := t.peers[host]
+	if !ok {
+		return nil, false
+	}
+	return copyPeerEntry(e), true
+}
+
+// Delete removes the entry of the peer identified by host.
+func (t *PeerTable) Delete(host datatype.DiameterIdentity) {
+	t.mu.Lock()
+	delete(t.peers, host)
+	t.mu.Unlock()
+}
+
+// Peers returns a copy of all entries, sorted by Origin-Host.
+func (t *PeerTable) Peers() []*PeerEntry {
+	t.mu.RLock()
+	peers := make([]*PeerEntry, 0, len(t.peers))
+	for _, e := range t.peers {
+		peers = append(peers, copyPeerEntry(e))
+	}
+	t.mu.RUnlock()
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Host < peers[j].Host })
+	return peers
+}
+
+func copyPeerEntry(e *PeerEntry) *PeerEntry {
+	cp := *e
+	cp.Applications = append([]uint32(nil), e.Applications...)
+	return &cp
+}
+
+// update applies a peer state transition of connection c to the table.
+func (t *PeerTable) update(c diam.Conn, host datatype.DiameterIdentity, state PeerState, wd WatchdogState) {
+	if host == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.peers[host]
+	if state.Open() && (!ok || e.Conn != c) {
+		e = &PeerEntry{Host: host, Conn: c}
+		if meta, ok := smpeer.FromContext(c.Context()); ok {
+			e.Realm = meta.OriginRealm
+			e.Applications = meta.Applications
+		}
+		t.peers[host] = e
+	} else if !ok || e.Conn != c {
+		return
+	}
+	if state == PeerClosed {
+		delete(t.peers, host)
+		return
+	}
+	e.State = state
+	e.Watchdog = wd
+}
+
+// updateWatchdog applies a watchdog state transition of connection c
+// to the table.
+func (t *PeerTable) updateWatchdog(c diam.Conn, host datatype.DiameterIdentity, wd WatchdogState) {
+	t.mu.Lock()
+	if e, ok := t.peers[host]; ok && e.Conn == c {
+		e.Watchdog = wd
+	}
+	t.mu.Unlock()
+}
+
+// PeerTable returns the peer table maintained by the state machine.
+func (sm *StateMachine) PeerTable() *PeerTable {
+	return sm.peerTable
+}
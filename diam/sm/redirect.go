@@ -0,0 +1,464 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/redirecthostusage"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm/smpeer"
+)
+
+// ErrNotRedirect is returned by ParseRedirect when the message is not
+// an answer with DIAMETER_REDIRECT_INDICATION and Redirect-Host AVPs.
+var ErrNotRedirect = errors.New("not a redirect indication")
+
+// Default ports of DiameterURIs. See RFC 6733 section 4.3.1.
+const (
+	defaultPort    = "3868"
+	defaultTLSPort = "5658"
+)
+
+// RedirectHost is a host of a Redirect-Host AVP.
+type RedirectHost struct {
+	URI     datatype.DiameterURI
+	Host    datatype.DiameterIdentity // FQDN of the URI
+	Addr    string                    // host:port
+	Network string                    // tcp, sctp or udp; tcp when not set in the URI
+	TLS     bool                      // aaas scheme
+}
+
+// ParseRedirectHost parses a DiameterURI of the form
+// aaa://FQDN[:port][;transport=tcp][;protocol=diameter], as described
+// in RFC 6733 section 4.3.1.
+func ParseRedirectHost(uri datatype.DiameterURI) (*RedirectHost, error) {
+	s := string(uri)
+	h := &RedirectHost{URI: uri, Network: "tcp"}
+	port := defaultPort
+	switch {
+	case strings.HasPrefix(s, "aaa://"):
+		s = s[len("aaa://"):]
+	case strings.HasPrefix(s, "aaas://"):
+		s = s[len("aaas://"):]
+		h.TLS = true
+		port = defaultTLSPort
+	default:
+		return nil, fmt.Errorf("invalid DiameterURI %q: unknown scheme", uri)
+	}
+	params := strings.Split(s, ";")
+	host := params[0]
+	for _, p := range params[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid DiameterURI %q: malformed parameter %q", uri, p)
+		}
+		switch strings.ToLower(kv[0]) {
+		case "transport":
+			h.Network = strings.ToLower(kv[1])
+		case "protocol":
+			if !strings.EqualFold(kv[1], "diameter") {
+				return nil, fmt.Errorf("invalid DiameterURI %q: unsupported protocol %q", uri, kv[1])
+			}
+		}
+	}
+	if hp, pp, err := net.SplitHostPort(host); err == nil {
+		host, port = hp, pp
+	}
+	if host == "" {
+		return nil, fmt.Errorf("invalid DiameterURI %q: missing host", uri)
+	}
+	h.Host = datatype.DiameterIdentity(host)
+	h.Addr = net.JoinHostPort(host, port)
+	return h, nil
+}
+
+// Redirect is a redirect indication: an answer with Result-Code
+// DIAMETER_REDIRECT_INDICATION. See RFC 6733 section 6.1.7.
+type Redirect struct {
+	Hosts        []*RedirectHost
+	Usage        datatype.Enumerated // Redirect-Host-Usage, DONT_CACHE if absent
+	MaxCacheTime time.Duration       // Redirect-Max-Cache-Time
+}
+
+// ParseRedirect parses the redirect indication m. It returns
+// ErrNotRedirect if m is not a redirect indication.
+func ParseRedirect(m *diam.Message) (*Redirect, error) {
+	if m.Header.CommandFlags&diam.RequestFlag != 0 {
+		return nil, ErrNotRedirect
+	}
+	rc, err := m.FindAVP(avp.ResultCode, 0)
+	if err != nil {
+		return nil, ErrNotRedirect
+	}
+	if code, ok := rc.Data.(datatype.Unsigned32); !ok || code != diam.RedirectIndication {
+		return nil, ErrNotRedirect
+	}
+	r := &Redirect{}
+	for _, a := range m.AVP {
+		if a.VendorID != 0 {
+			continue
+		}
+		switch a.Code {
+		case avp.RedirectHost:
+			uri, ok := a.Data.(datatype.DiameterURI)
+			if !ok {
+				return nil, fmt.Errorf("invalid Redirect-Host %v", a.Data)
+			}
+			h, err := ParseRedirectHost(uri)
+			if err != nil {
+				return nil, err
+			}
+			r.Hosts = append(r.Hosts, h)
+		case avp.RedirectHostUsage:
+			if v, ok := a.Data.(datatype.Enumerated); ok {
+				r.Usage = v
+			}
+		case avp.RedirectMaxCacheTime:
+			if v, ok := a.Data.(datatype.Unsigned32); ok {
+				r.MaxCacheTime = time.Duration(v) * time.Second
+			}
+		}
+	}
+	if len(r.Hosts) == 0 {
+		return nil, ErrNotRedirect
+	}
+	return r, nil
+}
+
+// redirectPrecedence is the order in which cached redirects are looked
+// up. See RFC 6733 section 6.13.
+var redirectPrecedence = []datatype.Enumerated{
+	redirecthostusage.ALL_SESSION,
+	redirecthostusage.ALL_USER,
+	redirecthostusage.REALM_AND_APPLICATION,
+	redirecthostusage.ALL_REALM,
+	redirecthostusage.ALL_APPLICATION,
+	redirecthostusage.ALL_HOST,
+}
+
+type redirectKey struct {
+	usage datatype.Enumerated
+	key   string
+}
+
+type redirectEntry struct {
+	hosts   []*RedirectHost
+	expires time.Time
+}
+
+// RedirectCache caches redirect indications according to their
+// Redirect-Host-Usage and Redirect-Max-Cache-Time, so that subsequent
+// requests are sent directly to the redirect hosts. It is safe for
+// concurrent use.
+//
+// The connections dialed by Client.SendRequest to the cached redirect
+// hosts are kept in the cache, and closed when no entry refers to their
+// host anymore.
+type RedirectCache struct {
+	mu      sync.Mutex // guards the following
+	entries map[redirectKey]*redirectEntry
+	conns   map[datatype.DiameterURI]diam.Conn
+}
+
+// NewRedirectCache creates and initializes a new RedirectCache.
+func NewRedirectCache() *RedirectCache {
+	return &RedirectCache{
+		entries: make(map[redirectKey]*redirectEntry),
+		conns:   make(map[datatype.DiameterURI]diam.Conn),
+	}
+}
+
+// redirectCacheKey returns the cache key of the request m sent to the
+// peer identified by host, for the given Redirect-Host-Usage.
+func redirectCacheKey(usage datatype.Enumerated, m *diam.Message, host datatype.DiameterIdentity) (redirectKey, bool) {
+	var key string
+	switch usage {
+	case redirecthostusage.ALL_SESSION:
+		key = stringAVP(m, avp.SessionID)
+	case redirecthostusage.ALL_USER:
+		key = stringAVP(m, avp.UserName)
+	case redirecthostusage.REALM_AND_APPLICATION:
+		if realm, ok := identityAVP(m, avp.DestinationRealm); ok {
+			key = fmt.Sprintf("%s/%d", strings.ToLower(string(realm)), m.Header.ApplicationID)
+		}
+	case redirecthostusage.ALL_REALM:
+		if realm, ok := identityAVP(m, avp.DestinationRealm); ok {
+			key = strings.ToLower(string(realm))
+		}
+	case redirecthostusage.ALL_APPLICATION:
+		key = fmt.Sprint(m.Header.ApplicationID)
+	case redirecthostusage.ALL_HOST:
+		key = string(host)
+	}
+	return redirectKey{usage, key}, key != ""
+}
+
+// Add caches the redirect indication r, received as the answer of
+// the request req sent to the peer identified by host. Indications
+// with Redirect-Host-Usage DONT_CACHE or without Redirect-Max-Cache-Time
+// are not cached.
+func (rc *RedirectCache) Add(req *diam.Message, host datatype.DiameterIdentity, r *Redirect) {
+	if r.Usage == redirecthostusage.DONT_CACHE || r.MaxCacheTime <= 0 {
+		return
+	}
+	k, ok := redirectCacheKey(r.Usage, req, host)
+	if !ok {
+		return
+	}
+	rc.mu.Lock()
+	rc.entries[k] = &redirectEntry{
+		hosts:   append([]*RedirectHost(nil), r.Hosts...),
+		expires: time.Now().Add(r.MaxCacheTime),
+	}
+	stale := rc.releaseConns()
+	rc.mu.Unlock()
+	closeConns(stale)
+}
+
+// Lookup returns the cached redirect hosts for the request m about to
+// be sent to the peer identified by host. When several entries match,
+// the one with the highest precedence Redirect-Host-Usage is returned.
+func (rc *RedirectCache) Lookup(m *diam.Message, host datatype.DiameterIdentity) ([]*RedirectHost, bool) {
+	now := time.Now()
+	var (
+		hosts   []*RedirectHost
+		expired bool
+	)
+	rc.mu.Lock()
+	for _, usage := range redirectPrecedence {
+		k, ok := redirectCacheKey(usage, m, host)
+		if !ok {
+			continue
+		}
+		e, ok := rc.entries[k]
+		if !ok {
+			continue
+		}
+		if !now.Before(e.expires) {
+			delete(rc.entries, k)
+			expired = true
+			continue
+		}
+		hosts = append([]*RedirectHost(nil), e.hosts...)
+		break
+	}
+	var stale []diam.Conn
+	if expired {
+		stale = rc.releaseConns()
+	}
+	rc.mu.Unlock()
+	closeConns(stale)
+	return hosts, hosts != nil
+}
+
+// RemoveExpired removes all entries expired at t and returns how many
+// were removed.
+func (rc *RedirectCache) RemoveExpired(t time.Time) int {
+	rc.mu.Lock()
+	var n int
+	for k, e := range rc.entries {
+		if !t.Before(e.expires) {
+			delete(rc.entries, k)
+			n++
+		}
+	}
+	stale := rc.releaseConns()
+	rc.mu.Unlock()
+	closeConns(stale)
+	return n
+}
+
+// conn returns the open connection kept for the redirect host uri.
+func (rc *RedirectCache) conn(uri datatype.DiameterURI) (diam.Conn, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	c, ok := rc.conns[uri]
+	if !ok {
+		return nil, false
+	}
+	if cn, ok := c.(diam.CloseNotifier); ok {
+		select {
+		case <-cn.CloseNotify():
+			delete(rc.conns, uri)
+			return nil, false
+		default:
+		}
+	}
+	return c, true
+}
+
+// keep keeps the connection c dialed to the redirect host uri, and
+// reports whether it was kept. Connections are only kept for the hosts
+// of cached entries, and one per host.
+func (rc *RedirectCache) keep(uri datatype.DiameterURI, c diam.Conn) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if _, ok := rc.conns[uri]; ok || !rc.referenced(uri) {
+		return false
+	}
+	rc.conns[uri] = c
+	return true
+}
+
+// referenced reports whether an entry has the redirect host uri.
+func (rc *RedirectCache) referenced(uri datatype.DiameterURI) bool {
+	for _, e := range rc.entries {
+		for _, h := range e.hosts {
+			if h.URI == uri {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// releaseConns forgets the connections kept for hosts that no entry
+// refers to anymore, and returns them to be closed.
+func (rc *RedirectCache) releaseConns() []diam.Conn {
+	var stale []diam.Conn
+	for uri, c := range rc.conns {
+		if !rc.referenced(uri) {
+			delete(rc.conns, uri)
+			stale = append(stale, c)
+		}
+	}
+	return stale
+}
+
+func closeConns(conns []diam.Conn) {
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// SendRequest sends the request m using c and waits for its answer, as
+// in diam.SendRequest, following redirect indications.
+//
+// If the Client has a RedirectCache and it holds redirect hosts for m,
+// m is sent to them instead of c. When the answer is a redirect
+// indication, it is added to the cache and m is sent again to the first
+// of its hosts that can be reached, up to MaxRedirects times. Redirect
+// hosts are reached through open connections of the state machine, or
+// connections dialed to their DiameterURI, which are kept open for
+// later requests while the RedirectCache holds them, or closed after the
+// request when the redirect indication is not cached. Redirect hosts with
+// an aaas URI are only reached through open connections.
+//
+// When a redirect indication cannot be followed it is returned as is.
+//
+// If the Client has an OverloadControl, m advertises DOIC, the overload
+// reports of the answers are applied, and m is throttled according to
+// them: it is diverted to another peer, or fails with ErrTooBusy. If it
+// has a LoadTable, the loads of the answers are recorded in it.
+func (cli *Client) SendRequest(ctx context.Context, c diam.Conn, m *diam.Message) (*diam.Message, error) {
+	// Connections dialed for this request only.
+	var dialed []diam.Conn
+	defer func() { closeConns(dialed) }()
+	if cli.Redirects != nil {
+		if hosts, ok := cli.Redirects.Lookup(m, connOriginHost(c)); ok {
+			if rc, temp, err := cli.redirectConn(hosts); err == nil {
+				c = rc
+				if temp {
+					dialed = append(dialed, rc)
+				}
+			}
+		}
+	}
+	for n := uint(0); ; n++ {
+		if cli.Overload != nil {
+			oc, err := cli.overloadConn(c, m)
+			if err != nil {
+				return nil, err
+			}
+			c = oc
+		}
+		a, err := diam.SendRequest(ctx, c, m)
+		if err == nil && cli.Overload != nil {
+			cli.Overload.Update(a)
+		}
+		if err == nil && cli.Loads != nil {
+			cli.Loads.Update(a)
+		}
+		if err != nil || n >= cli.MaxRedirects {
+			return a, err
+		}
+		r, err := ParseRedirect(a)
+		if err != nil {
+			return a, nil
+		}
+		if cli.Redirects != nil {
+			cli.Redirects.Add(m, connOriginHost(c), r)
+		}
+		rc, temp, err := cli.redirectConn(r.Hosts)
+		if err != nil {
+			return a, nil
+		}
+		c = rc
+		if temp {
+			dialed = append(dialed, rc)
+		}
+	}
+}
+
+// redirectConn returns a connection to the first reachable host, and
+// whether it was dialed for the request only, in which case it must be
+// closed after it. Connections dialed to the hosts of cached redirect
+// indications are kept in the RedirectCache instead.
+func (cli *Client) redirectConn(hosts []*RedirectHost) (diam.Conn, bool, error) {
+	err := ErrUnableToDeliver
+	for _, h := range hosts {
+		if e, ok := cli.Handler.PeerTable().Get(h.Host); ok && e.Available() {
+			return e.Conn, false, nil
+		}
+		if cli.Redirects != nil {
+			if c, ok := cli.Redirects.conn(h.URI); ok {
+				return c, false, nil
+			}
+		}
+		if h.TLS {
+			continue
+		}
+		var c diam.Conn
+		if c, err = cli.DialNetwork(h.Network, h.Addr); err == nil {
+			temp := cli.Redirects == nil || !cli.Redirects.keep(h.URI, c)
+			return c, temp, nil
+		}
+	}
+	return nil, false, err
+}
+
+// connOriginHost returns the Origin-Host of the peer of c, if known.
+func connOriginHost(c diam.Conn) datatype.DiameterIdentity {
+	if meta, ok := smpeer.FromContext(c.Context()); ok {
+		return meta.OriginHost
+	}
+	return ""
+}
+
+// stringAVP returns the value of the UTF8String or OctetString AVP
+// code of m, or an empty string.
+func stringAVP(m *diam.Message, code uint32) string {
+	for _, a := range m.AVP {
+		if a.Code != code || a.VendorID != 0 {
+			continue
+		}
+		switch v := a.Data.(type) {
+		case datatype.UTF8String:
+			return string(v)
+		case datatype.OctetString:
+			return string(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/redirecthostusage"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+func TestParseRedirectHost(t *testing.T) {
+	for _, tc := range []struct {
+		uri     datatype.DiameterURI
+		host    datatype.DiameterIdentity
+		addr    string
+		network string
+		tls     bool
+	}{
+		{"aaa://ocs.example", "ocs.example", "ocs.example:3868", "tcp", false},
+		{"aaa://ocs.example:1812;transport=sctp", "ocs.example", "ocs.example:1812", "sctp", false},
+		{"aaas://ocs.example;transport=tcp;protocol=diameter", "ocs.example", "ocs.example:5658", "tcp", true},
+		{"aaa://127.0.0.1:3869", "127.0.0.1", "127.0.0.1:3869", "tcp", false},
+	} {
+		h, err := ParseRedirectHost(tc.uri)
+		if err != nil {
+			t.Errorf("%s: %v", tc.uri, err)
+			continue
+		}
+		if h.Host != tc.host || h.Addr != tc.addr || h.Network != tc.network || h.TLS != tc.tls {
+			t.Errorf("%s: unexpected result %+v", tc.uri, h)
+		}
+	}
+	for _, uri := range []datatype.DiameterURI{
+		"http://ocs.example",
+		"aaa://",
+		"aaa://ocs.example;protocol=radius",
+		"aaa://ocs.example;transport",
+	} {
+		if _, err := ParseRedirectHost(uri); err == nil {
+			t.Errorf("%s: expected error", uri)
+		}
+	}
+}
+
+func TestParseRedirect(t *testing.T) {
+	req := newRoutingTestRequest(4, "ocs.example", "")
+	a := req.Answer(diam.RedirectIndication)
+	a.NewAVP(avp.RedirectHost, avp.Mbit, 0, datatype.DiameterURI("aaa://ocs1"))
+	a.NewAVP(avp.RedirectHost, avp.Mbit, 0, datatype.DiameterURI("aaa://ocs2"))
+	a.NewAVP(avp.RedirectHostUsage, avp.Mbit, 0, redirecthostusage.ALL_REALM)
+	a.NewAVP(avp.RedirectMaxCacheTime, avp.Mbit, 0, datatype.Unsigned32(60))
+	r, err := ParseRedirect(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Hosts) != 2 || r.Hosts[1].Host != "ocs2" {
+		t.Fatalf("Unexpected hosts %v", r.Hosts)
+	}
+	if r.Usage != redirecthostusage.ALL_REALM || r.MaxCacheTime != time.Minute {
+		t.Fatalf("Unexpected redirect %+v", r)
+	}
+	if _, err := ParseRedirect(req.Answer(diam.Success)); err != ErrNotRedirect {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrNotRedirect, err)
+	}
+}
+
+func TestRedirectCache(t *testing.T) {
+	rc := NewRedirectCache()
+	newRedirect := func(host string, usage datatype.Enumerated, d time.Duration) *Redirect {
+		return &Redirect{
+			Hosts:        []*RedirectHost{{Host: datatype.DiameterIdentity(host)}},
+			Usage:        usage,
+			MaxCacheTime: d,
+		}
+	}
+	req := newRoutingTestRequest(4, "ocs.example", "")
+	req.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+
+	rc.Add(req, "dra", newRedirect("never", redirecthostusage.DONT_CACHE, time.Minute))
+	rc.Add(req, "dra", newRedirect("never", redirecthostusage.ALL_REALM, 0))
+	if hosts, ok := rc.Lookup(req, "dra"); ok {
+		t.Fatalf("Unexpected cached hosts %v", hosts)
+	}
+
+	rc.Add(req, "dra", newRedirect("byhost", redirecthostusage.ALL_HOST, time.Minute))
+	rc.Add(req, "dra", newRedirect("byrealm", redirecthostusage.ALL_REALM, time.Minute))
+	for _, tc := range []struct {
+		m    *diam.Message
+		peer datatype.DiameterIdentity
+		want datatype.DiameterIdentity
+	}{
+		{req, "dra", "byrealm"},
+		{newRoutingTestRequest(4, "OCS.example", ""), "other", "byrealm"},
+		{newRoutingTestRequest(4, "hss.example", ""), "dra", "byhost"},
+		{newRoutingTestRequest(4, "hss.example", ""), "other", ""},
+	} {
+		hosts, ok := rc.Lookup(tc.m, tc.peer)
+		switch {
+		case tc.want == "" && ok:
+			t.Errorf("Unexpected cached hosts %v", hosts)
+		case tc.want != "" && (!ok || hosts[0].Host != tc.want):
+			t.Errorf("Unexpected cached hosts. Want %s, have %v", tc.want, hosts)
+		}
+	}
+
+	rc.Add(req, "dra", newRedirect("bysession", redirecthostusage.ALL_SESSION, time.Minute))
+	if hosts, _ := rc.Lookup(req, "dra"); len(hosts) == 0 || hosts[0].Host != "bysession" {
+		t.Fatalf("Unexpected cached hosts. Want bysession, have %v", hosts)
+	}
+	if n := rc.RemoveExpired(time.Now().Add(time.Hour)); n != 3 {
+		t.Fatalf("Unexpected number of expired entries. Want 3, have %d", n)
+	}
+	if hosts, ok := rc.Lookup(req, "dra"); ok {
+		t.Fatalf("Unexpected cached hosts %v", hosts)
+	}
+}
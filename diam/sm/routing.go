@@ -0,0 +1,262 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+var (
+	// ErrRealmNotServed is returned by SelectPeer when there is no
+	// route for the Destination-Realm of the request. It maps to
+	// DIAMETER_REALM_NOT_SERVED (3003).
+	ErrRealmNotServed = errors.New("realm not served")
+
+	// ErrUnableToDeliver is returned by SelectPeer when none of the
+	// peers of the route is available and supports the application
+	// of the request. It maps to DIAMETER_UNABLE_TO_DELIVER (3002).
+	ErrUnableToDeliver = errors.New("unable to deliver")
+)
+
+// LocalAction is the action of a route. See RFC 6733 section 2.7.
+type LocalAction int
+
+// Local actions.
+const (
+	LocalActionLocal    LocalAction = iota // Processed locally
+	LocalActionRelay                       // Forwarded unmodified
+	LocalActionProxy                       // Forwarded, may be modified
+	LocalActionRedirect                    // Answered with a redirect
+)
+
+var localActionNames = map[LocalAction]string{
+	LocalActionLocal:    "LOCAL",
+	LocalActionRelay:    "RELAY",
+	LocalActionProxy:    "PROXY",
+	LocalActionRedirect: "REDIRECT",
+}
+
+func (a LocalAction) String() string {
+	if name, ok := localActionNames[a]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// Route is an entry of the routing table. See RFC 6733 section 2.7.
+//
+// Routes with LocalActionRedirect are answered with the Redirect-Host
+// AVPs of RedirectHosts, or aaa://server for each of the Servers when
+// RedirectHosts is empty.
+type Route struct {
+	Realm   datatype.DiameterIdentity   // Destination-Realm, case insensitive
+	AppID   uint32                      // Application, or RelayApplicationID for all
+	Action  LocalAction                 // What to do with matching requests
+	Servers []datatype.DiameterIdentity // Next hops, in order of preference
+	Expires time.Time                   // Zero for static routes
+
+	RedirectHosts        []datatype.DiameterURI // Redirect-Host of redirect answers
+	RedirectUsage        datatype.Enumerated    // Redirect-Host-Usage of redirect answers
+	RedirectMaxCacheTime time.Duration          // Redirect-Max-Cache-Time of redirect answers
+}
+
+// Expired reports whether a dynamic route has expired at t.
+func (r *Route) Expired(t time.Time) bool {
+	return !r.Expires.IsZero() && !t.Before(r.Expires)
+}
+
+type routeKey struct {
+	realm string
+	appID uint32
+}
+
+// RoutingTable is the realm-based routing table of a node, keyed by
+// realm and application. It is safe for concurrent use.
+type RoutingTable struct {
+	mu           sync.RWMutex // guards the following
+	routes       map[routeKey]*Route
+	defaultRoute *Route
+}
+
+// NewRoutingTable creates and initializes a new RoutingTable.
+func NewRoutingTable() *RoutingTable {
+	return &RoutingTable{
+		routes: make(map[routeKey]*Route),
+	}
+}
+
+func newRouteKey(realm datatype.DiameterIdentity, appID uint32) routeKey {
+	return routeKey{strings.ToLower(string(realm)), appID}
+}
+
+func copyRoute(r *Route) *Route {
+	cp := *r
+	cp.Servers = append([]datatype.DiameterIdentity(nil), r.Servers...)
+	cp.RedirectHosts = append([]datatype.DiameterURI(nil), r.RedirectHosts...)
+	return &cp
+}
+
+// Add adds or replaces the route for r.Realm and r.AppID.
+func (rt *RoutingTable) Add(r *Route) {
+	rt.mu.Lock()
+	rt.routes[newRouteKey(r.Realm, r.AppID)] = copyRoute(r)
+	rt.mu.Unlock()
+}
+
+// Delete removes the route for the given realm and application.
+func (rt *RoutingTable) Delete(realm datatype.DiameterIdentity, appID uint32) {
+	rt.mu.Lock()
+	delete(rt.routes, newRouteKey(realm, appID))
+	rt.mu.Unlock()
+}
+
+// SetDefault sets the default route, used for requests to realms
+// without route. The realm and application of r are ignored.
+// A nil r removes the default route.
+func (rt *RoutingTable) SetDefault(r *Route) {
+	rt.mu.Lock()
+	if r == nil {
+		rt.defaultRoute = nil
+	} else {
+		rt.defaultRoute = copyRoute(r)
+	}
+	rt.mu.Unlock()
+}
+
+// Lookup returns the route for the given realm and application. Routes
+// for a specific application take precedence over routes for all
+// applications (RelayApplicationID), then the default route is used.
+// Expired routes are ignored.
+func (rt *RoutingTable) Lookup(realm datatype.DiameterIdentity, appID uint32) (*Route, bool) {
+	now := time.Now()
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	for _, id := range []uint32{appID, RelayApplicationID} {
+		if r, ok := rt.routes[newRouteKey(realm, id)]; ok && !r.Expired(now) {
+			return copyRoute(r), true
+		}
+	}
+	if rt.defaultRoute != nil {
+		return copyRoute(rt.defaultRoute), true
+	}
+	return nil, false
+}
+
+// Routes returns a copy of all routes that have not expired, sorted by
+// realm and application. The default route is not included.
+func (rt *RoutingTable) Routes() []*Route {
+	now := time.Now()
+	rt.mu.RLock()
+	routes := make([]*Route, 0, len(rt.routes))
+	for _, r := range rt.routes {
+		if !r.Expired(now) {
+			routes = append(routes, copyRoute(r))
+		}
+	}
+	rt.mu.RUnlock()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Realm != routes[j].Realm {
+			return routes[i].Realm < routes[j].Realm
+		}
+		return routes[i].AppID < routes[j].AppID
+	})
+	return routes
+}
+
+// RemoveExpired removes all routes expired at t and returns how many
+// were removed.
+func (rt *RoutingTable) RemoveExpired(t time.Time) int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var n int
+	for k, r := range rt.routes {
+		if r.Expired(t) {
+			delete(rt.routes, k)
+			n++
+		}
+	}
+	return n
+}
+
+// SelectPeer selects the peer to send the request m to, as described
+// in RFC 6733 section 6.1:
+//
+// If the request has a Destination-Host of an available peer that
+// supports the application of the request, that peer is selected.
+// Otherwise the route for the Destination-Realm and application is
+// looked up, and the first available peer of its servers that supports
+// the application is selected.
+//
+// The route is returned along with the peer, and is nil when the peer
+// was selected by Destination-Host. For routes with LocalActionLocal or
+// LocalActionRedirect no peer is selected, and the caller is expected
+// to handle the request.
+func (rt *RoutingTable) SelectPeer(peers *PeerTable, m *diam.Message) (*PeerEntry, *Route, error) {
+	return rt.selectPeer(peers, m, nil)
+}
+
+// SelectPeerByLoad selects the peer to send the request m to, as
+// SelectPeer, but chooses among all the available peers of the route
+// that support the application of the request, at random, weighted by
+// the capacity they have left according to their latest loads in loads.
+// See RFC 8583 section 5.2.
+func (rt *RoutingTable) SelectPeerByLoad(peers *PeerTable, loads *LoadTable, m *diam.Message) (*PeerEntry, *Route, error) {
+	return rt.selectPeer(peers, m, loads)
+}
+
+// selectPeer implements SelectPeer, and SelectPeerByLoad when loads is
+// not nil.
+func (rt *RoutingTable) selectPeer(peers *PeerTable, m *diam.Message, loads *LoadTable) (*PeerEntry, *Route, error) {
+	appID := m.Header.ApplicationID
+	if host, ok := identityAVP(m, avp.DestinationHost); ok {
+		if e, ok := peers.Get(host); ok && e.Available() && e.Supports(appID) {
+			return e, nil, nil
+		}
+	}
+	realm, ok := identityAVP(m, avp.DestinationRealm)
+	if !ok {
+		return nil, nil, ErrUnableToDeliver
+	}
+	r, ok := rt.Lookup(realm, appID)
+	if !ok {
+		return nil, nil, ErrRealmNotServed
+	}
+	switch r.Action {
+	case LocalActionLocal, LocalActionRedirect:
+		return nil, r, nil
+	}
+	var candidates []*PeerEntry
+	for _, host := range r.Servers {
+		if e, ok := peers.Get(host); ok && e.Available() && e.Supports(appID) {
+			if loads == nil {
+				return e, r, nil
+			}
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, r, ErrUnableToDeliver
+	}
+	return loads.choose(candidates), r, nil
+}
+
+// identityAVP returns the value of the DiameterIdentity AVP code of m.
+func identityAVP(m *diam.Message, code uint32) (datatype.DiameterIdentity, bool) {
+	for _, a := range m.AVP {
+		if a.Code == code && a.VendorID == 0 {
+			id, ok := a.Data.(datatype.DiameterIdentity)
+			return id, ok && id != ""
+		}
+	}
+	return "", false
+}
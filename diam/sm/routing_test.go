@@ -0,0 +1,129 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/loadtype"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+func newRoutingTestRequest(appID uint32, realm, host string) *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, appID, dict.Default)
+	if realm != "" {
+		m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity(realm))
+	}
+	if host != "" {
+		m.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.DiameterIdentity(host))
+	}
+	return m
+}
+
+func newRoutingTestPeers() *PeerTable {
+	peers := NewPeerTable()
+	for _, e := range []*PeerEntry{
+		{Host: "ocs1", Realm: "ocs.example", State: PeerIOpen, Watchdog: WatchdogSuspect, Applications: []uint32{4}},
+		{Host: "ocs2", Realm: "ocs.example", State: PeerIOpen, Watchdog: WatchdogOkay, Applications: []uint32{4}},
+		{Host: "hss1", Realm: "hss.example", State: PeerROpen, Watchdog: WatchdogOkay, Applications: []uint32{16777251}},
+		{Host: "dra", Realm: "dra.example", State: PeerIOpen, Watchdog: WatchdogOkay, Applications: []uint32{RelayApplicationID}},
+	} {
+		peers.Put(e)
+	}
+	return peers
+}
+
+func TestRoutingTable_SelectPeer(t *testing.T) {
+	peers := newRoutingTestPeers()
+	rt := NewRoutingTable()
+	rt.Add(&Route{Realm: "OCS.example", AppID: 4, Action: LocalActionRelay, Servers: []datatype.DiameterIdentity{"ocs1", "ocs2"}})
+	rt.Add(&Route{Realm: "hss.example", AppID: RelayApplicationID, Action: LocalActionProxy, Servers: []datatype.DiameterIdentity{"hss1"}})
+	rt.Add(&Route{Realm: "local.example", AppID: 4, Action: LocalActionLocal})
+	rt.SetDefault(&Route{Action: LocalActionRelay, Servers: []datatype.DiameterIdentity{"dra"}})
+
+	for _, tc := range []struct {
+		name   string
+		m      *diam.Message
+		peer   datatype.DiameterIdentity
+		action LocalAction
+		err    error
+	}{
+		{"skip SUSPECT peer", newRoutingTestRequest(4, "ocs.example", ""), "ocs2", LocalActionRelay, nil},
+		{"Destination-Host", newRoutingTestRequest(16777251, "hss.example", "hss1"), "hss1", 0, nil},
+		{"unavailable Destination-Host", newRoutingTestRequest(4, "ocs.example", "ocs1"), "ocs2", LocalActionRelay, nil},
+		{"route for all applications", newRoutingTestRequest(16777251, "hss.example", ""), "hss1", LocalActionProxy, nil},
+		{"unsupported application", newRoutingTestRequest(5, "hss.example", ""), "", LocalActionProxy, ErrUnableToDeliver},
+		{"local route", newRoutingTestRequest(4, "local.example", ""), "", LocalActionLocal, nil},
+		{"default route", newRoutingTestRequest(4, "other.example", ""), "dra", LocalActionRelay, nil},
+		{"missing Destination-Realm", newRoutingTestRequest(4, "", ""), "", 0, ErrUnableToDeliver},
+	} {
+		e, r, err := rt.SelectPeer(peers, tc.m)
+		if err != tc.err {
+			t.Fatalf("%s: unexpected error. Want %v, have %v", tc.name, tc.err, err)
+		}
+		if tc.peer == "" && e != nil {
+			t.Fatalf("%s: unexpected peer %s", tc.name, e.Host)
+		}
+		if tc.peer != "" && (e == nil || e.Host != tc.peer) {
+			t.Fatalf("%s: unexpected peer. Want %s, have %v", tc.name, tc.peer, e)
+		}
+		if r != nil && r.Action != tc.action {
+			t.Fatalf("%s: unexpected action. Want %s, have %s", tc.name, tc.action, r.Action)
+		}
+	}
+
+	rt.SetDefault(nil)
+	if _, _, err := rt.SelectPeer(peers, newRoutingTestRequest(4, "other.example", "")); err != ErrRealmNotServed {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrRealmNotServed, err)
+	}
+}
+
+func TestRoutingTable_SelectPeerByLoad(t *testing.T) {
+	peers := newRoutingTestPeers()
+	peers.Put(&PeerEntry{Host: "ocs3", Realm: "ocs.example", State: PeerIOpen, Watchdog: WatchdogOkay, Applications: []uint32{4}})
+	rt := NewRoutingTable()
+	rt.Add(&Route{Realm: "ocs.example", AppID: 4, Action: LocalActionRelay, Servers: []datatype.DiameterIdentity{"ocs1", "ocs2", "ocs3"}})
+	loads := NewLoadTable()
+	loads.loads[loadKey{loadtype.HOST, "ocs2"}] = MaxLoadValue
+	loads.loads[loadKey{loadtype.PEER, "ocs3"}] = 0
+	loads.loads[loadKey{loadtype.HOST, "ocs3"}] = MaxLoadValue
+	for i := 0; i < 100; i++ {
+		e, _, err := rt.SelectPeerByLoad(peers, loads, newRoutingTestRequest(4, "ocs.example", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Host != "ocs3" {
+			t.Fatalf("Unexpected peer. Want ocs3, have %s", e.Host)
+		}
+	}
+	// Peers without capacity left are still selected when alone.
+	loads.Delete("ocs3")
+	peers.Delete("ocs3")
+	if e, _, err := rt.SelectPeerByLoad(peers, loads, newRoutingTestRequest(4, "ocs.example", "")); err != nil || e.Host != "ocs2" {
+		t.Fatalf("Unexpected peer. Want ocs2, have %v (%v)", e, err)
+	}
+}
+
+func TestRoutingTable_Expired(t *testing.T) {
+	rt := NewRoutingTable()
+	rt.Add(&Route{Realm: "a.example", AppID: 4, Expires: time.Now().Add(-time.Second)})
+	rt.Add(&Route{Realm: "b.example", AppID: 4, Expires: time.Now().Add(time.Hour)})
+	if _, ok := rt.Lookup("a.example", 4); ok {
+		t.Fatal("Unexpected expired route")
+	}
+	if _, ok := rt.Lookup("b.example", 4); !ok {
+		t.Fatal("Route not found")
+	}
+	if routes := rt.Routes(); len(routes) != 1 || routes[0].Realm != "b.example" {
+		t.Fatalf("Unexpected routes: %v", routes)
+	}
+	if n := rt.RemoveExpired(time.Now()); n != 1 {
+		t.Fatalf("Unexpected # of removed routes. Want 1, have %d", n)
+	}
+}
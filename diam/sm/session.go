@@ -0,0 +1,173 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// SessionState is the state of a diameter session.
+// See RFC 6733 section 8.1 for details.
+type SessionState int
+
+// Session states.
+const (
+	SessionIdle SessionState = iota
+	SessionPending
+	SessionOpen
+	SessionDiscon
+)
+
+var sessionStateNames = map[SessionState]string{
+	SessionIdle:    "Idle",
+	SessionPending: "Pending",
+	SessionOpen:    "Open",
+	SessionDiscon:  "Discon",
+}
+
+func (s SessionState) String() string {
+	if name, ok := sessionStateNames[s]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// Session holds the state of a diameter session.
+type Session struct {
+	ID      datatype.UTF8String
+	State   SessionState
+	Peer    datatype.DiameterIdentity // Origin-Host of the peer owning the session
+	AppID   uint32                    // Application of the session
+	Created time.Time                 // Set by the store when unset
+	Expires time.Time                 // Zero for sessions without lifetime
+	Data    interface{}               // Application specific data
+}
+
+// Expired reports whether the session lifetime has expired at t.
+func (s *Session) Expired(t time.Time) bool {
+	return !s.Expires.IsZero() && !t.Before(s.Expires)
+}
+
+// SetLifetime sets the session to expire after d from now.
+// A zero or negative d removes the lifetime.
+func (s *Session) SetLifetime(d time.Duration) {
+	if d <= 0 {
+		s.Expires = time.Time{}
+		return
+	}
+	s.Expires = time.Now().Add(d)
+}
+
+// SessionStore is the interface implemented by session stores.
+//
+// Stores keep copies of the sessions: modifications of a Session
+// returned by Get must be saved with Put.
+type SessionStore interface {
+	// Put adds or replaces the session with the same ID.
+	Put(s *Session)
+
+	// Get returns the session with the given ID. Expired
+	// sessions are removed and never returned.
+	Get(id datatype.UTF8String) (*Session, bool)
+
+	// Delete removes the session with the given ID.
+	Delete(id datatype.UTF8String)
+
+	// PeerSessions returns the sessions owned by the given peer.
+	PeerSessions(peer datatype.DiameterIdentity) []*Session
+}
+
+// MemorySessionStore is an in-memory SessionStore.
+// It is safe for concurrent use.
+type MemorySessionStore struct {
+	mu       sync.RWMutex // guards sessions
+	sessions map[datatype.UTF8String]Session
+}
+
+// NewMemorySessionStore creates and initializes a new MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[datatype.UTF8String]Session),
+	}
+}
+
+// Put implements the SessionStore interface.
+func (st *MemorySessionStore) Put(s *Session) {
+	if s.Created.IsZero() {
+		s.Created = time.Now()
+	}
+	st.mu.Lock()
+	st.sessions[s.ID] = *s
+	st.mu.Unlock()
+}
+
+// Get implements the SessionStore interface.
+func (st *MemorySessionStore) Get(id datatype.UTF8String) (*Session, bool) {
+	st.mu.RLock()
+	s, ok := st.sessions[id]
+	st.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if now := time.Now(); s.Expired(now) {
+		// The session may have been replaced since the read lock
+		// was released: only remove it if it is still expired.
+		st.mu.Lock()
+		if s, ok := st.sessions[id]; ok && s.Expired(now) {
+			delete(st.sessions, id)
+		}
+		st.mu.Unlock()
+		return nil, false
+	}
+	return &s, true
+}
+
+// Delete implements the SessionStore interface.
+func (st *MemorySessionStore) Delete(id datatype.UTF8String) {
+	st.mu.Lock()
+	delete(st.sessions, id)
+	st.mu.Unlock()
+}
+
+// PeerSessions implements the SessionStore interface.
+func (st *MemorySessionStore) PeerSessions(peer datatype.DiameterIdentity) []*Session {
+	now := time.Now()
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	var sessions []*Session
+	for _, s := range st.sessions {
+		if s.Peer == peer && !s.Expired(now) {
+			s := s
+			sessions = append(sessions, &s)
+		}
+	}
+	return sessions
+}
+
+// Len returns the number of sessions in the store, including the
+// expired ones that were not removed yet.
+func (st *MemorySessionStore) Len() int {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return len(st.sessions)
+}
+
+// RemoveExpired removes all sessions expired at t and returns
+// how many were removed.
+func (st *MemorySessionStore) RemoveExpired(t time.Time) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var n int
+	for id, s := range st.sessions {
+		if s.Expired(t) {
+			delete(st.sessions, id)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,153 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+func TestSessionIDGenerator(t *testing.T) {
+	g := newSessionIDGenerator("host.example.com", time.Unix(1000, 0))
+	want := []datatype.UTF8String{
+		"host.example.com;1000;0",
+		"host.example.com;1000;1",
+		"host.example.com;1000;2;opt",
+	}
+	have := []datatype.UTF8String{g.NextID(), g.NextID(), g.NextID("opt")}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Fatalf("Unexpected Session-Id. Want %q, have %q", string(want[i]), string(have[i]))
+		}
+	}
+	if host := SessionIDHost(have[2]); host != "host.example.com" {
+		t.Fatalf("Unexpected Session-Id host. Want host.example.com, have %q", string(host))
+	}
+}
+
+func TestSessionIDGenerator_Wrap(t *testing.T) {
+	g := newSessionIDGenerator("h", time.Unix(7, 0))
+	g.n = 7<<32 | 0xffffffff - 1
+	g.NextID()
+	if id := g.NextID(); id != "h;8;0" {
+		t.Fatalf("Unexpected Session-Id. Want h;8;0, have %q", string(id))
+	}
+}
+
+func TestSessionIDGenerator_Concurrent(t *testing.T) {
+	sm := New(&Settings{OriginHost: "srv"})
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		seen = make(map[datatype.UTF8String]bool)
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				id := sm.NewSessionID()
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("Duplicate Session-Id %q", string(id))
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMemorySessionStore(t *testing.T) {
+	st := NewMemorySessionStore()
+	for i := 0; i < 3; i++ {
+		st.Put(&Session{
+			ID:    datatype.UTF8String(fmt.Sprintf("cli;1;%d", i)),
+			State: SessionOpen,
+			Peer:  "cli",
+		})
+	}
+	st.Put(&Session{ID: "other;1;1", State: SessionPending, Peer: "other"})
+
+	s, ok := st.Get("cli;1;1")
+	if !ok {
+		t.Fatal("Session not found")
+	}
+	if s.State != SessionOpen || s.Created.IsZero() {
+		t.Fatalf("Unexpected session: %+v", s)
+	}
+	// Sessions are copied in and out of the store.
+	s.State = SessionDiscon
+	if s, _ = st.Get("cli;1;1"); s.State != SessionOpen {
+		t.Fatalf("Unexpected state. Want %s, have %s", SessionOpen, s.State)
+	}
+	if n := len(st.PeerSessions("cli")); n != 3 {
+		t.Fatalf("Unexpected number of peer sessions. Want 3, have %d", n)
+	}
+	st.Delete("cli;1;1")
+	if _, ok = st.Get("cli;1;1"); ok {
+		t.Fatal("Deleted session found")
+	}
+	if n := st.Len(); n != 3 {
+		t.Fatalf("Unexpected number of sessions. Want 3, have %d", n)
+	}
+}
+
+func TestMemorySessionStore_Lifetime(t *testing.T) {
+	st := NewMemorySessionStore()
+	s := &Session{ID: "a", Peer: "cli"}
+	s.SetLifetime(time.Hour)
+	st.Put(s)
+	st.Put(&Session{ID: "b", Peer: "cli", Expires: time.Now().Add(-time.Second)})
+	st.Put(&Session{ID: "c", Peer: "cli"})
+
+	if _, ok := st.Get("a"); !ok {
+		t.Fatal("Session a not found")
+	}
+	if n := len(st.PeerSessions("cli")); n != 2 {
+		t.Fatalf("Unexpected number of peer sessions. Want 2, have %d", n)
+	}
+	if _, ok := st.Get("b"); ok {
+		t.Fatal("Expired session b found")
+	}
+	if n := st.RemoveExpired(time.Now().Add(2 * time.Hour)); n != 1 {
+		t.Fatalf("Unexpected number of expired sessions. Want 1, have %d", n)
+	}
+	if n := st.Len(); n != 1 {
+		t.Fatalf("Unexpected number of sessions. Want 1, have %d", n)
+	}
+}
+
+func TestMemorySessionStore_GetPut(t *testing.T) {
+	st := NewMemorySessionStore()
+	for i := 0; i < 1000; i++ {
+		st.Put(&Session{ID: "a", Expires: time.Now().Add(-time.Second)})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			st.Get("a")
+		}()
+		st.Put(&Session{ID: "a"})
+		wg.Wait()
+		if _, ok := st.Get("a"); !ok {
+			t.Fatalf("%d: session replaced by Put removed by Get", i)
+		}
+	}
+}
+
+func TestSessionState_String(t *testing.T) {
+	if s := SessionOpen.String(); s != "Open" {
+		t.Fatalf("Unexpected state name. Want Open, have %s", s)
+	}
+	if s := SessionState(99).String(); s != "Unknown" {
+		t.Fatalf("Unexpected state name. Want Unknown, have %s", s)
+	}
+}
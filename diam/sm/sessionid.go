@@ -0,0 +1,60 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// SessionIDGenerator generates Session-Id values as described in
+// RFC 6733 section 8.8:
+//
+//	<DiameterIdentity>;<high 32 bits>;<low 32 bits>[;<optional value>]
+//
+// The high 32 bits are initialized with the time the generator was
+// created, and the 64 bits value is incremented for each Session-Id.
+// It is safe for concurrent use.
+type SessionIDGenerator struct {
+	host datatype.DiameterIdentity
+	n    uint64
+}
+
+// NewSessionIDGenerator creates a SessionIDGenerator for the given host,
+// typically the Origin-Host of the node.
+func NewSessionIDGenerator(host datatype.DiameterIdentity) *SessionIDGenerator {
+	return newSessionIDGenerator(host, time.Now())
+}
+
+func newSessionIDGenerator(host datatype.DiameterIdentity, boot time.Time) *SessionIDGenerator {
+	return &SessionIDGenerator{
+		host: host,
+		n:    uint64(uint32(boot.Unix()))<<32 - 1,
+	}
+}
+
+// NextID returns a new Session-Id. The optional values are appended
+// to the Session-Id, separated by semicolons.
+func (g *SessionIDGenerator) NextID(optional ...string) datatype.UTF8String {
+	n := atomic.AddUint64(&g.n, 1)
+	id := fmt.Sprintf("%s;%d;%d", string(g.host), uint32(n>>32), uint32(n))
+	if len(optional) > 0 {
+		id += ";" + strings.Join(optional, ";")
+	}
+	return datatype.UTF8String(id)
+}
+
+// SessionIDHost returns the DiameterIdentity part of the Session-Id.
+func SessionIDHost(id datatype.UTF8String) datatype.DiameterIdentity {
+	s := string(id)
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		s = s[:i]
+	}
+	return datatype.DiameterIdentity(s)
+}
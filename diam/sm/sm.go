@@ -6,6 +6,7 @@ package sm
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/ctrlzy/go-diameter/v4/diam"
@@ -69,24 +70,69 @@ type Settings struct {
 	//
 	// Deprecated: HostIPAddress is depreciated, use HostIPAddresses instead
 	HostIPAddress datatype.Address
+
+	// WatchdogInterval enables the watchdog of RFC 3539 on connections
+	// accepted by servers when set, with the given interval (Twinit).
+	// RFC 3539 recommends 30s, and no less than 6s.
+	//
+	// Clients use Client.EnableWatchdog and Client.WatchdogInterval.
+	WatchdogInterval time.Duration
+
+	// DuplicateDetectionTime enables duplicate detection when set.
+	// Answers to requests are kept for the given time, and requests
+	// with the same End-to-End Identifier and Origin-Host received in
+	// the meantime, such as retransmissions with the 'T' flag, are
+	// answered with them instead of being passed to the handlers.
+	// See RFC 6733 section 6.2.
+	DuplicateDetectionTime time.Duration
+
+	// AnswerErrors enables answering protocol and validation errors
+	// with error answers generated by the state machine, per RFC 6733
+	// section 7: requests with unknown commands, invalid header bits or
+	// invalid AVPs, and requests of handshaked peers with unsupported
+	// applications or failing Message.Validate. The answers carry the
+	// Origin-Host and Origin-Realm above, an Error-Message, and the
+	// offending AVP in a Failed-AVP when there is one.
+	//
+	// When unset, messages that fail to decode are reported to the
+	// ErrorReports channel and close their connection.
+	AnswerErrors bool
+
+	// ValidateEnumerated makes AnswerErrors also answer requests with
+	// Enumerated values not defined in the dictionary, as reported by
+	// Message.ValidateEnumerated, with DIAMETER_INVALID_AVP_VALUE.
+	ValidateEnumerated bool
 }
 
 var (
 	baseCERIdx = diam.CommandIndex{AppID: 0, Code: diam.CapabilitiesExchange, Request: true}
 	baseCEAIdx = diam.CommandIndex{AppID: 0, Code: diam.CapabilitiesExchange, Request: false}
 	baseDWRIdx = diam.CommandIndex{AppID: 0, Code: diam.DeviceWatchdog, Request: true}
+	baseDPRIdx = diam.CommandIndex{AppID: 0, Code: diam.DisconnectPeer, Request: true}
+	baseDPAIdx = diam.CommandIndex{AppID: 0, Code: diam.DisconnectPeer, Request: false}
 )
 
 // StateMachine is a specialized type of diam.ServeMux that handles
-// the CER/CEA handshake and DWR/DWA messages for clients or servers.
+// the CER/CEA handshake, DWR/DWA and DPR/DPA messages for clients or
+// servers, and keeps track of the state of each peer connection.
 //
 // Other handlers registered in the state machine are only executed
 // after the peer has passed the initial CER/CEA handshake.
 type StateMachine struct {
 	cfg           *Settings
 	mux           *diam.ServeMux
-	hsNotifyc     chan diam.Conn // handshake notifier
+	hsNotifyc     chan diam.Conn          // handshake notifier
+	psNotifyc     chan PeerStateEvent     // peer state notifier
+	wdNotifyc     chan WatchdogStateEvent // watchdog state notifier
 	supportedApps []*SupportedApp
+	sessionIDs    *SessionIDGenerator
+	peerTable     *PeerTable
+	dups          *duplicates // duplicate detection, if enabled
+
+	mu           sync.Mutex // guards the following
+	peers        map[diam.Conn]*peer
+	noReconnect  map[datatype.DiameterIdentity]bool
+	watchdogDown map[datatype.DiameterIdentity]bool
 }
 
 // New creates and initializes a new StateMachine for clients or servers.
@@ -98,12 +144,31 @@ func New(settings *Settings) *StateMachine {
 		cfg:           settings,
 		mux:           diam.NewServeMux(),
 		hsNotifyc:     make(chan diam.Conn),
+		psNotifyc:     make(chan PeerStateEvent, peerStateNotifyBuffer),
+		wdNotifyc:     make(chan WatchdogStateEvent, peerStateNotifyBuffer),
 		supportedApps: PrepareSupportedApps(dict.Default),
+		sessionIDs:    NewSessionIDGenerator(settings.OriginHost),
+		peerTable:     NewPeerTable(),
+		peers:         make(map[diam.Conn]*peer),
+		noReconnect:   make(map[datatype.DiameterIdentity]bool),
+		watchdogDown:  make(map[datatype.DiameterIdentity]bool),
 	}
 	sm.mux.Handle("CER", handleCER(sm))
+	sm.mux.Handle("CEA", handleCEA(sm))
 	sm.mux.Handle("DWR", handshakeOK(handleDWR(sm)))
+	sm.mux.Handle("DPR", handshakeOK(handleDPR(sm)))
+	sm.mux.Handle("DPA", handleDPA(sm))
 	sm.mux.HandleIdx(baseCERIdx, handleCER(sm))
+	sm.mux.HandleIdx(baseCEAIdx, handleCEA(sm))
 	sm.mux.HandleIdx(baseDWRIdx, handleDWR(sm))
+	sm.mux.HandleIdx(baseDPRIdx, handshakeOK(handleDPR(sm)))
+	sm.mux.HandleIdx(baseDPAIdx, handleDPA(sm))
+	if settings.WatchdogInterval > 0 {
+		sm.mux.Handle("DWA", handshakeOK(handleDWA(sm)))
+	}
+	if settings.DuplicateDetectionTime > 0 {
+		sm.dups = newDuplicates(settings.DuplicateDetectionTime)
+	}
 	return sm
 }
 
@@ -112,11 +177,33 @@ func (sm *StateMachine) Settings() *Settings {
 	return sm.cfg
 }
 
+// NewSessionID returns a new Session-Id for the Origin-Host of the
+// state machine Settings. See SessionIDGenerator for details.
+func (sm *StateMachine) NewSessionID(optional ...string) datatype.UTF8String {
+	return sm.sessionIDs.NextID(optional...)
+}
+
 // ServeDIAM implements the diam.Handler interface.
 func (sm *StateMachine) ServeDIAM(c diam.Conn, m *diam.Message) {
+	sm.observe(c, m)
+	if isApplicationRequest(m) && (sm.cfg.AnswerErrors || sm.dups != nil) {
+		if _, ok := smpeer.FromContext(c.Context()); ok {
+			if sm.cfg.AnswerErrors && sm.answerInvalid(c, m) {
+				return
+			}
+			if sm.dups != nil && sm.dups.check(c, m) {
+				return
+			}
+		}
+	}
 	sm.mux.ServeDIAM(c, m)
 }
 
+// ObserveAnswer implements the diam.AnswerObserver interface.
+func (sm *StateMachine) ObserveAnswer(c diam.Conn, m *diam.Message) {
+	sm.observe(c, m)
+}
+
 // Handle implements the diam.Handler interface.
 func (sm *StateMachine) Handle(cmd string, handler diam.Handler) {
 	sm.HandleFunc(cmd, handler.ServeDIAM)
@@ -124,7 +211,7 @@ func (sm *StateMachine) Handle(cmd string, handler diam.Handler) {
 
 func (sm *StateMachine) HandleIdx(cmd diam.CommandIndex, handler diam.Handler) {
 	switch cmd {
-	case baseCERIdx, baseCEAIdx, baseDWRIdx:
+	case baseCERIdx, baseCEAIdx, baseDWRIdx, baseDPRIdx, baseDPAIdx:
 		sm.Error(&diam.ErrorReport{
 			Error: fmt.Errorf("cannot overwrite %v command in the state machine", cmd),
 		})
@@ -136,7 +223,7 @@ func (sm *StateMachine) HandleIdx(cmd diam.CommandIndex, handler diam.Handler) {
 // HandleFunc implements the diam.Handler interface.
 func (sm *StateMachine) HandleFunc(cmd string, handler diam.HandlerFunc) {
 	switch cmd {
-	case "CER", "CEA", "DWR":
+	case "CER", "CEA", "DWR", "DPR", "DPA":
 		sm.Error(&diam.ErrorReport{
 			Error: fmt.Errorf("cannot overwrite %s command in the state machine", cmd),
 		})
@@ -180,10 +267,3 @@ func (f handshakeOK) ServeDIAM(c diam.Conn, m *diam.Message) {
 		f(c, m)
 	}
 }
-
-var sessionId uint64 = 0
-
-func getSessionId(host datatype.DiameterIdentity) string {
-	sessionId += 1
-	return fmt.Sprintf("%v;%v;%v", host, time.Now(), sessionId)
-}
@@ -0,0 +1,52 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smparser
+
+import (
+	"fmt"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// DPA is a Disconnect-Peer-Answer message.
+// See RFC 6733 section 5.4.2 for details.
+type DPA struct {
+	ResultCode   uint32                    `avp:"Result-Code"`
+	OriginHost   datatype.DiameterIdentity `avp:"Origin-Host"`
+	OriginRealm  datatype.DiameterIdentity `avp:"Origin-Realm"`
+	ErrorMessage string                    `avp:"Error-Message"`
+	FailedAVP    []*diam.AVP               `avp:"Failed-AVP"`
+}
+
+// Parse parses and validates the given message.
+func (dpa *DPA) Parse(m *diam.Message) error {
+	if err := m.Unmarshal(dpa); err != nil {
+		return err
+	}
+	if err := dpa.sanityCheck(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sanityCheck ensures mandatory AVPs are present.
+func (dpa *DPA) sanityCheck() error {
+	if dpa.ResultCode == 0 {
+		return ErrMissingResultCode
+	}
+	if len(dpa.OriginHost) == 0 {
+		return ErrMissingOriginHost
+	}
+	if len(dpa.OriginRealm) == 0 {
+		return ErrMissingOriginRealm
+	}
+	return nil
+}
+
+func (r *DPA) String() string {
+	return fmt.Sprintf("DPA { ResultCode: %d, OriginHost: %s, OriginRealm: %s, ErrorMessage: %s }",
+		r.ResultCode, r.OriginHost, r.OriginRealm, r.ErrorMessage)
+}
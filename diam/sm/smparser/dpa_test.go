@@ -0,0 +1,39 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smparser
+
+import (
+	"testing"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+func TestDPA_MissingResultCode(t *testing.T) {
+	m := diam.NewMessage(diam.DisconnectPeer, 0, 0, 0, 0, nil)
+	dpa := new(DPA)
+	if err := dpa.Parse(m); err != ErrMissingResultCode {
+		t.Fatal("Unexpected error:", err)
+	}
+}
+
+func TestDPA(t *testing.T) {
+	m := diam.NewMessage(diam.DisconnectPeer, 0, 0, 0, 0, nil)
+	m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(diam.Success))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	dpa := new(DPA)
+	if err := dpa.Parse(m); err != nil {
+		t.Fatal(err)
+	}
+	if dpa.ResultCode != diam.Success {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %d",
+			diam.Success, dpa.ResultCode)
+	}
+	if dpa.OriginHost != "foobar" {
+		t.Fatalf("Unexpected Origin-Host. Want foobar, have %s", dpa.OriginHost)
+	}
+}
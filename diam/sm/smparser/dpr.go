@@ -0,0 +1,67 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smparser
+
+import (
+	"fmt"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+)
+
+// DPR is a Disconnect-Peer-Request message.
+// See RFC 6733 section 5.4.1 for details.
+type DPR struct {
+	OriginHost      datatype.DiameterIdentity `avp:"Origin-Host"`
+	OriginRealm     datatype.DiameterIdentity `avp:"Origin-Realm"`
+	DisconnectCause *diam.AVP                 `avp:"Disconnect-Cause"`
+}
+
+// Parse parses and validates the given message, and returns nil when
+// all AVPs are ok.
+func (dpr *DPR) Parse(m *diam.Message) error {
+	if err := m.Unmarshal(dpr); err != nil {
+		return err
+	}
+	if err := dpr.sanityCheck(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sanityCheck ensures all mandatory AVPs are present.
+func (dpr *DPR) sanityCheck() error {
+	if len(dpr.OriginHost) == 0 {
+		return ErrMissingOriginHost
+	}
+	if len(dpr.OriginRealm) == 0 {
+		return ErrMissingOriginRealm
+	}
+	return nil
+}
+
+// Cause returns the value of the Disconnect-Cause AVP, and false
+// if the AVP is not present.
+func (dpr *DPR) Cause() (datatype.Enumerated, bool) {
+	if dpr.DisconnectCause == nil {
+		return 0, false
+	}
+	cause, ok := dpr.DisconnectCause.Data.(datatype.Enumerated)
+	return cause, ok
+}
+
+func (r *DPR) String() string {
+	result := "DPR { "
+	if r != nil {
+		result += fmt.Sprintf("OriginHost: %s, OriginRealm: %s", r.OriginHost, r.OriginRealm)
+		if r.DisconnectCause != nil {
+			result += fmt.Sprintf(", DisconnectCause: %v", r.DisconnectCause.String())
+		}
+	} else {
+		result += "nil"
+	}
+	result += " }"
+	return result
+}
@@ -0,0 +1,53 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smparser
+
+import (
+	"testing"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/constants/disconnectcause"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+func TestDPR_MissingOriginHost(t *testing.T) {
+	m := diam.NewRequest(diam.DisconnectPeer, 0, dict.Default)
+	dpr := new(DPR)
+	if err := dpr.Parse(m); err != ErrMissingOriginHost {
+		t.Fatal("Unexpected error:", err)
+	}
+}
+
+func TestDPR_MissingOriginRealm(t *testing.T) {
+	m := diam.NewRequest(diam.DisconnectPeer, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	dpr := new(DPR)
+	if err := dpr.Parse(m); err != ErrMissingOriginRealm {
+		t.Fatal("Unexpected error:", err)
+	}
+}
+
+func TestDPR_OK(t *testing.T) {
+	m := diam.NewRequest(diam.DisconnectPeer, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	dpr := new(DPR)
+	if err := dpr.Parse(m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dpr.Cause(); ok {
+		t.Fatal("Unexpected Disconnect-Cause")
+	}
+	m.NewAVP(avp.DisconnectCause, avp.Mbit, 0, disconnectcause.DO_NOT_WANT_TO_TALK_TO_YOU)
+	if err := dpr.Parse(m); err != nil {
+		t.Fatal(err)
+	}
+	if cause, ok := dpr.Cause(); !ok || cause != disconnectcause.DO_NOT_WANT_TO_TALK_TO_YOU {
+		t.Fatalf("Unexpected Disconnect-Cause. Want %d, have %d",
+			disconnectcause.DO_NOT_WANT_TO_TALK_TO_YOU, cause)
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+// WatchdogState is the state of the watchdog of a peer connection.
+// See RFC 3539 section 3.4 for details.
+type WatchdogState int
+
+// Watchdog states.
+const (
+	WatchdogInitial WatchdogState = iota
+	WatchdogOkay
+	WatchdogSuspect
+	WatchdogDown
+	WatchdogReopen
+)
+
+var watchdogStateNames = map[WatchdogState]string{
+	WatchdogInitial: "INITIAL",
+	WatchdogOkay:    "OKAY",
+	WatchdogSuspect: "SUSPECT",
+	WatchdogDown:    "DOWN",
+	WatchdogReopen:  "REOPEN",
+}
+
+func (s WatchdogState) String() string {
+	if name, ok := watchdogStateNames[s]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// WatchdogStateEvent describes a watchdog state transition.
+type WatchdogStateEvent struct {
+	Conn       diam.Conn
+	OriginHost datatype.DiameterIdentity
+	From       WatchdogState
+	To         WatchdogState
+}
+
+// The WatchdogStateNotifier interface is implemented by Handlers
+// that allow observing the watchdog of peer connections.
+type WatchdogStateNotifier interface {
+	// WatchdogStateNotify returns a channel that receives an
+	// event for each watchdog state transition.
+	WatchdogStateNotify() <-chan WatchdogStateEvent
+}
+
+const (
+	// reopenDWAs is the number of DWAs required in REOPEN before
+	// the peer is considered back.
+	reopenDWAs = 3
+
+	// maxWatchdogJitter is the maximum jitter added to or subtracted
+	// from the watchdog interval.
+	maxWatchdogJitter = 2 * time.Second
+)
+
+// watchdog runs the watchdog algorithm of RFC 3539 on a connection.
+//
+// Any message received from the peer counts as liveness, except in
+// REOPEN where only DWAs do. DWAs with a Result-Code other than
+// DIAMETER_SUCCESS are ignored.
+type watchdog struct {
+	sm       *StateMachine
+	c        diam.Conn
+	host     datatype.DiameterIdentity
+	interval time.Duration // Twinit
+	stream   uint          // Stream to send DWR on
+	dwac     chan struct{} // Successful DWA received
+	trafficc chan struct{} // Other message received
+	closing  int32         // Set when the connection is closing gracefully
+
+	// Owned by the run goroutine.
+	state   WatchdogState
+	pending bool
+	numDWA  int
+}
+
+func newWatchdog(sm *StateMachine, c diam.Conn, host datatype.DiameterIdentity, interval time.Duration, stream uint) *watchdog {
+	return &watchdog{
+		sm:       sm,
+		c:        c,
+		host:     host,
+		interval: interval,
+		stream:   stream,
+		dwac:     make(chan struct{}, 1),
+		trafficc: make(chan struct{}, 1),
+	}
+}
+
+// dwa reports a successful DWA.
+func (wd *watchdog) dwa() {
+	select {
+	case wd.dwac <- struct{}{}:
+	default:
+	}
+}
+
+// traffic reports a message other than DWA.
+func (wd *watchdog) traffic() {
+	select {
+	case wd.trafficc <- struct{}{}:
+	default:
+	}
+}
+
+// tw returns the watchdog interval with jitter, as described in
+// RFC 3539 section 3.4.1. The jitter is limited to a fourth of the
+// interval for intervals shorter than 8 seconds.
+func (wd *watchdog) tw() time.Duration {
+	jitter := maxWatchdogJitter
+	if jitter > wd.interval/4 {
+		jitter = wd.interval / 4
+	}
+	if jitter <= 0 {
+		return wd.interval
+	}
+	return wd.interval - jitter + time.Duration(rand.Int63n(int64(2*jitter)+1))
+}
+
+// run is the watchdog loop. It starts in OKAY, or in REOPEN for
+// connections replacing one that went DOWN, and returns when the
+// connection is closed.
+func (wd *watchdog) run(reopen bool) {
+	var closed <-chan struct{}
+	if cn, ok := wd.c.(diam.CloseNotifier); ok {
+		closed = cn.CloseNotify()
+	}
+	if reopen {
+		wd.setState(WatchdogReopen)
+		wd.send()
+	} else {
+		wd.setState(WatchdogOkay)
+	}
+	timer := time.NewTimer(wd.tw())
+	defer timer.Stop()
+	for {
+		select {
+		case <-closed:
+			wd.down()
+			return
+		case <-wd.dwac:
+			wd.pending = false
+			switch wd.state {
+			case WatchdogReopen:
+				wd.numDWA++
+				if wd.numDWA >= reopenDWAs {
+					wd.setState(WatchdogOkay)
+				}
+				continue
+			case WatchdogSuspect:
+				wd.setState(WatchdogOkay)
+			}
+			resetTimer(timer, wd.tw())
+		case <-wd.trafficc:
+			switch wd.state {
+			case WatchdogReopen:
+				continue
+			case WatchdogSuspect:
+				wd.setState(WatchdogOkay)
+			}
+			resetTimer(timer, wd.tw())
+		case <-timer.C:
+			switch {
+			case wd.state == WatchdogSuspect:
+				wd.c.Close()
+				wd.down()
+				return
+			case !wd.pending:
+				wd.send()
+			case wd.state == WatchdogOkay:
+				wd.setState(WatchdogSuspect)
+			case wd.numDWA < 0:
+				// REOPEN with two consecutive DWRs unanswered.
+				wd.c.Close()
+				wd.down()
+				return
+			default:
+				wd.numDWA = -1
+			}
+			timer.Reset(wd.tw())
+		}
+	}
+}
+
+// send sends a DWR to the peer.
+func (wd *watchdog) send() {
+	wd.pending = true
+	m := wd.sm.makeDWR(wd.c.Dictionary())
+	if _, err := m.WriteToStream(wd.c, wd.stream); err != nil {
+		wd.sm.Error(&diam.ErrorReport{
+			Conn:    wd.c,
+			Message: m,
+			Error:   err,
+		})
+	}
+}
+
+// down moves the watchdog to DOWN. Unless the connection was closed
+// gracefully, the next watchdog for the same peer starts in REOPEN.
+func (wd *watchdog) down() {
+	if atomic.LoadInt32(&wd.closing) == 0 && wd.host != "" {
+		wd.sm.mu.Lock()
+		wd.sm.watchdogDown[wd.host] = true
+		wd.sm.mu.Unlock()
+	}
+	wd.setState(WatchdogDown)
+}
+
+func (wd *watchdog) setState(to WatchdogState) {
+	if wd.state == to {
+		return
+	}
+	from := wd.state
+	wd.state = to
+	wd.sm.mu.Lock()
+	if p, ok := wd.sm.peers[wd.c]; ok {
+		p.wdState = to
+		if to == WatchdogSuspect || to == WatchdogDown {
+			p.failover()
+		}
+	}
+	if to == WatchdogOkay && wd.host != "" {
+		delete(wd.sm.watchdogDown, wd.host)
+	}
+	wd.sm.mu.Unlock()
+	wd.sm.peerTable.updateWatchdog(wd.c, wd.host, to)
+	select {
+	case wd.sm.wdNotifyc <- WatchdogStateEvent{Conn: wd.c, OriginHost: wd.host, From: from, To: to}:
+	default:
+	}
+}
+
+// resetTimer stops t, drains its channel and resets it to d.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
+
+// startWatchdog starts the watchdog of connection c, which must be
+// open. The watchdog starts in REOPEN if the previous watchdog of a
+// connection initiated to the same peer went DOWN.
+func (sm *StateMachine) startWatchdog(c diam.Conn, interval time.Duration, stream uint) {
+	sm.mu.Lock()
+	p, ok := sm.peers[c]
+	if !ok || p.wd != nil {
+		sm.mu.Unlock()
+		return
+	}
+	wd := newWatchdog(sm, c, p.host, interval, stream)
+	p.wd = wd
+	reopen := p.initiator && sm.watchdogDown[p.host]
+	sm.mu.Unlock()
+	go wd.run(reopen)
+}
+
+// observe reports messages received on c, other than DWA, to the
+// watchdog of c.
+func (sm *StateMachine) observe(c diam.Conn, m *diam.Message) {
+	if m.Header.CommandCode == diam.DeviceWatchdog &&
+		m.Header.CommandFlags&diam.RequestFlag == 0 {
+		// Reported by handleDWA, when successful.
+		return
+	}
+	sm.mu.Lock()
+	p, ok := sm.peers[c]
+	sm.mu.Unlock()
+	if ok && p.wd != nil {
+		p.wd.traffic()
+	}
+}
+
+// WatchdogState returns the watchdog state of connection c.
+//
+// Open connections without watchdog are always WatchdogOkay, and
+// connections unknown to the state machine, including the ones
+// already closed, are WatchdogDown.
+func (sm *StateMachine) WatchdogState(c diam.Conn) WatchdogState {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	p, ok := sm.peers[c]
+	switch {
+	case !ok:
+		return WatchdogDown
+	case p.wd != nil:
+		return p.wdState
+	case p.state.Open():
+		return WatchdogOkay
+	default:
+		return WatchdogInitial
+	}
+}
+
+// WatchdogStateNotify implements the WatchdogStateNotifier interface.
+func (sm *StateMachine) WatchdogStateNotify() <-chan WatchdogStateEvent {
+	return sm.wdNotifyc
+}
+
+// makeDWR creates a Device-Watchdog-Request.
+func (sm *StateMachine) makeDWR(d *dict.Parser) *diam.Message {
+	m := diam.NewRequest(diam.DeviceWatchdog, 0, d)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, sm.cfg.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, sm.cfg.OriginRealm)
+	if sm.cfg.OriginStateID != 0 {
+		m.NewAVP(avp.OriginStateID, avp.Mbit, 0, sm.cfg.OriginStateID)
+	}
+	return m
+}
@@ -0,0 +1,147 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+	"github.com/ctrlzy/go-diameter/v4/diam/sm"
+)
+
+// These tests use dictionary, settings and functions from sm_test.go.
+
+// dropDWRHandler passes messages to the state machine, except DWRs
+// while drop is set.
+type dropDWRHandler struct {
+	*sm.StateMachine
+	drop *int32
+}
+
+func (h dropDWRHandler) ServeDIAM(c diam.Conn, m *diam.Message) {
+	if m.Header.CommandCode == diam.DeviceWatchdog && m.Header.CommandFlags&diam.RequestFlag != 0 &&
+		atomic.LoadInt32(h.drop) == 1 {
+		return
+	}
+	h.StateMachine.ServeDIAM(c, m)
+}
+
+func newWatchdogTestClient() *sm.Client {
+	cli := newTestClient(sm.New(clientSettings), 1001)
+	cli.EnableWatchdog = true
+	cli.WatchdogInterval = 50 * time.Millisecond
+	return cli
+}
+
+func waitWatchdogStates(t *testing.T, events <-chan sm.WatchdogStateEvent, want ...sm.WatchdogState) {
+	t.Helper()
+	for _, state := range want {
+		select {
+		case ev := <-events:
+			if ev.To != state {
+				t.Fatalf("Unexpected watchdog state. Want %s, have %s", state, ev.To)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for watchdog state %s", state)
+		}
+	}
+}
+
+func TestWatchdog_Server(t *testing.T) {
+	settings := *serverSettings
+	settings.WatchdogInterval = 50 * time.Millisecond
+	srvSM := sm.New(&settings)
+	srv := diamtest.NewServer(srvSM, dict.Default)
+	defer srv.Close()
+	cli := newTestClient(sm.New(clientSettings), 1001)
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ev := waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerROpen)
+	waitWatchdogStates(t, srvSM.WatchdogStateNotify(), sm.WatchdogOkay)
+	// The client answers the DWRs of the server.
+	time.Sleep(300 * time.Millisecond)
+	if state := srvSM.WatchdogState(ev.Conn); state != sm.WatchdogOkay {
+		t.Fatalf("Unexpected watchdog state. Want OKAY, have %s", state)
+	}
+	if state := cli.Handler.WatchdogState(c); state != sm.WatchdogOkay {
+		t.Fatalf("Unexpected client watchdog state. Want OKAY, have %s", state)
+	}
+}
+
+func TestWatchdog_SuspectDownReopen(t *testing.T) {
+	drop := int32(1)
+	srv := diamtest.NewServer(dropDWRHandler{sm.New(serverSettings), &drop}, dict.Default)
+	defer srv.Close()
+	cli := newWatchdogTestClient()
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	events := cli.Handler.WatchdogStateNotify()
+	waitWatchdogStates(t, events, sm.WatchdogOkay, sm.WatchdogSuspect, sm.WatchdogDown)
+	select {
+	case <-c.(diam.CloseNotifier).CloseNotify():
+	case <-time.After(time.Second):
+		t.Fatal("Connection not closed by the watchdog")
+	}
+	// The peer answers DWRs again: three DWAs are required in REOPEN.
+	atomic.StoreInt32(&drop, 0)
+	c, err = cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitWatchdogStates(t, events, sm.WatchdogReopen, sm.WatchdogOkay)
+}
+
+func TestWatchdog_TrafficIsLiveness(t *testing.T) {
+	drop := int32(1)
+	srvSM := sm.New(serverSettings)
+	srv := diamtest.NewServer(dropDWRHandler{srvSM, &drop}, dict.Default)
+	defer srv.Close()
+	cli := newWatchdogTestClient()
+	cli.WatchdogInterval = 100 * time.Millisecond
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ev := waitPeerState(t, srvSM.PeerStateNotify(), sm.PeerROpen)
+	waitWatchdogStates(t, cli.Handler.WatchdogStateNotify(), sm.WatchdogOkay)
+	// Keep the connection busy with DWRs from the server. The client
+	// never receives DWAs to its own DWRs.
+	done := time.After(500 * time.Millisecond)
+	for {
+		select {
+		case <-done:
+			if state := cli.Handler.WatchdogState(c); state != sm.WatchdogOkay {
+				t.Fatalf("Unexpected watchdog state. Want OKAY, have %s", state)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+			m := diam.NewRequest(diam.DeviceWatchdog, 0, dict.Default)
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, serverSettings.OriginHost)
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, serverSettings.OriginRealm)
+			if _, err := m.WriteTo(ev.Conn); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestWatchdogState_String(t *testing.T) {
+	if s := sm.WatchdogSuspect.String(); s != "SUSPECT" {
+		t.Fatalf("Unexpected state name. Want SUSPECT, have %s", s)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Request/answer correlation for diameter connections.
+
+package diam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrRequestTimeout is the cause of a RequestError when the
+	// deadline of the request context expires before the answer
+	// is received. The cause also matches context.DeadlineExceeded.
+	ErrRequestTimeout = errors.New("request timeout (no answer)")
+
+	// ErrConnectionClosed is the cause of a RequestError when the
+	// connection goes away before the answer is received.
+	ErrConnectionClosed = errors.New("connection closed")
+
+	// ErrNotRequest is returned by SendRequest when the message
+	// does not have the Request bit set.
+	ErrNotRequest = errors.New("message is not a request")
+
+	// ErrNoHopByHopID is the cause of a RequestError when the
+	// Hop-by-Hop Identifiers generated for the request are all in
+	// use by pending requests of the connection.
+	ErrNoHopByHopID = errors.New("no Hop-by-Hop Identifier available")
+)
+
+// RequestError is returned by SendRequest when a request fails
+// before its answer is received. The cause is one of ErrRequestTimeout,
+// ErrConnectionClosed, ErrNoHopByHopID, context.Canceled or the error
+// returned when writing the request to the connection.
+type RequestError struct {
+	HopByHopID uint32
+	EndToEndID uint32
+	Err        error
+}
+
+// Error implements the error interface.
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("diameter request (hop-by-hop 0x%x, end-to-end 0x%x) failed: %s",
+		e.HopByHopID, e.EndToEndID, e.Err)
+}
+
+// Unwrap returns the cause of the failure.
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// The RequestSender interface is implemented by Conns which
+// allow sending requests and waiting for their answers.
+//
+// Answers that do not match any pending request are dispatched
+// to the connection's Handler as usual.
+type RequestSender interface {
+	// SendRequest assigns a new Hop-by-Hop Identifier to the
+	// request m, writes it to the connection and blocks until
+	// the matching answer arrives, ctx is done or the connection
+	// is closed.
+	SendRequest(ctx context.Context, m *Message) (*Message, error)
+}
+
+// SendRequest sends the request m using c and waits for its answer.
+// It fails with an error if c does not implement RequestSender.
+func SendRequest(ctx context.Context, c Conn, m *Message) (*Message, error) {
+	rs, ok := c.(RequestSender)
+	if !ok {
+		return nil, fmt.Errorf("connection %T does not support SendRequest", c)
+	}
+	return rs.SendRequest(ctx, m)
+}
+
+// transaction is a request waiting for its answer.
+type transaction struct {
+	endToEnd uint32
+	answerc  chan *Message
+}
+
+// transactions tracks the pending requests of a connection,
+// indexed by Hop-by-Hop Identifier.
+type transactions struct {
+	ids     IDGenerator // Hop-by-Hop Identifiers of this connection
+	mu      sync.Mutex  // guards the following
+	pending map[uint32]*transaction
+	closed  bool
+}
+
+func newTransactions() *transactions {
+	return &transactions{
+		ids:     NewConnHopByHopIDs(),
+		pending: make(map[uint32]*transaction),
+	}
+}
+
+// add assigns a new Hop-by-Hop Identifier to m and registers it
+// as a pending transaction.
+func (ts *transactions) add(m *Message) (*transaction, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.closed {
+		return nil, ErrConnectionClosed
+	}
+	// A generator that keeps returning in-flight identifiers would
+	// otherwise loop forever while holding the lock.
+	hopByHop, ok := ts.ids.NextID(), false
+	for i := len(ts.pending); i >= 0; i-- {
+		if _, exists := ts.pending[hopByHop]; !exists {
+			ok = true
+			break
+		}
+		hopByHop = ts.ids.NextID()
+	}
+	if !ok {
+		return nil, ErrNoHopByHopID
+	}
+	m.Header.HopByHopID = hopByHop
+	t := &transaction{
+		endToEnd: m.Header.EndToEndID,
+		answerc:  make(chan *Message, 1),
+	}
+	ts.pending[m.Header.HopByHopID] = t
+	return t, nil
+}
+
+// remove drops the pending transaction of the given Hop-by-Hop Identifier.
+func (ts *transactions) remove(hopByHop uint32) {
+	ts.mu.Lock()
+	delete(ts.pending, hopByHop)
+	ts.mu.Unlock()
+}
+
+// deliver hands the answer m over to its pending transaction, if any.
+// It returns false when m does not match a pending request.
+func (ts *transactions) deliver(m *Message) bool {
+	if m.Header.CommandFlags&RequestFlag == RequestFlag {
+		return false
+	}
+	ts.mu.Lock()
+	t, ok := ts.pending[m.Header.HopByHopID]
+	if ok && t.endToEnd == m.Header.EndToEndID {
+		delete(ts.pending, m.Header.HopByHopID)
+	} else {
+		ok = false
+	}
+	ts.mu.Unlock()
+	if ok {
+		t.answerc <- m
+	}
+	return ok
+}
+
+// close fails all pending transactions and rejects new ones.
+func (ts *transactions) close() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.closed {
+		return
+	}
+	ts.closed = true
+	for hopByHop, t := range ts.pending {
+		close(t.answerc)
+		delete(ts.pending, hopByHop)
+	}
+}
+
+// SendRequest implements the RequestSender interface.
+func (w *response) SendRequest(ctx context.Context, m *Message) (*Message, error) {
+	if m.Header.CommandFlags&RequestFlag != RequestFlag {
+		return nil, ErrNotRequest
+	}
+	// Requests of done contexts are not written.
+	if err := contextError(ctx); err != nil {
+		return nil, &RequestError{m.Header.HopByHopID, m.Header.EndToEndID, err}
+	}
+	ts := w.conn.transactions
+	t, err := ts.add(m)
+	if err != nil {
+		return nil, &RequestError{m.Header.HopByHopID, m.Header.EndToEndID, err}
+	}
+	hopByHop := m.Header.HopByHopID
+	if _, err = m.WriteTo(w); err != nil {
+		ts.remove(hopByHop)
+		return nil, &RequestError{hopByHop, t.endToEnd, err}
+	}
+	select {
+	case a, ok := <-t.answerc:
+		if !ok {
+			return nil, &RequestError{hopByHop, t.endToEnd, ErrConnectionClosed}
+		}
+		return a, nil
+	case <-ctx.Done():
+		ts.remove(hopByHop)
+		return nil, &RequestError{hopByHop, t.endToEnd, contextError(ctx)}
+	}
+}
+
+// contextError returns the error of ctx, wrapped with ErrRequestTimeout
+// if its deadline expired.
+func contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if err == context.DeadlineExceeded {
+		return fmt.Errorf("%w: %w", ErrRequestTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,223 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/diamtest"
+)
+
+func newDWR() *diam.Message {
+	m := diam.NewRequest(diam.DeviceWatchdog, 0, nil)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("localhost"))
+	return m
+}
+
+func handleDWR(c diam.Conn, m *diam.Message) {
+	a := m.Answer(diam.Success)
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("srv"))
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("localhost"))
+	a.WriteTo(c)
+}
+
+func TestSendRequest(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.HandleFunc("DWR", handleDWR)
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	cmux := diam.NewServeMux()
+	unmatched := make(chan *diam.Message, 1)
+	cmux.HandleFunc("DWA", func(c diam.Conn, m *diam.Message) {
+		unmatched <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		req := newDWR()
+		a, err := diam.SendRequest(ctx, cli, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Header.HopByHopID != req.Header.HopByHopID {
+			t.Fatalf("Unexpected Hop-by-Hop. Want 0x%x, have 0x%x",
+				req.Header.HopByHopID, a.Header.HopByHopID)
+		}
+		if a.Header.EndToEndID != req.Header.EndToEndID {
+			t.Fatalf("Unexpected End-to-End. Want 0x%x, have 0x%x",
+				req.Header.EndToEndID, a.Header.EndToEndID)
+		}
+	}
+	// Answers to requests not sent by SendRequest go to the handler.
+	if _, err = newDWR().WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-unmatched:
+	case err := <-cmux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: unmatched DWA not delivered to the handler")
+	}
+	select {
+	case m := <-unmatched:
+		t.Fatalf("Matched answer delivered to the handler:\n%s", m)
+	default:
+	}
+}
+
+func TestSendRequest_Timeout(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	cli, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = diam.SendRequest(ctx, cli, newDWR())
+	var rerr *diam.RequestError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Unexpected error type %T: %v", err, err)
+	}
+	if !errors.Is(err, diam.ErrRequestTimeout) {
+		t.Fatalf("Unexpected error. Want %v, have %v", diam.ErrRequestTimeout, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Unexpected error. Want %v, have %v", context.DeadlineExceeded, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = diam.SendRequest(ctx, cli, newDWR())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error. Want %v, have %v", context.Canceled, err)
+	}
+}
+
+func TestSendRequest_Canceled(t *testing.T) {
+	received := make(chan *diam.Message, 1)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {
+		received <- m
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	cli, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = diam.SendRequest(ctx, cli, newDWR())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error. Want %v, have %v", context.Canceled, err)
+	}
+	select {
+	case m := <-received:
+		t.Fatalf("Request of canceled context sent:\n%s", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSendRequest_ConnectionClosed(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {
+		c.Close()
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	cli, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = diam.SendRequest(ctx, cli, newDWR())
+	if !errors.Is(err, diam.ErrConnectionClosed) {
+		t.Fatalf("Unexpected error. Want %v, have %v", diam.ErrConnectionClosed, err)
+	}
+	_, err = diam.SendRequest(ctx, cli, newDWR())
+	if !errors.Is(err, diam.ErrConnectionClosed) {
+		t.Fatalf("Unexpected error. Want %v, have %v", diam.ErrConnectionClosed, err)
+	}
+}
+
+func TestSendRequest_NotRequest(t *testing.T) {
+	srv := diamtest.NewServer(diam.NewServeMux(), nil)
+	defer srv.Close()
+	cli, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	_, err = diam.SendRequest(context.Background(), cli, newDWR().Answer(diam.Success))
+	if err != diam.ErrNotRequest {
+		t.Fatalf("Unexpected error. Want %v, have %v", diam.ErrNotRequest, err)
+	}
+}
+
+type observerMux struct {
+	*diam.ServeMux
+	answers chan *diam.Message
+}
+
+func (h observerMux) ObserveAnswer(c diam.Conn, m *diam.Message) {
+	h.answers <- m
+}
+
+func TestSendRequest_AnswerObserver(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.HandleFunc("DWR", handleDWR)
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	h := observerMux{diam.NewServeMux(), make(chan *diam.Message, 1)}
+	cli, err := diam.Dial(srv.Addr, h, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	a, err := diam.SendRequest(ctx, cli, newDWR())
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-h.answers:
+		if m != a {
+			t.Fatalf("Unexpected observed answer:\n%s", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: answer not observed")
+	}
+}
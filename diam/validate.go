@@ -0,0 +1,186 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Validation of messages against the rules of the dictionary.
+
+package diam
+
+import (
+	"fmt"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+// ValidationError is returned by Message.Validate when the message
+// does not comply with the rules of its command in the dictionary.
+//
+// Code is the Result-Code to answer the message with, and AVP is the
+// offending AVP to be sent in the Failed-AVP of the answer. See
+// RFC 6733 section 7.5 for details.
+type ValidationError struct {
+	Code uint32 // CommandUnsupported, InvalidAVPBits, InvalidAVPValue, AVPUnsupported, MissingAVP or AVPOccursTooManyTimes
+	AVP  *AVP   // Offending AVP, nil for CommandUnsupported
+	Name string // Name of the offending AVP or command in the dictionary
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	switch e.Code {
+	case CommandUnsupported:
+		return fmt.Sprintf("command %s is not supported", e.Name)
+	case InvalidAVPBits:
+		return fmt.Sprintf("AVP %s has flags that do not comply with its flag rules", e.Name)
+	case InvalidAVPValue:
+		return fmt.Sprintf("AVP %s has an invalid value", e.Name)
+	case AVPUnsupported:
+		return fmt.Sprintf("unsupported AVP %s with the M-bit set", e.Name)
+	case MissingAVP:
+		return fmt.Sprintf("missing AVP %s", e.Name)
+	case AVPOccursTooManyTimes:
+		return fmt.Sprintf("AVP %s occurs too many times", e.Name)
+	}
+	return fmt.Sprintf("invalid AVP %s (result code %d)", e.Name, e.Code)
+}
+
+// Validate checks the message against the rules of its command in the
+// dictionary, and the AVPs of Grouped AVPs against the rules of their
+// data in the dictionary, recursively. It reports the first of:
+//
+//   - AVPs with flags that do not comply with their flag rules in the
+//     dictionary: InvalidAVPBits
+//   - AVPs unknown to the dictionary with the M-bit set: AVPUnsupported
+//   - AVPs occurring more times than allowed: AVPOccursTooManyTimes
+//   - required AVPs missing, or occurring less times than allowed: MissingAVP
+//
+// as a *ValidationError. Messages with commands unknown to the
+// dictionary fail with CommandUnsupported. Enumerated values are checked
+// separately by ValidateEnumerated.
+func (m *Message) Validate() error {
+	d := m.Dictionary()
+	cmd, err := d.FindCommand(m.Header.ApplicationID, m.Header.CommandCode)
+	if err != nil {
+		return &ValidationError{
+			Code: CommandUnsupported,
+			Name: fmt.Sprint(m.Header.CommandCode),
+		}
+	}
+	rules := cmd.Answer.Rule
+	if m.Header.CommandFlags&RequestFlag == RequestFlag {
+		rules = cmd.Request.Rule
+	}
+	return validateAVPs(d, m.Header.ApplicationID, m.AVP, rules)
+}
+
+// validateAVPs checks avps against rules, then the AVPs of Grouped
+// AVPs against their own rules.
+func validateAVPs(d *dict.Parser, appID uint32, avps []*AVP, rules []*dict.Rule) error {
+	for _, a := range avps {
+		if _, ok := a.Data.(datatype.Unknown); ok {
+			if a.Flags&avp.Mbit == avp.Mbit {
+				return &ValidationError{
+					Code: AVPUnsupported,
+					AVP:  a,
+					Name: fmt.Sprintf("%d (vendor %d)", a.Code, a.VendorID),
+				}
+			}
+			continue
+		}
+		dictAVP, err := d.FindAVPWithVendor(appID, a.Code, a.VendorID)
+		if err != nil {
+			continue
+		}
+		if dictAVP.FlagRules().Check(a.Flags) != nil {
+			return &ValidationError{Code: InvalidAVPBits, AVP: a, Name: dictAVP.Name}
+		}
+	}
+	for _, rule := range rules {
+		if rule.AVP == "AVP" {
+			// Wildcard for any AVP.
+			continue
+		}
+		dictAVP, err := d.FindAVP(appID, rule.AVP)
+		if err != nil {
+			continue
+		}
+		var (
+			n    int
+			last *AVP
+		)
+		for _, a := range avps {
+			if a.Code == dictAVP.Code && a.VendorID == dictAVP.VendorID {
+				n++
+				last = a
+			}
+		}
+		if rule.Max > 0 && n > rule.Max {
+			return &ValidationError{Code: AVPOccursTooManyTimes, AVP: last, Name: rule.AVP}
+		}
+		min := rule.Min
+		if rule.Required && min < 1 {
+			min = 1
+		}
+		if n < min {
+			return &ValidationError{Code: MissingAVP, AVP: missingAVP(dictAVP), Name: rule.AVP}
+		}
+	}
+	for _, a := range avps {
+		g, ok := a.Data.(*GroupedAVP)
+		if !ok {
+			continue
+		}
+		dictAVP, err := d.FindAVPWithVendor(appID, a.Code, a.VendorID)
+		if err != nil {
+			continue
+		}
+		if err := validateAVPs(d, appID, g.AVP, dictAVP.Data.Rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateEnumerated checks that the values of the Enumerated AVPs of
+// the message, including those of Grouped AVPs, are defined in the
+// dictionary. It reports the first undefined value as a *ValidationError
+// with code InvalidAVPValue. Enumerated AVPs without items in the
+// dictionary accept any value.
+func (m *Message) ValidateEnumerated() error {
+	return validateEnumerated(m.Dictionary(), m.Header.ApplicationID, m.AVP)
+}
+
+func validateEnumerated(d *dict.Parser, appID uint32, avps []*AVP) error {
+	for _, a := range avps {
+		switch v := a.Data.(type) {
+		case datatype.Enumerated:
+			dictAVP, err := d.FindAVPWithVendor(appID, a.Code, a.VendorID)
+			if err == nil && len(dictAVP.Data.Enum) > 0 && enumItem(dictAVP, v) == nil {
+				return &ValidationError{Code: InvalidAVPValue, AVP: a, Name: dictAVP.Name}
+			}
+		case *GroupedAVP:
+			if err := validateEnumerated(d, appID, v.AVP); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// missingAVP returns an AVP with the code, vendor and default flags of
+// dictAVP and a zero filled payload of the minimum length of its type,
+// as required in the Failed-AVP of DIAMETER_MISSING_AVP answers.
+func missingAVP(dictAVP *dict.AVP) *AVP {
+	var n int
+	switch dictAVP.Data.Type {
+	case datatype.Integer32Type, datatype.Unsigned32Type, datatype.Float32Type,
+		datatype.EnumeratedType, datatype.TimeType:
+		n = 4
+	case datatype.Integer64Type, datatype.Unsigned64Type, datatype.Float64Type:
+		n = 8
+	case datatype.AddressType:
+		n = 6
+	}
+	return NewAVP(dictAVP.Code, dictAVP.Flags(), dictAVP.VendorID, datatype.Unknown(make([]byte, n)))
+}
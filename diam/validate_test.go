@@ -0,0 +1,168 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ctrlzy/go-diameter/v4/diam"
+	"github.com/ctrlzy/go-diameter/v4/diam/avp"
+	"github.com/ctrlzy/go-diameter/v4/diam/datatype"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+func newValidCCR() *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, 4, dict.Default)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
+	m.NewAVP(avp.ServiceContextID, avp.Mbit, 0, datatype.UTF8String("32251@3gpp.org"))
+	m.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(1))
+	m.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+	return m
+}
+
+func TestMessage_Validate(t *testing.T) {
+	if err := newValidCCR().Validate(); err != nil {
+		t.Fatalf("Unexpected error validating CCR: %v", err)
+	}
+
+	missing := newValidCCR()
+	missing.AVP = missing.AVP[:len(missing.AVP)-1]
+
+	twice := newValidCCR()
+	extra, _ := twice.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli2"))
+
+	unknown := newValidCCR()
+	unsupported, _ := unknown.NewAVP(uint32(99999), avp.Mbit, 0, datatype.Unknown("data"))
+
+	optional := newValidCCR()
+	optional.NewAVP(uint32(99999), 0, 0, datatype.Unknown("data"))
+
+	grouped := newValidCCR()
+	grouped.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.SubscriptionIDType, avp.Mbit, 0, datatype.Enumerated(1)),
+		},
+	})
+
+	bits := newValidCCR()
+	invalid, _ := bits.NewAVP(avp.UserName, 0, 0, datatype.UTF8String("user"))
+
+	command := diam.NewRequest(9999, 4, dict.Default)
+
+	for _, tc := range []struct {
+		name string
+		m    *diam.Message
+		code uint32
+		avp  *diam.AVP
+	}{
+		{"missing AVP", missing, diam.MissingAVP, nil},
+		{"AVP occurs too many times", twice, diam.AVPOccursTooManyTimes, extra},
+		{"unsupported AVP", unknown, diam.AVPUnsupported, unsupported},
+		{"unknown AVP without M-bit", optional, 0, nil},
+		{"missing AVP in Grouped", grouped, diam.MissingAVP, nil},
+		{"invalid AVP bits", bits, diam.InvalidAVPBits, invalid},
+		{"unsupported command", command, diam.CommandUnsupported, nil},
+	} {
+		err := tc.m.Validate()
+		if tc.code == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+		verr, ok := err.(*diam.ValidationError)
+		if !ok {
+			t.Errorf("%s: unexpected error %#v", tc.name, err)
+			continue
+		}
+		if verr.Code != tc.code {
+			t.Errorf("%s: unexpected code. Want %d, have %d", tc.name, tc.code, verr.Code)
+		}
+		if tc.avp != nil && verr.AVP != tc.avp {
+			t.Errorf("%s: unexpected AVP %v", tc.name, verr.AVP)
+		}
+	}
+}
+
+func TestMessage_ValidateEnumerated(t *testing.T) {
+	m := newValidCCR()
+	if err := m.ValidateEnumerated(); err != nil {
+		t.Fatalf("Unexpected error validating CCR: %v", err)
+	}
+	m.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.SubscriptionIDType, avp.Mbit, 0, datatype.Enumerated(99)),
+			diam.NewAVP(avp.SubscriptionIDData, avp.Mbit, 0, datatype.UTF8String("1234")),
+		},
+	})
+	if err := m.Validate(); err != nil {
+		t.Fatalf("Unexpected error validating CCR: %v", err)
+	}
+	err, ok := m.ValidateEnumerated().(*diam.ValidationError)
+	if !ok {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if err.Code != diam.InvalidAVPValue || err.AVP.Code != avp.SubscriptionIDType {
+		t.Fatalf("Unexpected error %v (AVP %v)", err, err.AVP)
+	}
+}
+
+func TestMessage_Validate_MissingAVP(t *testing.T) {
+	m := newValidCCR()
+	m.AVP = m.AVP[:len(m.AVP)-1]
+	err, ok := m.Validate().(*diam.ValidationError)
+	if !ok {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	a := err.AVP
+	if a.Code != avp.CCRequestNumber || a.Flags != avp.Mbit || a.Data.Len() != 4 {
+		t.Fatalf("Unexpected Failed-AVP %v", a)
+	}
+	if err.Error() != "missing AVP CC-Request-Number" {
+		t.Fatalf("Unexpected error message %q", err.Error())
+	}
+}
+
+func TestMessage_Validate_MissingVendorAVP(t *testing.T) {
+	m := diam.NewRequest(diam.UpdateLocation, 16777251, dict.Default)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(avp.AuthSessionState, avp.Mbit, 0, datatype.Enumerated(1))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	m.NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String("001010000000001"))
+	m.NewDictAVP(avp.RATType, datatype.Enumerated(1004))
+	m.NewDictAVP(avp.VisitedPLMNID, datatype.OctetString("\x00\xf1\x10"))
+	err, ok := m.Validate().(*diam.ValidationError)
+	if !ok {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	a := err.AVP
+	if a.Code != avp.ULRFlags || a.VendorID != 10415 || a.Flags != avp.Mbit|avp.Vbit {
+		t.Fatalf("Unexpected Failed-AVP %v", a)
+	}
+}
+
+func TestMessage_Validate_Decoded(t *testing.T) {
+	m := newValidCCR()
+	m.NewAVP(uint32(99999), avp.Mbit, 0, datatype.Unknown("data"))
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = diam.ReadMessage(bytes.NewReader(b), dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Validate()
+	if verr, ok := err.(*diam.ValidationError); !ok || verr.Code != diam.AVPUnsupported {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Generates typed Go structs, AVP codes and Enumerated constants from
+// go-diameter dictionaries.
+// Use: dict-gen-tool -out ./s6a -pkg s6a tgpp_s6a.xml
+//
+// Generated structs are written to <out>/messages.go, the AVP codes to
+// <out>/avp/codes.go, and the Enumerated items of each AVP to the
+// packages in <out>/constants. AVPs referenced by the dictionaries are
+// resolved with the default dictionaries, unless -base=false.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+	"github.com/ctrlzy/go-diameter/v4/diam/dict/codegen"
+)
+
+func main() {
+	out := flag.String("out", ".", "output directory")
+	pkg := flag.String("pkg", "messages", "package name of the generated structs")
+	base := flag.Bool("base", true, "resolve AVPs with the default dictionaries")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("Use: dict-gen-tool [flags] dictionary.xml...")
+	}
+	parser := dict.Default
+	if !*base {
+		parser, _ = dict.NewParser()
+	}
+	// Load all files first, so AVPs may be referenced across files.
+	var files []*dict.File
+	for _, name := range flag.Args() {
+		f, err := parser.LoadFileOnce(name)
+		if err != nil {
+			log.Fatalf("Cannot load %s: %v", name, err)
+		}
+		files = append(files, f)
+	}
+	// Generate the applications of all files together, so types are
+	// declared once.
+	all := &dict.File{}
+	for _, f := range files {
+		all.App = append(all.App, f.App...)
+	}
+	src, err := codegen.New(parser, *pkg).Generate(all)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, b := range src {
+		name = filepath.Join(*out, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			log.Fatal(err)
+		}
+		if err = os.WriteFile(name, b, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Checks the consistency of go-diameter dictionaries, see dict.Parser.Lint.
+// Use: dict-lint-tool [-base] dictionary.xml...
+//
+// The inconsistencies of the dictionaries are printed to stdout, and the
+// exit status is 1 if any is found. With -base, the dictionaries are
+// loaded over a copy of the default dictionaries, to reference their
+// AVPs, and only the inconsistencies found in the given files are
+// printed.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ctrlzy/go-diameter/v4/diam/dict"
+)
+
+func main() {
+	base := flag.Bool("base", false, "load the dictionaries over the default dictionaries")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("Use: dict-lint-tool [-base] dictionary.xml...")
+	}
+	parser, _ := dict.NewParser()
+	if *base {
+		parser = dict.Default.Snapshot()
+	}
+	files := make(map[string]bool)
+	for _, name := range flag.Args() {
+		if _, err := parser.LoadFileOnce(name); err != nil {
+			log.Fatalf("Cannot load %s: %v", name, err)
+		}
+		files[name] = true
+	}
+	found := false
+	for _, err := range parser.Lint() {
+		if *base && !files[err.Filename] {
+			continue
+		}
+		fmt.Println(err)
+		found = true
+	}
+	if found {
+		os.Exit(1)
+	}
+}
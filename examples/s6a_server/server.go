@@ -136,6 +136,12 @@ func handleAIR(settings sm.Settings) diam.HandlerFunc {
 }
 
 func sendULA(settings sm.Settings, w io.Writer, m *diam.Message) (n int64, err error) {
+	// Service-Selection is an IETF AVP: take its vendor and flags from
+	// the dictionary rather than hard-coding them.
+	serviceSelection, err := diam.NewDictAVP(m.Dictionary(), m.Header.ApplicationID, avp.ServiceSelection, datatype.UTF8String("oai.ipv4"))
+	if err != nil {
+		return 0, err
+	}
 
 	m.NewAVP(avp.ULAFlags, avp.Mbit|avp.Vbit, VENDOR_3GPP, datatype.Unsigned32(1))
 	m.NewAVP(avp.SubscriptionData, avp.Mbit, VENDOR_3GPP, &diam.GroupedAVP{
@@ -159,7 +165,7 @@ func sendULA(settings sm.Settings, w io.Writer, m *diam.Message) (n int64, err e
 						AVP: []*diam.AVP{
 							diam.NewAVP(avp.ContextIdentifier, avp.Mbit|avp.Vbit, VENDOR_3GPP, datatype.Unsigned32(0)),
 							diam.NewAVP(avp.PDNType, avp.Mbit|avp.Vbit, VENDOR_3GPP, datatype.Unsigned32(0)),
-							diam.NewAVP(avp.ServiceSelection, avp.Mbit|avp.Vbit, VENDOR_3GPP, datatype.UTF8String("oai.ipv4")),
+							serviceSelection,
 							diam.NewAVP(avp.EPSSubscribedQoSProfile, avp.Mbit|avp.Vbit, VENDOR_3GPP, &diam.GroupedAVP{
 								AVP: []*diam.AVP{
 									diam.NewAVP(avp.QoSClassIdentifier, avp.Mbit|avp.Vbit, VENDOR_3GPP, datatype.Unsigned32(9)),
@@ -19,7 +19,8 @@ type File struct {
 // Vendor defines diameter vendors in XML, that can be used to translate
 // the VendorId AVP of incoming messages.
 type Vendor struct {
-	ID   uint32 `xml:"vendor-id,attr"`
+	ID   string `xml:"vendor-id,attr"`
+	Code uint32 `xml:"code,attr"`
 	Name string `xml:"name,attr"`
 }
 
@@ -43,6 +44,8 @@ type AVP struct {
 	Mandatory  string     `xml:"mandatory,attr"`
 	MayEncrypt string     `xml:"may-encrypt,attr"`
 	Protected  string     `xml:"protected,attr"`
+	VendorBit  string     `xml:"vendor-bit,attr"`
+	VendorID   string     `xml:"vendor-id,attr"`
 	Type       DataType   `xml:"type"`
 	Enum       []*Enum    `xml:"enum"`    // In case of Enumerated AVP
 	Grouped    []*Grouped `xml:"grouped"` // In case of Grouped AVP
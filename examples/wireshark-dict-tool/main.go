@@ -6,17 +6,16 @@
 // Use: wireshark-dict-tool < wireshark-dict.xml > new-dict.xml
 //
 // Some wireshark dictionaries must be slightly fixed before they can
-// be converted by this tool.
+// be converted by this tool. Dictionaries are converted back to the
+// Wireshark format with dict.Parser.WriteWireshark.
 
 package main
 
-// TODO: Improve the parser and fix AVP properties during conversion:
-// <avp name=".." code=".." must="" may="" must-not="" may-encrypt="">
-
 import (
 	"encoding/xml"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/ctrlzy/go-diameter/v4/diam/dict"
 )
@@ -35,7 +34,7 @@ func main() {
 		}
 		copyVendors(wsd.Vendor, newApp)
 		copyCommands(app.Cmd, newApp)
-		copyAvps(app.AVP, newApp)
+		copyAvps(app.AVP, wsd.Vendor, newApp)
 		newDict.App = append(newDict.App, newApp)
 	}
 	os.Stdout.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n"))
@@ -47,7 +46,7 @@ func main() {
 func copyVendors(src []*Vendor, dst *dict.App) {
 	for _, vendor := range src {
 		dst.Vendor = append(dst.Vendor, &dict.Vendor{
-			ID:   vendor.ID,
+			ID:   vendor.Code,
 			Name: vendor.Name,
 		})
 	}
@@ -81,12 +80,17 @@ func copyCmdRules(src []*Rule, dst *dict.CommandRule, required bool) {
 	}
 }
 
-func copyAvps(src []*AVP, dst *dict.App) {
+func copyAvps(src []*AVP, vendors []*Vendor, dst *dict.App) {
 	for _, avp := range src {
 		newAVP := &dict.AVP{
 			Name: avp.Name,
 			Code: avp.Code,
 		}
+		for _, vendor := range vendors {
+			if vendor.ID == avp.VendorID {
+				newAVP.VendorID = vendor.Code
+			}
+		}
 		if avp.Type.Name == "" && avp.Grouped != nil {
 			newAVP.Data = dict.Data{TypeName: "Grouped"}
 		} else {
@@ -100,22 +104,7 @@ func copyAvps(src []*AVP, dst *dict.App) {
 		default:
 			newAVP.MayEncrypt = "-"
 		}
-		switch avp.Mandatory {
-		case "must":
-			newAVP.Must = "M"
-		case "may":
-			newAVP.May = "P"
-		default:
-			newAVP.Must = ""
-		}
-		if newAVP.May != "" {
-			switch avp.Protected {
-			case "may":
-				newAVP.May = "P"
-			default:
-				newAVP.May = ""
-			}
-		}
+		newAVP.Must, newAVP.May, newAVP.MustNot = copyFlags(avp)
 		for _, p := range avp.Enum {
 			newAVP.Data.Enum = append(newAVP.Data.Enum,
 				&dict.Enum{
@@ -154,3 +143,27 @@ func copyAvps(src []*AVP, dst *dict.App) {
 		dst.AVP = append(dst.AVP, newAVP)
 	}
 }
+
+// copyFlags returns the must, may and must-not flags of the AVP from its
+// vendor-bit, mandatory and protected rules.
+func copyFlags(avp *AVP) (must, may, mustNot string) {
+	var m, y, n []string
+	for _, f := range []struct{ rule, def, flag string }{
+		{avp.VendorBit, "mustnot", "V"},
+		{avp.Mandatory, "may", "M"},
+		{avp.Protected, "may", "P"},
+	} {
+		if f.rule == "" {
+			f.rule = f.def
+		}
+		switch f.rule {
+		case "must":
+			m = append(m, f.flag)
+		case "may":
+			y = append(y, f.flag)
+		default: // mustnot, shouldnot
+			n = append(n, f.flag)
+		}
+	}
+	return strings.Join(m, ","), strings.Join(y, ","), strings.Join(n, ",")
+}